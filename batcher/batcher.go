@@ -7,11 +7,15 @@ import (
 
 	"NATter/batcher/encoder"
 	"NATter/driver"
+	"NATter/entity"
+	"NATter/errtpl"
 	"NATter/log"
 
 	"github.com/pkg/errors"
 )
 
+var ErrStopped = errors.Wrap(errtpl.ErrServiceUnavailable, "batcher is stopped")
+
 type Config struct {
 	Timeout  uint32 // seconds
 	Capacity uint32
@@ -19,15 +23,27 @@ type Config struct {
 
 type Batcher interface {
 	Run(context.Context)
-	Send(msg []byte) error
-	Request(msg []byte) ([]byte, error)
+	Send(msg *entity.Message) error
+	Request(msg *entity.Message) (*entity.Message, error)
+}
+
+type request struct {
+	msg  *entity.Message
+	resp chan *response
+}
+
+type response struct {
+	msg []byte
+	err error
 }
 
 type batcher struct {
 	sender driver.Sender
 	enc    encoder.Encoder
 
-	msgChan chan []byte
+	msgChan chan *entity.Message
+	reqChan chan *request
+	done    chan struct{} // closed once Run stops reading messages and requests
 	wg      *sync.WaitGroup
 
 	timeout  time.Duration
@@ -38,7 +54,9 @@ func New(cfg *Config, sender driver.Sender, enc encoder.Encoder) (Batcher, error
 	batcher := &batcher{
 		sender:   sender,
 		enc:      enc,
-		msgChan:  make(chan []byte),
+		msgChan:  make(chan *entity.Message),
+		reqChan:  make(chan *request),
+		done:     make(chan struct{}),
 		wg:       &sync.WaitGroup{},
 		timeout:  time.Duration(cfg.Timeout) * time.Second,
 		capacity: cfg.Capacity,
@@ -53,7 +71,8 @@ func New(cfg *Config, sender driver.Sender, enc encoder.Encoder) (Batcher, error
 
 func (b *batcher) Run(ctx context.Context) {
 	ticker := b.prepareTicker()
-	msgs := [][]byte{}
+	msgs := []*entity.Message{}
+	reqs := []*request{}
 
 OUTER:
 	for {
@@ -63,7 +82,7 @@ OUTER:
 
 			log.Debug("pushed new message to batch")
 
-			if len(msgs) < int(b.capacity) || b.capacity == 0 {
+			if !b.isFull(len(msgs)) {
 				break
 			}
 
@@ -71,23 +90,44 @@ OUTER:
 
 			msgs = nil
 
-			if b.timeout > 0 {
-				ticker.Reset(b.timeout)
+			b.resetTicker(ticker)
+		case req := <-b.reqChan:
+			reqs = append(reqs, req)
+
+			log.Debug("pushed new request to batch")
+
+			if !b.isFull(len(reqs)) {
+				break
 			}
+
+			b.releaseRequestBatch(reqs)
+
+			reqs = nil
+
+			b.resetTicker(ticker)
 		case <-ticker.C:
 			b.releaseBatch(msgs)
+			b.releaseRequestBatch(reqs)
 
 			msgs = nil
+			reqs = nil
 		case <-ctx.Done():
 			b.releaseBatch(msgs)
+			b.releaseRequestBatch(reqs)
 
 			break OUTER
 		}
 	}
 
+	close(b.done)
+
 	b.wg.Wait()
 }
 
+func (b *batcher) isFull(size int) bool {
+	return b.capacity != 0 && size >= int(b.capacity)
+}
+
 func (b *batcher) prepareTicker() (t *time.Ticker) {
 	if b.timeout > 0 {
 		t = time.NewTicker(b.timeout)
@@ -99,7 +139,13 @@ func (b *batcher) prepareTicker() (t *time.Ticker) {
 	return t
 }
 
-func (b *batcher) releaseBatch(msgs [][]byte) {
+func (b *batcher) resetTicker(t *time.Ticker) {
+	if b.timeout > 0 {
+		t.Reset(b.timeout)
+	}
+}
+
+func (b *batcher) releaseBatch(msgs []*entity.Message) {
 	if len(msgs) == 0 {
 		return
 	}
@@ -109,7 +155,15 @@ func (b *batcher) releaseBatch(msgs [][]byte) {
 	go func() {
 		defer b.wg.Done()
 
-		batch, err := b.enc.Marshal(msgs)
+		payloads := make([][]byte, len(msgs))
+		ctxs := make([]context.Context, len(msgs))
+
+		for i, msg := range msgs {
+			payloads[i] = msg.Payload
+			ctxs[i] = msg.Context()
+		}
+
+		batch, err := b.enc.Marshal(payloads)
 
 		if err != nil {
 			log.Error(err)
@@ -117,7 +171,11 @@ func (b *batcher) releaseBatch(msgs [][]byte) {
 			return
 		}
 
-		if err := b.sender.Send(batch); err != nil {
+		// Senders do not wait for the batch, so it is only bound by their earliest deadline.
+		ctx, cancel := batchContext(ctxs, false)
+		defer cancel()
+
+		if err := b.sender.Send(entity.NewMessage(batch).WithContext(ctx)); err != nil {
 			log.Error(err)
 
 			return
@@ -127,12 +185,142 @@ func (b *batcher) releaseBatch(msgs [][]byte) {
 	}()
 }
 
-func (b *batcher) Send(msg []byte) error {
-	b.msgChan <- msg
+func (b *batcher) releaseRequestBatch(reqs []*request) {
+	if len(reqs) == 0 {
+		return
+	}
+
+	b.wg.Add(1)
+
+	go func() {
+		defer b.wg.Done()
+
+		resps, err := b.requestBatch(reqs)
+
+		for i, req := range reqs {
+			if err != nil {
+				req.resp <- &response{err: err}
+
+				continue
+			}
+
+			req.resp <- &response{msg: resps[i]}
+		}
+
+		if err != nil {
+			log.Error(err)
+
+			return
+		}
+
+		log.Debugf("released new batch of %d requests", len(reqs))
+	}()
+}
+
+func (b *batcher) requestBatch(reqs []*request) ([][]byte, error) {
+	msgs := make([][]byte, len(reqs))
+	ctxs := make([]context.Context, len(reqs))
+
+	for i, req := range reqs {
+		msgs[i] = req.msg.Payload
+		ctxs[i] = req.msg.Context()
+	}
+
+	batch, err := b.enc.Marshal(msgs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := batchContext(ctxs, true)
+	defer cancel()
+
+	resp, err := b.sender.Request(entity.NewMessage(batch).WithContext(ctx))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return b.enc.Split(resp.Payload, msgs)
+}
+
+func (b *batcher) Send(msg *entity.Message) error {
+	select {
+	case b.msgChan <- msg:
+		return nil
+	case <-msg.Context().Done():
+		return msg.Context().Err()
+	case <-b.done:
+		return ErrStopped
+	}
+}
+
+func (b *batcher) Request(msg *entity.Message) (*entity.Message, error) {
+	req := &request{
+		msg:  msg,
+		resp: make(chan *response, 1),
+	}
+
+	select {
+	case b.reqChan <- req:
+	case <-msg.Context().Done():
+		return nil, msg.Context().Err()
+	case <-b.done:
+		return nil, ErrStopped
+	}
+
+	var resp *response
+
+	// The request pushed to the batch is always answered since Run releases the last batch,
+	// the buffered channel lets the batch be answered after the requester has gone.
+	select {
+	case resp = <-req.resp:
+	case <-msg.Context().Done():
+		return nil, msg.Context().Err()
+	}
+
+	if resp.err != nil {
+		return nil, resp.err
+	}
 
-	return nil
+	return entity.NewMessage(resp.msg), nil
 }
 
-func (b *batcher) Request(msg []byte) ([]byte, error) {
-	return nil, errors.New("request is not supported in protobuf batching")
+// batchContext returns the context of a batch with the earliest deadline of the contexts. If wait is
+// set, the context is also canceled once all the contexts are done, since nobody waits for the batch then.
+func batchContext(ctxs []context.Context, wait bool) (context.Context, context.CancelFunc) {
+	var deadline time.Time
+
+	for _, ctx := range ctxs {
+		if d, ok := ctx.Deadline(); ok && (deadline.IsZero() || d.Before(deadline)) {
+			deadline = d
+		}
+	}
+
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+	)
+
+	if deadline.IsZero() {
+		ctx, cancel = context.WithCancel(context.Background())
+	} else {
+		ctx, cancel = context.WithDeadline(context.Background(), deadline)
+	}
+
+	if wait {
+		go func() {
+			for _, c := range ctxs {
+				select {
+				case <-c.Done():
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			cancel()
+		}()
+	}
+
+	return ctx, cancel
 }
@@ -1,10 +1,14 @@
 package batcher
 
 import (
+	"bytes"
 	"context"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"NATter/entity"
 	m "NATter/mock"
 
 	"github.com/pkg/errors"
@@ -23,7 +27,7 @@ func TestBatcherRunOnFullBatch(t *testing.T) {
 	enc := &m.BatcherEncoder{}
 
 	sender.
-		On("Send", []byte("batch-of-data")).
+		On("Send", m.Message(entity.NewMessage([]byte("batch-of-data")))).
 		Return(nil).Once()
 
 	enc.
@@ -44,7 +48,7 @@ func TestBatcherRunOnFullBatch(t *testing.T) {
 
 	go func() {
 		for i := 0; i < 3; i++ {
-			err := bat.Send([]byte("some-data"))
+			err := bat.Send(entity.NewMessage([]byte("some-data")))
 
 			assert.Nil(t, err)
 		}
@@ -64,7 +68,7 @@ func TestBatcherRunOnTimeout(t *testing.T) {
 	enc := &m.BatcherEncoder{}
 
 	sender.
-		On("Send", []byte("batch-of-data")).
+		On("Send", m.Message(entity.NewMessage([]byte("batch-of-data")))).
 		Return(nil).Once()
 
 	enc.
@@ -82,7 +86,7 @@ func TestBatcherRunOnTimeout(t *testing.T) {
 	assert.NotNil(t, bat)
 
 	go func() {
-		err := bat.Send([]byte("some-data"))
+		err := bat.Send(entity.NewMessage([]byte("some-data")))
 
 		assert.Nil(t, err)
 	}()
@@ -114,7 +118,7 @@ func TestBatcherRunOnMarshalError(t *testing.T) {
 	assert.NotNil(t, bat)
 
 	go func() {
-		err := bat.Send([]byte("some-data"))
+		err := bat.Send(entity.NewMessage([]byte("some-data")))
 
 		assert.Nil(t, err)
 	}()
@@ -132,7 +136,7 @@ func TestBatcherRunOnSendError(t *testing.T) {
 	enc := &m.BatcherEncoder{}
 
 	sender.
-		On("Send", []byte("batch-of-data")).
+		On("Send", m.Message(entity.NewMessage([]byte("batch-of-data")))).
 		Return(errors.New("error"))
 
 	enc.
@@ -150,7 +154,7 @@ func TestBatcherRunOnSendError(t *testing.T) {
 	assert.NotNil(t, bat)
 
 	go func() {
-		err := bat.Send([]byte("some-data"))
+		err := bat.Send(entity.NewMessage([]byte("some-data")))
 
 		assert.Nil(t, err)
 	}()
@@ -186,7 +190,7 @@ func TestBatcherSend(t *testing.T) {
 	enc := &m.BatcherEncoder{}
 
 	sender.
-		On("Send", []byte("batch-of-data")).
+		On("Send", m.Message(entity.NewMessage([]byte("batch-of-data")))).
 		Return(nil).Once()
 
 	enc.
@@ -204,7 +208,7 @@ func TestBatcherSend(t *testing.T) {
 	assert.NotNil(t, bat)
 
 	go func() {
-		err := bat.Send([]byte("some-data"))
+		err := bat.Send(entity.NewMessage([]byte("some-data")))
 
 		assert.Nil(t, err)
 	}()
@@ -218,17 +222,262 @@ func TestBatcherSend(t *testing.T) {
 	enc.AssertExpectations(t)
 }
 
+type joinEncoder struct {
+}
+
+func (e *joinEncoder) Marshal(msgs [][]byte) ([]byte, error) {
+	return bytes.Join(msgs, []byte(",")), nil
+}
+
+func (e *joinEncoder) Split(batch []byte, _ [][]byte) ([][]byte, error) {
+	return bytes.Split(batch, []byte(",")), nil
+}
+
+type echoSender struct {
+	requests int
+}
+
+func (s *echoSender) Send(*entity.Message) error {
+	return nil
+}
+
+func (s *echoSender) Request(batch *entity.Message) (*entity.Message, error) {
+	s.requests++
+
+	return entity.NewMessage(bytes.ReplaceAll(batch.Payload, []byte("request"), []byte("response"))), nil
+}
+
 func TestBatcherRequest(t *testing.T) {
+	sender := &echoSender{}
+
 	bat, err := New(&Config{
 		Timeout:  30,
-		Capacity: 3,
-	}, &m.DriverSender{}, &m.BatcherEncoder{})
+		Capacity: 2,
+	}, sender, &joinEncoder{})
 
 	assert.Nil(t, err)
 	assert.NotNil(t, bat)
 
-	respb, err := bat.Request([]byte("some-data"))
+	wg := &sync.WaitGroup{}
 
-	assert.Error(t, err)
-	assert.Nil(t, respb)
+	for _, msg := range []string{"request-data1", "request-data2"} {
+		wg.Add(1)
+
+		go func(msg string) {
+			defer wg.Done()
+
+			resp, err := bat.Request(entity.NewMessage([]byte(msg)))
+
+			assert.Nil(t, err)
+			assert.Equal(t, strings.Replace(msg, "request", "response", 1), string(resp.Payload))
+		}(msg)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+
+	bat.Run(ctx)
+	wg.Wait()
+
+	assert.Equal(t, 1, sender.requests)
+}
+
+func TestBatcherRequestOnRequestError(t *testing.T) {
+	sender := &m.DriverSender{}
+	enc := &m.BatcherEncoder{}
+
+	sender.
+		On("Request", m.Message(entity.NewMessage([]byte("batch-of-requests")))).
+		Return((*entity.Message)(nil), errors.New("error")).Once()
+
+	enc.
+		On("Marshal", [][]byte{
+			[]byte("request-data"),
+		}).
+		Return([]byte("batch-of-requests"), nil).Once()
+
+	bat, err := New(&Config{
+		Timeout:  30,
+		Capacity: 1,
+	}, sender, enc)
+
+	assert.Nil(t, err)
+	assert.NotNil(t, bat)
+
+	go func() {
+		resp, err := bat.Request(entity.NewMessage([]byte("request-data")))
+
+		assert.Error(t, err)
+		assert.Nil(t, resp)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	bat.Run(ctx)
+
+	sender.AssertExpectations(t)
+	enc.AssertExpectations(t)
+}
+
+func TestBatcherRequestOnSplitError(t *testing.T) {
+	sender := &m.DriverSender{}
+	enc := &m.BatcherEncoder{}
+
+	sender.
+		On("Request", m.Message(entity.NewMessage([]byte("batch-of-requests")))).
+		Return(entity.NewMessage([]byte("batch-of-responses")), nil).Once()
+
+	enc.
+		On("Marshal", [][]byte{
+			[]byte("request-data"),
+		}).
+		Return([]byte("batch-of-requests"), nil).Once()
+	enc.
+		On("Split", []byte("batch-of-responses"), [][]byte{
+			[]byte("request-data"),
+		}).
+		Return([][]byte(nil), errors.New("error")).Once()
+
+	bat, err := New(&Config{
+		Timeout:  30,
+		Capacity: 1,
+	}, sender, enc)
+
+	assert.Nil(t, err)
+	assert.NotNil(t, bat)
+
+	go func() {
+		resp, err := bat.Request(entity.NewMessage([]byte("request-data")))
+
+		assert.Error(t, err)
+		assert.Nil(t, resp)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	bat.Run(ctx)
+
+	sender.AssertExpectations(t)
+	enc.AssertExpectations(t)
+}
+
+func TestBatcherRequestOnStopped(t *testing.T) {
+	bat, err := New(&Config{Timeout: 30}, &m.DriverSender{}, &m.BatcherEncoder{})
+	assert.Nil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	bat.Run(ctx)
+
+	resp, err := bat.Request(entity.NewMessage([]byte("request-data")))
+
+	assert.ErrorIs(t, err, ErrStopped)
+	assert.Nil(t, resp)
+	assert.ErrorIs(t, bat.Send(entity.NewMessage([]byte("some-data"))), ErrStopped)
+}
+
+func TestBatcherRequestOnCanceledMessage(t *testing.T) {
+	bat, err := New(&Config{Timeout: 30}, &m.DriverSender{}, &m.BatcherEncoder{})
+	assert.Nil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// Run is not started, so nothing reads the batch.
+	resp, err := bat.Request(entity.NewMessage([]byte("request-data")).WithContext(ctx))
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Nil(t, resp)
+	assert.ErrorIs(t, bat.Send(entity.NewMessage([]byte("some-data")).WithContext(ctx)), context.Canceled)
+}
+
+// blockingSender waits for the batch context to be done and reports its error.
+type blockingSender struct {
+	errs chan error
+}
+
+func (s *blockingSender) Send(*entity.Message) error {
+	return nil
+}
+
+func (s *blockingSender) Request(batch *entity.Message) (*entity.Message, error) {
+	<-batch.Context().Done()
+
+	s.errs <- batch.Context().Err()
+
+	return nil, batch.Context().Err()
+}
+
+func TestBatcherRequestOnCanceledCaller(t *testing.T) {
+	sender := &blockingSender{errs: make(chan error, 1)}
+
+	bat, err := New(&Config{Capacity: 1}, sender, &joinEncoder{})
+	assert.Nil(t, err)
+
+	runCtx, stop := context.WithCancel(context.Background())
+	defer stop()
+
+	go bat.Run(runCtx)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		time.Sleep(time.Millisecond * 10)
+		cancel()
+	}()
+
+	resp, err := bat.Request(entity.NewMessage([]byte("request-data")).WithContext(ctx))
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Nil(t, resp)
+
+	// The batched request is aborted once its only caller has gone.
+	select {
+	case err := <-sender.errs:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatal("batched request is not aborted")
+	}
+}
+
+func TestBatchContext(t *testing.T) {
+	first, cancelFirst := context.WithTimeout(context.Background(), time.Hour)
+	defer cancelFirst()
+
+	second, cancelSecond := context.WithTimeout(context.Background(), time.Minute)
+
+	ctx, cancel := batchContext([]context.Context{first, second, context.Background()}, false)
+	defer cancel()
+
+	deadline, ok := ctx.Deadline()
+	expected, _ := second.Deadline()
+
+	assert.True(t, ok)
+	assert.Equal(t, expected, deadline)
+
+	cancelSecond()
+
+	// Senders of messages do not wait for the batch.
+	assert.NoError(t, ctx.Err())
+
+	first, cancelFirst = context.WithCancel(context.Background())
+	second, cancelSecond = context.WithCancel(context.Background())
+
+	ctx, cancel = batchContext([]context.Context{first, second}, true)
+	defer cancel()
+
+	_, ok = ctx.Deadline()
+
+	assert.False(t, ok)
+
+	cancelFirst()
+
+	assert.NoError(t, ctx.Err())
+
+	cancelSecond()
+
+	assert.Eventually(t, func() bool { return ctx.Err() != nil }, time.Second, time.Millisecond)
 }
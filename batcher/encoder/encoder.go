@@ -2,4 +2,5 @@ package encoder
 
 type Encoder interface {
 	Marshal(msgs [][]byte) ([]byte, error)
+	Split(batch []byte, msgs [][]byte) ([][]byte, error)
 }
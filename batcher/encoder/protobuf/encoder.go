@@ -1,6 +1,7 @@
 package protobuf
 
 import (
+	"github.com/pkg/errors"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/anypb"
 )
@@ -31,3 +32,49 @@ func (e *Encoder) Marshal(msgs [][]byte) ([]byte, error) {
 
 	return b, nil
 }
+
+// Split distributes messages of the response batch among the requests by index,
+// so that each request gets as many messages as it contained.
+func (e *Encoder) Split(batch []byte, msgs [][]byte) ([][]byte, error) {
+	accumulator := &Batch{}
+
+	if err := proto.Unmarshal(batch, accumulator); err != nil {
+		return nil, err
+	}
+
+	sizes := make([]int, len(msgs))
+	total := 0
+
+	for i, msg := range msgs {
+		req := &Batch{}
+
+		if err := proto.Unmarshal(msg, req); err != nil {
+			return nil, err
+		}
+
+		sizes[i] = len(req.Messages)
+		total += sizes[i]
+	}
+
+	if total != len(accumulator.Messages) {
+		return nil, errors.Errorf("batch response has %d messages instead of %d", len(accumulator.Messages), total)
+	}
+
+	res := make([][]byte, len(msgs))
+	offset := 0
+
+	for i, size := range sizes {
+		b, err := proto.Marshal(&Batch{
+			Messages: accumulator.Messages[offset : offset+size],
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		res[i] = b
+		offset += size
+	}
+
+	return res, nil
+}
@@ -48,3 +48,82 @@ func TestEncoderMarshalOnUmarshalError(t *testing.T) {
 	assert.Error(t, err)
 	assert.Nil(t, res)
 }
+
+func TestEncoderSplit(t *testing.T) {
+	enc := &Encoder{}
+
+	msg1, err := proto.Marshal(&Batch{
+		Messages: []*anypb.Any{{TypeUrl: "request1"}},
+	})
+
+	assert.Nil(t, err)
+
+	msg2, err := proto.Marshal(&Batch{
+		Messages: []*anypb.Any{{TypeUrl: "request2"}, {TypeUrl: "request3"}},
+	})
+
+	assert.Nil(t, err)
+
+	batch, err := proto.Marshal(&Batch{
+		Messages: []*anypb.Any{{TypeUrl: "response1"}, {TypeUrl: "response2"}, {TypeUrl: "response3"}},
+	})
+
+	assert.Nil(t, err)
+
+	resp1, err := proto.Marshal(&Batch{
+		Messages: []*anypb.Any{{TypeUrl: "response1"}},
+	})
+
+	assert.Nil(t, err)
+
+	resp2, err := proto.Marshal(&Batch{
+		Messages: []*anypb.Any{{TypeUrl: "response2"}, {TypeUrl: "response3"}},
+	})
+
+	assert.Nil(t, err)
+
+	res, err := enc.Split(batch, [][]byte{
+		msg1,
+		msg2,
+	})
+
+	assert.Equal(t, [][]byte{resp1, resp2}, res)
+	assert.Nil(t, err)
+}
+
+func TestEncoderSplitOnSizeMismatch(t *testing.T) {
+	enc := &Encoder{}
+
+	msg, err := proto.Marshal(&Batch{
+		Messages: []*anypb.Any{{}, {}},
+	})
+
+	assert.Nil(t, err)
+
+	batch, err := proto.Marshal(&Batch{
+		Messages: []*anypb.Any{{}},
+	})
+
+	assert.Nil(t, err)
+
+	res, err := enc.Split(batch, [][]byte{msg})
+
+	assert.Error(t, err)
+	assert.Nil(t, res)
+}
+
+func TestEncoderSplitOnUmarshalError(t *testing.T) {
+	enc := &Encoder{}
+
+	res, err := enc.Split([]byte("broken-data"), [][]byte{})
+
+	assert.Error(t, err)
+	assert.Nil(t, res)
+
+	res, err = enc.Split([]byte{}, [][]byte{
+		[]byte("broken-data"),
+	})
+
+	assert.Error(t, err)
+	assert.Nil(t, res)
+}
@@ -0,0 +1,178 @@
+package breaker
+
+import (
+	"sync"
+	"time"
+
+	"NATter/log"
+
+	"github.com/pkg/errors"
+)
+
+type State string
+
+const (
+	StateClosed   State = "closed"
+	StateOpen     State = "open"
+	StateHalfOpen State = "half-open"
+
+	// Interval to check whether a probe is finished.
+	probeWait = time.Millisecond * 100
+)
+
+type Config struct {
+	Host      string
+	Threshold uint32 // consecutive failures to open
+	Duration  uint32 // seconds to stay open before probing
+	Probes    uint32 // successful probes to close
+}
+
+// Breaker stops sending to an endpoint host after consecutive failures and probes it
+// with single messages after the open duration. It is shared by routes of the host.
+type Breaker struct {
+	host      string
+	threshold uint32
+	duration  time.Duration
+	probes    uint32
+
+	mx        sync.Mutex
+	state     State
+	failures  uint32
+	successes uint32
+	probe     uint64 // token of the probe in flight, zero if there is none
+	tokens    uint64 // number of issued probe tokens
+	openedAt  time.Time
+}
+
+func New(cfg *Config) (*Breaker, error) {
+	if cfg.Threshold == 0 {
+		return nil, errors.New("breaker threshold must be positive")
+	}
+
+	if cfg.Duration == 0 {
+		return nil, errors.New("breaker duration must be positive")
+	}
+
+	probes := cfg.Probes
+
+	if probes == 0 {
+		probes = 1
+	}
+
+	return &Breaker{
+		host:      cfg.Host,
+		threshold: cfg.Threshold,
+		duration:  time.Duration(cfg.Duration) * time.Second,
+		probes:    probes,
+		state:     StateClosed,
+	}, nil
+}
+
+func (b *Breaker) String() string {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	if b.state == StateOpen && time.Since(b.openedAt) >= b.duration {
+		return string(StateHalfOpen)
+	}
+
+	return string(b.state)
+}
+
+// allow reports whether a message may be sent or how long to wait otherwise. The message sent
+// in the half-open state is a probe, it gets a non-zero token to record its result with.
+func (b *Breaker) allow() (uint64, time.Duration, bool) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	switch b.state {
+	case StateOpen:
+		if left := b.duration - time.Since(b.openedAt); left > 0 {
+			return 0, left, false
+		}
+
+		b.setState(StateHalfOpen)
+		b.successes = 0
+
+		fallthrough
+	case StateHalfOpen:
+		if b.probe != 0 {
+			return 0, probeWait, false
+		}
+
+		b.tokens++
+		b.probe = b.tokens
+
+		return b.probe, 0, true
+	default:
+		return 0, 0, true
+	}
+}
+
+// release lets another probe through if the token is of the probe in flight, its result is not recorded.
+func (b *Breaker) release(token uint64) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	if token != 0 && token == b.probe {
+		b.probe = 0
+	}
+}
+
+// record updates the state by whether a message sent with the token failed and reports whether
+// the breaker is open. Only the probe in flight changes the half-open state, messages sent
+// before the breaker opened do not.
+func (b *Breaker) record(token uint64, failed bool) bool {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	if b.state == StateHalfOpen {
+		if token == 0 || token != b.probe {
+			return false
+		}
+
+		b.probe = 0
+
+		if failed {
+			b.open()
+
+			return true
+		}
+
+		if b.successes++; b.successes >= b.probes {
+			b.failures = 0
+			b.setState(StateClosed)
+		}
+
+		return false
+	}
+
+	if !failed {
+		b.failures = 0
+
+		return false
+	}
+
+	if b.failures++; b.failures >= b.threshold && b.state == StateClosed {
+		b.open()
+	}
+
+	return b.state == StateOpen
+}
+
+func (b *Breaker) open() {
+	b.openedAt = time.Now()
+	b.setState(StateOpen)
+}
+
+func (b *Breaker) setState(state State) {
+	if b.state == state {
+		return
+	}
+
+	b.state = state
+
+	log.WithFields(log.Fields{
+		log.FieldEndpoint: b.host,
+	}).Warnf("circuit breaker is %s", state)
+}
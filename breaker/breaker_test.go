@@ -0,0 +1,306 @@
+package breaker
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"NATter/entity"
+	"NATter/errtpl"
+	m "NATter/mock"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewOnInvalidParameters(t *testing.T) {
+	for _, cfg := range []*Config{
+		{Duration: 1},
+		{Threshold: 1},
+	} {
+		brk, err := New(cfg)
+
+		assert.Error(t, err)
+		assert.Nil(t, brk)
+	}
+}
+
+func TestGroupSenderOnInvalidParameters(t *testing.T) {
+	grd, err := NewGroup().Sender(&Config{Duration: 1}, "http://svc", &m.DriverSender{}, false)
+
+	assert.Error(t, err)
+	assert.Nil(t, grd)
+}
+
+func TestGuardSendOnReject(t *testing.T) {
+	sender := &m.DriverSender{}
+
+	sender.
+		On("Send", entity.NewMessage([]byte("some-data"))).
+		Return(errors.New("error")).Twice()
+
+	snd, err := NewGroup().Sender(&Config{Threshold: 2, Duration: 30}, "http://svc/path", sender, false)
+
+	assert.Nil(t, err)
+
+	assert.Error(t, snd.Send(entity.NewMessage([]byte("some-data"))))
+	assert.Equal(t, "closed", snd.String())
+
+	assert.Error(t, snd.Send(entity.NewMessage([]byte("some-data"))))
+	assert.Equal(t, "open", snd.String())
+
+	err = snd.Send(entity.NewMessage([]byte("some-data")))
+
+	assert.True(t, errors.Is(err, errtpl.ErrServiceUnavailable))
+	sender.AssertExpectations(t)
+}
+
+func TestGuardSendOnClientError(t *testing.T) {
+	sender := &m.DriverSender{}
+
+	sender.
+		On("Send", entity.NewMessage([]byte("some-data"))).
+		Return(&errtpl.StatusError{Code: http.StatusBadRequest}).Twice()
+
+	snd, err := NewGroup().Sender(&Config{Threshold: 1, Duration: 30}, "http://svc/path", sender, true)
+
+	assert.Nil(t, err)
+
+	// The host has handled the messages, so they are returned at once and do not open the breaker.
+	for i := 0; i < 2; i++ {
+		err = snd.Send(entity.NewMessage([]byte("some-data")))
+
+		assert.Equal(t, http.StatusBadRequest, errtpl.Status(err))
+		assert.Equal(t, "closed", snd.String())
+	}
+
+	sender.AssertExpectations(t)
+}
+
+func TestGuardSendOnFailures(t *testing.T) {
+	for _, err := range []error{
+		errors.New("connection refused"),
+		errtpl.ErrGatewayTimeout,
+		&errtpl.StatusError{Code: http.StatusBadGateway},
+		&errtpl.StatusError{Code: http.StatusTooManyRequests},
+	} {
+		sender := &m.DriverSender{}
+
+		sender.
+			On("Send", entity.NewMessage(nil)).
+			Return(err).Once()
+
+		snd, serr := NewGroup().Sender(&Config{Threshold: 1, Duration: 30}, "http://svc/path", sender, false)
+
+		assert.Nil(t, serr)
+		assert.Error(t, snd.Send(entity.NewMessage(nil)))
+		assert.Equal(t, "open", snd.String(), err.Error())
+	}
+}
+
+func TestGuardRequestOnHalfOpen(t *testing.T) {
+	sender := &m.DriverSender{}
+
+	sender.
+		On("Request", entity.NewMessage([]byte("request-data"))).
+		Return(entity.NewMessage([]byte("response-data")), nil).Twice()
+
+	group := NewGroup()
+
+	snd, err := group.Sender(&Config{Threshold: 1, Duration: 1, Probes: 2}, "http://svc/path", sender, false)
+
+	assert.Nil(t, err)
+
+	// Opened a while ago.
+	brk := group.breakers["svc"]
+	brk.open()
+	brk.openedAt = time.Now().Add(-time.Second)
+
+	assert.Equal(t, "half-open", snd.String())
+
+	resp, err := snd.Request(entity.NewMessage([]byte("request-data")))
+
+	assert.Nil(t, err)
+	assert.Equal(t, "response-data", string(resp.Payload))
+	assert.Equal(t, "half-open", snd.String())
+
+	_, err = snd.Request(entity.NewMessage([]byte("request-data")))
+
+	assert.Nil(t, err)
+	assert.Equal(t, "closed", snd.String())
+	sender.AssertExpectations(t)
+}
+
+func TestGuardSendOnFailedProbe(t *testing.T) {
+	sender := &m.DriverSender{}
+
+	sender.
+		On("Send", entity.NewMessage([]byte("some-data"))).
+		Return(errors.New("error")).Once()
+
+	group := NewGroup()
+
+	snd, err := group.Sender(&Config{Threshold: 1, Duration: 1}, "http://svc/path", sender, false)
+
+	assert.Nil(t, err)
+
+	brk := group.breakers["svc"]
+	brk.open()
+	brk.openedAt = time.Now().Add(-time.Second)
+
+	assert.Error(t, snd.Send(entity.NewMessage([]byte("some-data"))))
+	assert.Equal(t, "open", snd.String())
+	sender.AssertExpectations(t)
+}
+
+func TestBreakerRecordOnStaleMessage(t *testing.T) {
+	brk, err := New(&Config{Threshold: 1, Duration: 1})
+
+	assert.Nil(t, err)
+
+	// The message is sent before the breaker opens.
+	stale, _, ok := brk.allow()
+
+	assert.True(t, ok)
+	assert.Zero(t, stale)
+
+	brk.open()
+	brk.openedAt = time.Now().Add(-time.Second)
+
+	probe, _, ok := brk.allow()
+
+	assert.True(t, ok)
+	assert.NotZero(t, probe)
+	assert.Equal(t, "half-open", brk.String())
+
+	// Only the probe changes the half-open state.
+	assert.False(t, brk.record(stale, false))
+	assert.Equal(t, "half-open", brk.String())
+
+	_, _, ok = brk.allow()
+
+	assert.False(t, ok)
+
+	assert.False(t, brk.record(probe, false))
+	assert.Equal(t, "closed", brk.String())
+}
+
+func TestGuardSendOnCanceled(t *testing.T) {
+	sender := &m.DriverSender{}
+
+	sender.
+		On("Send", entity.NewMessage([]byte("some-data"))).
+		Return(errors.Wrap(context.Canceled, "endpoint")).Twice()
+
+	group := NewGroup()
+
+	snd, err := group.Sender(&Config{Threshold: 1, Duration: 1}, "http://svc/path", sender, false)
+
+	assert.Nil(t, err)
+
+	// A canceled message does not open the breaker.
+	assert.Error(t, snd.Send(entity.NewMessage([]byte("some-data"))))
+	assert.Equal(t, "closed", snd.String())
+
+	brk := group.breakers["svc"]
+	brk.open()
+	brk.openedAt = time.Now().Add(-time.Second)
+
+	// A canceled probe lets the next one through.
+	assert.Error(t, snd.Send(entity.NewMessage([]byte("some-data"))))
+	assert.Equal(t, "half-open", snd.String())
+
+	_, _, ok := brk.allow()
+
+	assert.True(t, ok)
+	sender.AssertExpectations(t)
+}
+
+func TestGuardSendOnHosts(t *testing.T) {
+	sender := &m.DriverSender{}
+
+	sender.
+		On("Send", &entity.Message{Params: map[string]string{"host": "svc1"}}).
+		Return(errors.New("error")).Once()
+
+	group := NewGroup()
+
+	snd, err := group.Sender(&Config{Threshold: 1, Duration: 30}, "http://{{ .uri.host }}/path", sender, false)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 0, group.Len())
+
+	assert.Error(t, snd.Send(&entity.Message{Params: map[string]string{"host": "svc1"}}))
+	assert.Equal(t, "open", group.breakers["svc1"].String())
+
+	// Another host has a breaker of its own.
+	other, err := group.Sender(&Config{Threshold: 1, Duration: 30}, "http://svc2/path", sender, false)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "closed", other.String())
+	assert.Equal(t, 2, group.Len())
+	sender.AssertExpectations(t)
+}
+
+// flakySender fails the first messages.
+type flakySender struct {
+	failures int
+	sent     int
+}
+
+func (s *flakySender) Send(*entity.Message) error {
+	if s.sent++; s.sent <= s.failures {
+		return errors.New("error")
+	}
+
+	return nil
+}
+
+func (s *flakySender) Request(*entity.Message) (*entity.Message, error) {
+	return nil, s.Send(nil)
+}
+
+func TestGuardSendOnWait(t *testing.T) {
+	sender := &flakySender{failures: 2}
+
+	snd, err := NewGroup().Sender(&Config{Threshold: 2, Duration: 1}, "http://svc/path", sender, true)
+
+	assert.Nil(t, err)
+
+	assert.Error(t, snd.Send(entity.NewMessage([]byte("some-data"))))
+
+	start := time.Now()
+
+	// The message opened the breaker, it is held and sent again as a probe.
+	assert.Nil(t, snd.Send(entity.NewMessage([]byte("some-data"))))
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(time.Second))
+	assert.Equal(t, 3, sender.sent)
+	assert.Equal(t, "closed", snd.String())
+}
+
+func TestGuardSendOnWaitCanceled(t *testing.T) {
+	sender := &flakySender{failures: 1}
+
+	group := NewGroup()
+
+	snd, err := group.Sender(&Config{Threshold: 1, Duration: 30}, "http://svc/path", sender, true)
+
+	assert.Nil(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+
+	// The held message gives up when its context is done.
+	err = snd.Send(entity.NewMessage([]byte("some-data")).WithContext(ctx))
+
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	// Waiting messages are released on shutdown.
+	group.Close()
+
+	err = snd.Send(entity.NewMessage([]byte("some-data")))
+
+	assert.True(t, errors.Is(err, errtpl.ErrServiceUnavailable))
+	assert.Equal(t, 1, sender.sent)
+}
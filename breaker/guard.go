@@ -0,0 +1,230 @@
+package breaker
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"NATter/driver"
+	"NATter/entity"
+	"NATter/errtpl"
+	"NATter/log"
+	"NATter/msgtpl"
+
+	"github.com/pkg/errors"
+)
+
+// Group holds breakers of endpoint hosts, routes sending to the same host share its breaker
+// configured by the first of them.
+type Group struct {
+	mx       sync.Mutex
+	breakers map[string]*Breaker
+	done     chan struct{}
+}
+
+func NewGroup() *Group {
+	return &Group{
+		breakers: map[string]*Breaker{},
+		done:     make(chan struct{}),
+	}
+}
+
+// Len returns the number of hosts that have breakers.
+func (g *Group) Len() int {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	return len(g.breakers)
+}
+
+// Close releases messages waiting for breakers of the group, they fail with errtpl.ErrServiceUnavailable.
+func (g *Group) Close() {
+	close(g.done)
+}
+
+// Sender returns the sender guarded by the breaker of the host the message is sent to, the host
+// is taken from the rendered endpoint. Messages to the open breaker either wait until it lets them
+// through or are rejected with errtpl.ErrServiceUnavailable.
+func (g *Group) Sender(cfg *Config, endpoint string, sender driver.Sender, wait bool) (*Guard, error) {
+	if _, err := New(cfg); err != nil {
+		return nil, err
+	}
+
+	grd := &Guard{
+		group:    g,
+		cfg:      *cfg,
+		endpoint: msgtpl.NewEndpoint(endpoint),
+		sender:   sender,
+		wait:     wait,
+		hosts:    map[string]*Breaker{},
+	}
+
+	// The breaker of a static endpoint is shown in stats from the start.
+	if !msgtpl.IsTemplate(endpoint) {
+		if _, err := grd.breaker(entity.NewMessage(nil)); err != nil {
+			return nil, err
+		}
+	}
+
+	return grd, nil
+}
+
+func (g *Group) breaker(host string, cfg Config) *Breaker {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	if brk, ok := g.breakers[host]; ok {
+		return brk
+	}
+
+	cfg.Host = host
+
+	// The config is checked by Sender.
+	brk, _ := New(&cfg)
+
+	g.breakers[host] = brk
+
+	return brk
+}
+
+// Guard sends messages of a route through breakers of the hosts of its endpoint.
+type Guard struct {
+	group    *Group
+	cfg      Config
+	endpoint *msgtpl.Template
+	sender   driver.Sender
+	wait     bool
+
+	mx    sync.Mutex
+	hosts map[string]*Breaker
+}
+
+// String returns the worst state of the breakers the route sent through.
+func (g *Guard) String() string {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	res := StateClosed
+
+	for _, brk := range g.hosts {
+		switch State(brk.String()) {
+		case StateOpen:
+			return string(StateOpen)
+		case StateHalfOpen:
+			res = StateHalfOpen
+		}
+	}
+
+	return string(res)
+}
+
+func (g *Guard) Send(msg *entity.Message) error {
+	_, err := g.do(msg, func() (*entity.Message, error) {
+		return nil, g.sender.Send(msg)
+	})
+
+	return err
+}
+
+func (g *Guard) Request(msg *entity.Message) (*entity.Message, error) {
+	return g.do(msg, func() (*entity.Message, error) {
+		return g.sender.Request(msg)
+	})
+}
+
+// breaker returns the breaker of the host the message is sent to.
+func (g *Guard) breaker(msg *entity.Message) (*Breaker, error) {
+	endpoint, err := g.endpoint.Execute(msg)
+
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := url.Parse(endpoint)
+
+	if err != nil {
+		return nil, errors.Wrapf(errtpl.ErrBadRequest, "invalid endpoint '%s'", endpoint)
+	}
+
+	brk := g.group.breaker(u.Host, g.cfg)
+
+	g.mx.Lock()
+	g.hosts[u.Host] = brk
+	g.mx.Unlock()
+
+	return brk, nil
+}
+
+// do sends the message through the breaker, in the wait mode a message failed because of the open breaker
+// is sent again when the breaker lets it through. It stops waiting when the message context is done
+// or the group is closed.
+func (g *Guard) do(msg *entity.Message, send func() (*entity.Message, error)) (*entity.Message, error) {
+	brk, err := g.breaker(msg)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		token, wait, ok := brk.allow()
+
+		if !ok {
+			if !g.wait {
+				return nil, errors.Wrapf(errtpl.ErrServiceUnavailable, "circuit breaker of %s is open", brk.host)
+			}
+
+			if err := g.sleep(msg.Context(), wait); err != nil {
+				return nil, errors.Wrapf(err, "circuit breaker of %s is open", brk.host)
+			}
+
+			continue
+		}
+
+		resp, err := send()
+
+		// The message is given up by its sender, it tells nothing about the host.
+		if errors.Is(err, context.Canceled) {
+			brk.release(token)
+
+			return resp, err
+		}
+
+		failed := isFailure(err)
+
+		if open := brk.record(token, failed); !failed || !open || !g.wait {
+			return resp, err
+		}
+
+		log.WithFields(log.Fields{
+			log.FieldEndpoint: brk.host,
+		}).WithError(err).Warn("message is held until circuit breaker is closed")
+	}
+}
+
+func (g *Guard) sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-g.group.done:
+		return errtpl.ErrServiceUnavailable
+	}
+}
+
+// isFailure reports whether the error means the host is unhealthy: a transport error, a timeout,
+// a 5xx or 429 response. The host has handled a request with any other 4xx response.
+func isFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	code := errtpl.Status(err)
+
+	return code >= http.StatusInternalServerError || code == http.StatusTooManyRequests
+}
@@ -8,12 +8,19 @@ import (
 	"path/filepath"
 	"sync"
 	"syscall"
+	"time"
 
+	"NATter/compression"
 	"NATter/config"
 	"NATter/driver"
 	"NATter/driver/http"
+	"NATter/driver/msgbroker/amqp"
 	"NATter/driver/msgbroker/kafka"
+	"NATter/driver/msgbroker/mqtt"
 	"NATter/driver/msgbroker/nats"
+	"NATter/driver/msgbroker/redis"
+	"NATter/driver/ws"
+	"NATter/entity"
 	"NATter/log"
 	"NATter/service"
 
@@ -21,7 +28,11 @@ import (
 	"github.com/spf13/pflag"
 )
 
-const brokerDriverName = "broker"
+const (
+	validateCommand = "validate"
+
+	megabyte = 1 << 20
+)
 
 type NATter struct {
 	wg sync.WaitGroup
@@ -54,12 +65,21 @@ func (natter *NATter) Run() error {
 		return err
 	}
 
-	if err := natter.connect(); err != nil {
+	if pflag.Arg(0) == validateCommand {
+		return natter.validate(path)
+	}
+
+	if err := config.Validate(); err != nil {
 		return err
 	}
 
+	// Conns connected before a failed one and the log file are closed as well.
 	defer natter.close()
 
+	if err := natter.connect(); err != nil {
+		return err
+	}
+
 	if err := natter.start(); err != nil {
 		return err
 	}
@@ -69,6 +89,18 @@ func (natter *NATter) Run() error {
 	return nil
 }
 
+func (natter *NATter) validate(path string) error {
+	if err := config.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+
+		return errors.Errorf("%s is invalid", path)
+	}
+
+	fmt.Printf("%s is valid\n", path)
+
+	return nil
+}
+
 func (natter *NATter) specifyRelativeEnvPath(path string) string {
 	if !filepath.IsAbs(path) {
 		return fmt.Sprintf(".%ccfg%c%s", os.PathSeparator, os.PathSeparator, path)
@@ -91,17 +123,32 @@ func (natter *NATter) close() {
 			log.Error(err)
 		}
 	}
+
+	// The log file is closed last so that errors of closing conns are written to it.
+	if err := log.Close(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
 }
 
 func (natter *NATter) setupLogger() error {
 	err := log.SetConfig(&log.Config{
-		Level: config.String("LOG.LOGGER_LEVEL"),
+		Level:  config.String("LOG.LOGGER_LEVEL"),
+		Format: config.String("LOG.FORMAT"),
 		Stream: log.ConfigStream{
 			Enable: config.Bool("LOG.STREAMLOG_ENABLE"),
 		},
 		File: log.ConfigFile{
-			Enable: config.Bool("LOG.FILELOG_ENABLE"),
-			Path:   config.String("LOG.FILELOG_PATH"),
+			Enable:         config.Bool("LOG.FILELOG_ENABLE"),
+			Path:           config.String("LOG.FILELOG_PATH"),
+			MaxSize:        int64(config.Int("LOG.FILELOG_MAX_SIZE")) * megabyte,
+			RotateInterval: config.Duration("LOG.FILELOG_ROTATE_INTERVAL"),
+			MaxFiles:       config.Int("LOG.FILELOG_MAX_FILES"),
+			Compress:       config.Bool("LOG.FILELOG_COMPRESS"),
+		},
+		Payload: log.ConfigPayload{
+			Enable:    config.Bool("LOG.PAYLOAD_ENABLE"),
+			MaxLength: config.Int("LOG.PAYLOAD_MAX_LENGTH"),
+			Redact:    config.StringSlice("LOG.PAYLOAD_REDACT"),
 		},
 		Syslog: log.ConfigSyslog{
 			Name:   "NATter",
@@ -114,18 +161,117 @@ func (natter *NATter) setupLogger() error {
 }
 
 func (natter *NATter) setupConns() error {
+	compression.SetMaxSize(int64(config.Int("COMPRESSION.MAX_SIZE")) * megabyte)
+
 	conn, err := natter.bootBroker()
 
 	if err != nil {
 		return err
 	}
 
-	natter.conns[brokerDriverName] = conn
-
-	natter.conns[http.DriverName] = http.NewConn(&http.ConnConfig{
-		Port: config.String("HTTP.PORT"),
+	natter.conns[entity.DriverBroker] = conn
+
+	httpConn, err := http.NewConn(&http.ConnConfig{
+		Port:              config.String("HTTP.PORT"),
+		Timeout:           seconds("HTTP.TIMEOUT"),
+		ReadTimeout:       seconds("HTTP.READ_TIMEOUT"),
+		ReadHeaderTimeout: seconds("HTTP.READ_HEADER_TIMEOUT"),
+		WriteTimeout:      seconds("HTTP.WRITE_TIMEOUT"),
+		IdleTimeout:       seconds("HTTP.IDLE_TIMEOUT"),
+		RequestRetention:  seconds("HTTP.REQUEST_RETENTION"),
+		CallbackURLs:      config.StringSlice("HTTP.CALLBACK_URLS"),
+		Client: &http.ClientConfig{
+			MaxIdleConns:        config.Int("HTTP.CLIENT.MAX_IDLE_CONNS"),
+			MaxIdleConnsPerHost: config.Int("HTTP.CLIENT.MAX_IDLE_CONNS_PER_HOST"),
+			MaxConnsPerHost:     config.Int("HTTP.CLIENT.MAX_CONNS_PER_HOST"),
+			IdleConnTimeout:     seconds("HTTP.CLIENT.IDLE_CONN_TIMEOUT"),
+			DisableHTTP2:        config.Bool("HTTP.CLIENT.DISABLE_HTTP2"),
+			ProxyURL:            config.String("HTTP.CLIENT.PROXY_URL"),
+			DNSCacheTTL:         seconds("HTTP.CLIENT.DNS_CACHE_TTL"),
+		},
+		SSE: &http.SSEConfig{
+			Replay:    config.Int("HTTP.SSE.REPLAY"),
+			Heartbeat: seconds("HTTP.SSE.HEARTBEAT"),
+			Buffer:    config.Int("HTTP.SSE.BUFFER"),
+		},
 	})
 
+	if err != nil {
+		return errors.Wrap(err, "http")
+	}
+
+	natter.conns[http.DriverName] = httpConn
+	natter.conns[http.SSEDriverName] = http.NewSSEConn(httpConn)
+
+	// The WebSocket server is started besides the HTTP one only if it is configured.
+	if port := config.String("WS.PORT"); port != "" {
+		natter.conns[ws.DriverName] = ws.NewConn(&ws.ConnConfig{
+			Host:           config.String("WS.HOST"),
+			Port:           port,
+			Tokens:         config.StringSlice("WS.TOKENS"),
+			AuthEndpoint:   config.String("WS.AUTH_ENDPOINT"),
+			AllowedOrigins: config.StringSlice("WS.ALLOWED_ORIGINS"),
+			PingInterval:   seconds("WS.PING_INTERVAL"),
+			Buffer:         config.Int("WS.BUFFER"),
+		})
+	}
+
+	// MQTT is connected besides the message broker only if it is configured.
+	if servers := config.StringSlice("MQTT.SERVERS"); len(servers) != 0 {
+		mqttConn, err := mqtt.NewConn(&mqtt.ConnConfig{
+			Servers:      servers,
+			ClientID:     config.String("MQTT.CLIENT_ID"),
+			Username:     config.String("MQTT.USERNAME"),
+			Password:     config.String("MQTT.PASSWORD"),
+			QoS:          byte(config.Int("MQTT.QOS")),
+			CleanSession: config.String("MQTT.CLEAN_SESSION") == "" || config.Bool("MQTT.CLEAN_SESSION"),
+			ShareGroup:   config.String("MQTT.SHARE_GROUP"),
+			Timeout:      seconds("MQTT.TIMEOUT"),
+		})
+
+		if err != nil {
+			return err
+		}
+
+		natter.conns[mqtt.DriverName] = mqttConn
+	}
+
+	// AMQP is connected besides the message broker only if it is configured.
+	if url := config.String("AMQP.URL"); url != "" {
+		amqpConn, err := amqp.NewConn(&amqp.ConnConfig{
+			URL:            url,
+			Prefetch:       config.Int("AMQP.PREFETCH"),
+			RequestTimeout: seconds("AMQP.REQUEST_TIMEOUT"),
+		})
+
+		if err != nil {
+			return err
+		}
+
+		natter.conns[amqp.DriverName] = amqpConn
+	}
+
+	// Redis is connected besides the message broker only if it is configured.
+	if addr := config.String("REDIS.ADDR"); addr != "" {
+		redisConn, err := redis.NewConn(&redis.ConnConfig{
+			Addr:          addr,
+			Username:      config.String("REDIS.USERNAME"),
+			Password:      config.String("REDIS.PASSWORD"),
+			DB:            config.Int("REDIS.DB"),
+			Group:         config.String("REDIS.GROUP"),
+			Consumer:      config.String("REDIS.CONSUMER"),
+			ReadCount:     config.Int("REDIS.READ_COUNT"),
+			RetryInterval: seconds("REDIS.RETRY_INTERVAL"),
+			ClaimIdle:     seconds("REDIS.CLAIM_IDLE"),
+		})
+
+		if err != nil {
+			return err
+		}
+
+		natter.conns[redis.DriverName] = redisConn
+	}
+
 	return nil
 }
 
@@ -135,10 +281,11 @@ func (natter *NATter) bootBroker() (conn driver.Conn, err error) {
 	switch broker {
 	case nats.DriverName:
 		conn, err = nats.NewConn(&nats.ConnConfig{
-			Servers: config.StringSlice("MESSAGE_BROKER.NATS_SERVERS"),
-			Token:   config.String("MESSAGE_BROKER.NATS_TOKEN"),
-			Group:   config.String("MESSAGE_BROKER.SERVICE_GROUP"),
-			Name:    config.String("MESSAGE_BROKER.SERVICE_NAME"),
+			Servers:        config.StringSlice("MESSAGE_BROKER.NATS_SERVERS"),
+			Token:          config.String("MESSAGE_BROKER.NATS_TOKEN"),
+			Group:          config.String("MESSAGE_BROKER.SERVICE_GROUP"),
+			Name:           config.String("MESSAGE_BROKER.SERVICE_NAME"),
+			RequestTimeout: seconds("MESSAGE_BROKER.REQUEST_TIMEOUT"),
 		})
 	case kafka.DriverName:
 		conn, err = kafka.NewConn(&kafka.ConnConfig{
@@ -153,6 +300,11 @@ func (natter *NATter) bootBroker() (conn driver.Conn, err error) {
 	return conn, err
 }
 
+// seconds returns the config option given in seconds as duration.
+func seconds(name string) time.Duration {
+	return time.Duration(config.Int(name)) * time.Second
+}
+
 func (natter *NATter) start() (err error) {
 	log.Info("Starting NATter")
 
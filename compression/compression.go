@@ -0,0 +1,83 @@
+package compression
+
+import (
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultMaxSize is the limit of a decompressed payload unless SetMaxSize changes it.
+const DefaultMaxSize = 64 << 20
+
+var (
+	ErrUnknownEncoding = errors.New("unknown content encoding")
+	ErrTooLarge        = errors.New("decompressed payload is too large")
+)
+
+var maxSize int64 = DefaultMaxSize
+
+// SetMaxSize limits the size of decompressed payloads, so a small compressed payload
+// can not expand to exhaust the memory. A non-positive size sets the default.
+// It is called on start before any payload is decompressed.
+func SetMaxSize(size int64) {
+	if size <= 0 {
+		size = DefaultMaxSize
+	}
+
+	atomic.StoreInt64(&maxSize, size)
+	resetZstdDecoder()
+}
+
+// MaxSize returns the limit of decompressed payloads.
+func MaxSize() int64 {
+	return atomic.LoadInt64(&maxSize)
+}
+
+type Compressor interface {
+	Encoding() string
+	Compress(payload []byte) ([]byte, error)
+	Decompress(payload []byte) ([]byte, error)
+}
+
+func New(encoding string) (Compressor, error) {
+	switch encoding {
+	case "": // no compression
+		return nil, nil
+	case EncodingGzip:
+		return &Gzip{}, nil
+	case EncodingZstd:
+		return &Zstd{}, nil
+	case EncodingSnappy:
+		return &Snappy{}, nil
+	default:
+		return nil, errors.Wrap(ErrUnknownEncoding, encoding)
+	}
+}
+
+func Compress(encoding string, payload []byte) ([]byte, error) {
+	comp, err := New(encoding)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if comp == nil {
+		return payload, nil
+	}
+
+	return comp.Compress(payload)
+}
+
+func Decompress(encoding string, payload []byte) ([]byte, error) {
+	if encoding == "" || encoding == EncodingIdentity {
+		return payload, nil
+	}
+
+	comp, err := New(encoding)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return comp.Decompress(payload)
+}
@@ -0,0 +1,116 @@
+package compression
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	inputs := []struct {
+		encoding string
+		comp     Compressor
+	}{
+		{encoding: "", comp: nil},
+		{encoding: "gzip", comp: &Gzip{}},
+		{encoding: "zstd", comp: &Zstd{}},
+		{encoding: "snappy", comp: &Snappy{}},
+	}
+
+	for i, input := range inputs {
+		comp, err := New(input.encoding)
+
+		assert.Nilf(t, err, "case %d", i+1)
+		assert.Equalf(t, input.comp, comp, "case %d", i+1)
+	}
+}
+
+func TestNewOnUnknownEncoding(t *testing.T) {
+	comp, err := New("deflate")
+
+	assert.ErrorIs(t, err, ErrUnknownEncoding)
+	assert.Nil(t, comp)
+}
+
+func TestCompressorRoundTrip(t *testing.T) {
+	for _, comp := range []Compressor{&Gzip{}, &Zstd{}, &Snappy{}} {
+		compressed, err := Compress(comp.Encoding(), []byte("some-data"))
+
+		assert.Nil(t, err, comp.Encoding())
+		assert.NotEqual(t, []byte("some-data"), compressed, comp.Encoding())
+
+		res, err := Decompress(comp.Encoding(), compressed)
+
+		assert.Nil(t, err, comp.Encoding())
+		assert.Equal(t, []byte("some-data"), res, comp.Encoding())
+	}
+}
+
+func TestCompressOnEmptyEncoding(t *testing.T) {
+	res, err := Compress("", []byte("some-data"))
+
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("some-data"), res)
+}
+
+func TestCompressOnUnknownEncoding(t *testing.T) {
+	res, err := Compress("deflate", []byte("some-data"))
+
+	assert.ErrorIs(t, err, ErrUnknownEncoding)
+	assert.Nil(t, res)
+}
+
+func TestDecompressOnIdentity(t *testing.T) {
+	for _, encoding := range []string{"", "identity"} {
+		res, err := Decompress(encoding, []byte("some-data"))
+
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("some-data"), res)
+	}
+}
+
+func TestDecompressOnUnknownEncoding(t *testing.T) {
+	res, err := Decompress("deflate", []byte("some-data"))
+
+	assert.ErrorIs(t, err, ErrUnknownEncoding)
+	assert.Nil(t, res)
+}
+
+func TestDecompressOnBrokenData(t *testing.T) {
+	for _, comp := range []Compressor{&Gzip{}, &Zstd{}, &Snappy{}} {
+		res, err := comp.Decompress([]byte("broken-data"))
+
+		assert.Error(t, err, comp.Encoding())
+		assert.Nil(t, res, comp.Encoding())
+	}
+}
+
+func TestDecompressOnMaxSize(t *testing.T) {
+	// The limit is over the default zstd window.
+	SetMaxSize(16 << 20)
+	defer SetMaxSize(0)
+
+	for _, comp := range []Compressor{&Gzip{}, &Zstd{}, &Snappy{}} {
+		bomb, err := comp.Compress(make([]byte, 32<<20))
+		assert.Nil(t, err, comp.Encoding())
+
+		res, err := comp.Decompress(bomb)
+		assert.ErrorIs(t, err, ErrTooLarge, comp.Encoding())
+		assert.Nil(t, res, comp.Encoding())
+
+		allowed, err := comp.Compress(make([]byte, 1<<20))
+		assert.Nil(t, err, comp.Encoding())
+
+		res, err = comp.Decompress(allowed)
+		assert.Nil(t, err, comp.Encoding())
+		assert.Len(t, res, 1<<20, comp.Encoding())
+	}
+}
+
+func TestSetMaxSize(t *testing.T) {
+	SetMaxSize(1024)
+	assert.Equal(t, int64(1024), MaxSize())
+
+	SetMaxSize(0)
+	assert.Equal(t, int64(DefaultMaxSize), MaxSize())
+}
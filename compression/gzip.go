@@ -0,0 +1,64 @@
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+)
+
+const (
+	EncodingIdentity = "identity"
+	EncodingGzip     = "gzip"
+)
+
+type Gzip struct {
+}
+
+func (c *Gzip) Encoding() string {
+	return EncodingGzip
+}
+
+func (c *Gzip) Compress(payload []byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w := gzip.NewWriter(buf)
+
+	if _, err := w.Write(payload); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (c *Gzip) Decompress(payload []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(payload))
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close()
+
+	return readLimited(r)
+}
+
+// readLimited reads the decompressed payload until it exceeds the max size.
+func readLimited(r io.Reader) ([]byte, error) {
+	limit := MaxSize()
+
+	res, err := ioutil.ReadAll(io.LimitReader(r, limit+1))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(res)) > limit {
+		return nil, ErrTooLarge
+	}
+
+	return res, nil
+}
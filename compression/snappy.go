@@ -0,0 +1,32 @@
+package compression
+
+import (
+	"github.com/golang/snappy"
+)
+
+const EncodingSnappy = "snappy"
+
+type Snappy struct {
+}
+
+func (c *Snappy) Encoding() string {
+	return EncodingSnappy
+}
+
+func (c *Snappy) Compress(payload []byte) ([]byte, error) {
+	return snappy.Encode(nil, payload), nil
+}
+
+func (c *Snappy) Decompress(payload []byte) ([]byte, error) {
+	size, err := snappy.DecodedLen(payload)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(size) > MaxSize() {
+		return nil, ErrTooLarge
+	}
+
+	return snappy.Decode(nil, payload)
+}
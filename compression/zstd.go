@@ -0,0 +1,90 @@
+package compression
+
+import (
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const EncodingZstd = "zstd"
+
+var (
+	zstdEncoder     *zstd.Encoder
+	zstdEncoderErr  error
+	zstdEncoderOnce = &sync.Once{}
+
+	zstdMx      = &sync.Mutex{}
+	zstdDecoder *zstd.Decoder // limited by the max size, it is recreated when the size changes
+)
+
+type Zstd struct {
+}
+
+func (c *Zstd) Encoding() string {
+	return EncodingZstd
+}
+
+// Compress uses the shared encoder, EncodeAll may be called concurrently.
+func (c *Zstd) Compress(payload []byte) ([]byte, error) {
+	zstdEncoderOnce.Do(func() {
+		zstdEncoder, zstdEncoderErr = zstd.NewWriter(nil)
+	})
+
+	if zstdEncoderErr != nil {
+		return nil, zstdEncoderErr
+	}
+
+	return zstdEncoder.EncodeAll(payload, nil), nil
+}
+
+// Decompress uses the shared decoder, DecodeAll may be called concurrently.
+func (c *Zstd) Decompress(payload []byte) ([]byte, error) {
+	r, err := sharedZstdDecoder()
+
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := r.DecodeAll(payload, nil)
+
+	switch {
+	case err == zstd.ErrDecoderSizeExceeded, err == zstd.ErrFrameSizeExceeded, err == zstd.ErrWindowSizeExceeded:
+		// A frame whose window exceeds the max size is rejected as well.
+		return nil, ErrTooLarge
+	case err == nil && int64(len(res)) > MaxSize():
+		return nil, ErrTooLarge
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func sharedZstdDecoder() (*zstd.Decoder, error) {
+	zstdMx.Lock()
+	defer zstdMx.Unlock()
+
+	if zstdDecoder == nil {
+		r, err := zstd.NewReader(nil, zstd.WithDecoderMaxMemory(uint64(MaxSize())))
+
+		if err != nil {
+			return nil, err
+		}
+
+		zstdDecoder = r
+	}
+
+	return zstdDecoder, nil
+}
+
+func resetZstdDecoder() {
+	zstdMx.Lock()
+	defer zstdMx.Unlock()
+
+	if zstdDecoder != nil {
+		zstdDecoder.Close()
+		zstdDecoder = nil
+	}
+}
@@ -1,23 +1,68 @@
 package config
 
 import (
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/spf13/cast"
 	"github.com/spf13/viper"
 )
 
+const envPrefix = "NATTER"
+
+// ${VAR} or ${VAR:-default}
+var envVarPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
 func Load(env string) error {
 	viper.SetConfigFile(env)
 
+	setupEnv()
+
 	return viper.ReadInConfig()
 }
 
+func setupEnv() {
+	viper.SetEnvPrefix(envPrefix)
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	viper.AutomaticEnv()
+}
+
 func String(name string) string {
-	return viper.GetString(name)
+	return interpolate(viper.GetString(name))
 }
 
 func StringSlice(name string) []string {
-	return viper.GetStringSlice(name)
+	values := viper.GetStringSlice(name)
+
+	for i, v := range values {
+		values[i] = interpolate(v)
+	}
+
+	return values
 }
 
 func Bool(name string) bool {
-	return viper.GetBool(name)
+	return cast.ToBool(String(name))
+}
+
+func Int(name string) int {
+	return cast.ToInt(String(name))
+}
+
+func Duration(name string) time.Duration {
+	return cast.ToDuration(String(name))
+}
+
+func interpolate(value string) string {
+	return envVarPattern.ReplaceAllStringFunc(value, func(match string) string {
+		sub := envVarPattern.FindStringSubmatch(match)
+
+		if v, ok := os.LookupEnv(sub[1]); ok {
+			return v
+		}
+
+		return sub[3]
+	})
 }
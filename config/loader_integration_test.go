@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -30,4 +31,6 @@ func TestLoad(t *testing.T) {
 	assert.Equal(t, "hello", String("CONFIG_TEST_STRING"))
 	assert.Equal(t, true, Bool("CONFIG_TEST_BOOL"))
 	assert.Equal(t, []string{"value1", "value2"}, StringSlice("CONFIG_TEST_STRING_SLICE"))
+	assert.Equal(t, 5, Int("CONFIG_TEST_INT"))
+	assert.Equal(t, time.Second*5, Duration("CONFIG_TEST_DURATION"))
 }
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvOverride(t *testing.T) {
+	viper.Reset()
+	viper.SetConfigType("toml")
+
+	setupEnv()
+
+	err := viper.ReadConfig(strings.NewReader(`
+		[MESSAGE_BROKER]
+		NATS_TOKEN='file-token'
+		NATS_SERVERS=['nats://file:4222']
+		[LOG]
+		FILELOG_ENABLE=false
+	`))
+
+	assert.Nil(t, err)
+
+	os.Setenv("NATTER_MESSAGE_BROKER_NATS_TOKEN", "env-token")
+	os.Setenv("NATTER_MESSAGE_BROKER_NATS_SERVERS", "nats://env1:4222 nats://env2:4222")
+	os.Setenv("NATTER_LOG_FILELOG_ENABLE", "true")
+	os.Setenv("NATTER_LOG_FILELOG_MAX_FILES", "3")
+
+	defer os.Unsetenv("NATTER_MESSAGE_BROKER_NATS_TOKEN")
+	defer os.Unsetenv("NATTER_MESSAGE_BROKER_NATS_SERVERS")
+	defer os.Unsetenv("NATTER_LOG_FILELOG_ENABLE")
+	defer os.Unsetenv("NATTER_LOG_FILELOG_MAX_FILES")
+
+	assert.Equal(t, "env-token", String("MESSAGE_BROKER.NATS_TOKEN"))
+	assert.Equal(t, []string{"nats://env1:4222", "nats://env2:4222"}, StringSlice("MESSAGE_BROKER.NATS_SERVERS"))
+	assert.Equal(t, true, Bool("LOG.FILELOG_ENABLE"))
+	assert.Equal(t, 3, Int("LOG.FILELOG_MAX_FILES"))
+
+	viper.Reset()
+}
+
+func TestInterpolation(t *testing.T) {
+	viper.Reset()
+	viper.SetConfigType("toml")
+
+	err := viper.ReadConfig(strings.NewReader(`
+		[MESSAGE_BROKER]
+		NATS_TOKEN='${TEST_NATS_TOKEN}'
+		NATS_SERVERS=['nats://${TEST_NATS_HOST}:4222', 'nats://${TEST_UNSET:-localhost}:4223']
+		[LOG]
+		STREAMLOG_ENABLE='${TEST_STREAMLOG_ENABLE}'
+		FILELOG_ROTATE_INTERVAL='${TEST_UNSET:-1h}'
+	`))
+
+	assert.Nil(t, err)
+
+	os.Setenv("TEST_NATS_TOKEN", "secret")
+	os.Setenv("TEST_NATS_HOST", "nats")
+	os.Setenv("TEST_STREAMLOG_ENABLE", "true")
+
+	defer os.Unsetenv("TEST_NATS_TOKEN")
+	defer os.Unsetenv("TEST_NATS_HOST")
+	defer os.Unsetenv("TEST_STREAMLOG_ENABLE")
+
+	assert.Equal(t, "secret", String("MESSAGE_BROKER.NATS_TOKEN"))
+	assert.Equal(t, []string{"nats://nats:4222", "nats://localhost:4223"}, StringSlice("MESSAGE_BROKER.NATS_SERVERS"))
+	assert.Equal(t, true, Bool("LOG.STREAMLOG_ENABLE"))
+	assert.Equal(t, time.Hour, Duration("LOG.FILELOG_ROTATE_INTERVAL"))
+
+	viper.Reset()
+}
+
+func TestInterpolate(t *testing.T) {
+	os.Setenv("TEST_VAR", "value")
+
+	defer os.Unsetenv("TEST_VAR")
+
+	inputs := []struct {
+		value string
+		res   string
+	}{
+		{value: "${TEST_VAR}", res: "value"},
+		{value: "prefix-${TEST_VAR}-suffix", res: "prefix-value-suffix"},
+		{value: "${TEST_UNSET}", res: ""},
+		{value: "${TEST_UNSET:-default}", res: "default"},
+		{value: "${TEST_VAR:-default}", res: "value"},
+		{value: "$TEST_VAR", res: "$TEST_VAR"},
+		{value: "pa$$word", res: "pa$$word"},
+	}
+
+	for i, input := range inputs {
+		assert.Equalf(t, input.res, interpolate(input.value), "case %d", i+1)
+	}
+}
@@ -1,20 +1,262 @@
 package config
 
 import (
+	"path/filepath"
+	"reflect"
+	"strings"
+
 	"NATter/entity"
 
 	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
 	"github.com/spf13/viper"
 )
 
-func Routes() (routes []*entity.Route, err error) {
-	err = viper.UnmarshalKey("ROUTES", &routes, func(ms *mapstructure.DecoderConfig) {
-		ms.TagName = "toml"
-	})
+const schemeSep = "://"
+
+var routeType = reflect.TypeOf(entity.Route{})
+
+// routeSource is a file that declares routes, the main config file or an included one.
+type routeSource struct {
+	file   string
+	routes []interface{}
+}
+
+func Routes() ([]*entity.Route, error) {
+	sources, err := routeSources()
 
 	if err != nil {
 		return nil, err
 	}
 
+	var routes []*entity.Route
+
+	for _, src := range sources {
+		decoded, err := decodeRoutes(src.routes)
+
+		if err != nil {
+			return nil, errors.Wrap(err, src.file)
+		}
+
+		routes = append(routes, decoded...)
+	}
+
 	return routes, nil
 }
+
+func routeSources() ([]*routeSource, error) {
+	sources := []*routeSource{{
+		file:   viper.ConfigFileUsed(),
+		routes: toSlice(viper.Get("ROUTES")),
+	}}
+
+	files, err := includes()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		v := viper.New()
+		v.SetConfigFile(file)
+
+		if err := v.ReadInConfig(); err != nil {
+			return nil, errors.Wrapf(err, "unable include %s", file)
+		}
+
+		sources = append(sources, &routeSource{
+			file:   file,
+			routes: toSlice(v.Get("ROUTES")),
+		})
+	}
+
+	return sources, nil
+}
+
+// includes returns files matched by INCLUDE patterns relative to the config file directory.
+func includes() ([]string, error) {
+	dir := filepath.Dir(viper.ConfigFileUsed())
+	files := []string{}
+
+	for _, pattern := range StringSlice("INCLUDE") {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(dir, pattern)
+		}
+
+		matches, err := filepath.Glob(pattern)
+
+		if err != nil {
+			return nil, errors.Wrap(err, pattern)
+		}
+
+		files = append(files, matches...)
+	}
+
+	return files, nil
+}
+
+func decodeRoutes(raw []interface{}) ([]*entity.Route, error) {
+	defaults := normalizeRoute(viper.Get("ROUTE_DEFAULTS"))
+	base := String("HTTP.BASE_URL")
+
+	routes := make([]*entity.Route, len(raw))
+
+	for i, item := range raw {
+		route := &entity.Route{}
+
+		if err := decodeRoute(merge(defaults, normalizeRoute(item)), route); err != nil {
+			return nil, err
+		}
+
+		route.Endpoint = resolveEndpoint(base, route.Endpoint)
+		resolveDescriptors(route)
+
+		routes[i] = route
+	}
+
+	return routes, nil
+}
+
+func decodeRoute(input map[string]interface{}, route *entity.Route) error {
+	dec, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		Result:           route,
+		TagName:          "toml",
+		WeaklyTypedInput: true,
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			interpolateHook,
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.StringToSliceHookFunc(","),
+		),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return dec.Decode(input)
+}
+
+func interpolateHook(from, _ reflect.Kind, data interface{}) (interface{}, error) {
+	if from != reflect.String {
+		return data, nil
+	}
+
+	return interpolate(data.(string)), nil
+}
+
+// resolveDescriptors makes relative paths of proto descriptors relative to the config file directory as INCLUDE.
+func resolveDescriptors(route *entity.Route) {
+	dir := filepath.Dir(viper.ConfigFileUsed())
+
+	for _, st := range route.Transform {
+		if st.Proto != nil && st.Proto.Descriptor != "" && !filepath.IsAbs(st.Proto.Descriptor) {
+			st.Proto.Descriptor = filepath.Join(dir, st.Proto.Descriptor)
+		}
+	}
+}
+
+// resolveEndpoint joins a relative endpoint with the base url.
+func resolveEndpoint(base, endpoint string) string {
+	if base == "" || endpoint == "" {
+		return endpoint
+	}
+
+	// The endpoint may be a template so it is not parsed as url.
+	if strings.Contains(endpoint, schemeSep) {
+		return endpoint
+	}
+
+	return strings.TrimSuffix(base, "/") + "/" + strings.TrimPrefix(endpoint, "/")
+}
+
+// merge returns the route values on top of the defaults, nested tables are merged too.
+func merge(defaults, route map[string]interface{}) map[string]interface{} {
+	res := make(map[string]interface{}, len(defaults)+len(route))
+
+	for k, v := range defaults {
+		res[k] = v
+	}
+
+	for k, v := range route {
+		def, dok := res[k].(map[string]interface{})
+		sub, sok := v.(map[string]interface{})
+
+		if dok && sok {
+			v = merge(def, sub)
+		}
+
+		res[k] = v
+	}
+
+	return res
+}
+
+// normalize upper-cases keys of route tables as file keys are case-insensitive,
+// keys of map fields such as RENAME are kept as is.
+func normalize(value interface{}, t reflect.Type) interface{} {
+	t = deref(t)
+
+	switch t.Kind() {
+	case reflect.Struct:
+		m, ok := value.(map[string]interface{})
+
+		if !ok {
+			return value
+		}
+
+		res := make(map[string]interface{}, len(m))
+
+		for k, v := range m {
+			key := strings.ToUpper(k)
+
+			if field, ok := fieldByTag(t, key); ok {
+				v = normalize(v, field.Type)
+			}
+
+			res[key] = v
+		}
+
+		return res
+	case reflect.Slice:
+		items := toSlice(value)
+
+		if items == nil {
+			return value
+		}
+
+		res := make([]interface{}, len(items))
+
+		for i, item := range items {
+			res[i] = normalize(item, t.Elem())
+		}
+
+		return res
+	default:
+		return value
+	}
+}
+
+func normalizeRoute(value interface{}) map[string]interface{} {
+	if m, ok := normalize(value, routeType).(map[string]interface{}); ok {
+		return m
+	}
+
+	return map[string]interface{}{}
+}
+
+func toSlice(value interface{}) []interface{} {
+	switch v := value.(type) {
+	case []interface{}:
+		return v
+	case []map[string]interface{}:
+		res := make([]interface{}, len(v))
+
+		for i, item := range v {
+			res[i] = item
+		}
+
+		return res
+	default:
+		return nil
+	}
+}
@@ -1,6 +1,9 @@
 package config
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -10,6 +13,38 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+func TestRoutesOnInterpolation(t *testing.T) {
+	viper.SetConfigType("toml")
+
+	err := viper.ReadConfig(strings.NewReader(`
+		[[ROUTES]]
+		MODE="broker-http-oneway"
+		TOPIC="${TEST_ROUTE_TOPIC}"
+		ENDPOINT="http://${TEST_ROUTE_HOST:-localhost}/path"
+		ASYNC="${TEST_ROUTE_ASYNC}"
+	`))
+
+	assert.Nil(t, err)
+
+	os.Setenv("TEST_ROUTE_TOPIC", "topic")
+	os.Setenv("TEST_ROUTE_ASYNC", "true")
+
+	defer os.Unsetenv("TEST_ROUTE_TOPIC")
+	defer os.Unsetenv("TEST_ROUTE_ASYNC")
+
+	res, err := Routes()
+
+	assert.Nil(t, err)
+	assert.Equal(t, []*entity.Route{
+		{
+			Mode:     entity.RouteMode("broker-http-oneway"),
+			Async:    true,
+			Topic:    "topic",
+			Endpoint: "http://localhost/path",
+		},
+	}, res)
+}
+
 func TestRoutes(t *testing.T) {
 	viper.SetConfigType("toml")
 
@@ -52,3 +87,142 @@ func TestRoutes(t *testing.T) {
 		},
 	}, res)
 }
+
+func TestRoutesOnDefaultsAndIncludes(t *testing.T) {
+	path := testLoadFile(t, `
+		INCLUDE=['routes/*.toml']
+
+		[MESSAGE_BROKER]
+		BROKER='nats'
+
+		[HTTP]
+		BASE_URL='http://localhost:8080/api/'
+
+		[ROUTE_DEFAULTS]
+		MODE="broker-http-oneway"
+		COMPRESSION="gzip"
+		[ROUTE_DEFAULTS.BATCHING]
+		TIMEOUT=30
+		CAPACITY=5
+
+		[[ROUTES]]
+		TOPIC="topic1"
+		ENDPOINT="/path1"
+		[ROUTES.BATCHING]
+		CAPACITY=10
+	`)
+
+	dir := filepath.Join(filepath.Dir(path), "routes")
+
+	assert.Nil(t, os.Mkdir(dir, 0700))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "team.toml"), []byte(`
+		[[ROUTES]]
+		MODE="http-broker-oneway"
+		TOPIC="topic2"
+		URI="/path2"
+
+		[[ROUTES]]
+		TOPIC="topic3"
+		ENDPOINT="https://example.com/path3"
+	`), 0600))
+
+	res, err := Routes()
+
+	assert.Nil(t, err)
+	assert.Equal(t, []*entity.Route{
+		{
+			Mode:        entity.RouteMode("broker-http-oneway"),
+			Topic:       "topic1",
+			Endpoint:    "http://localhost:8080/api/path1",
+			Compression: "gzip",
+			Batching: &entity.RouteBatching{
+				Timeout:  30,
+				Capacity: 10,
+			},
+		},
+		{
+			Mode:        entity.RouteMode("http-broker-oneway"),
+			Topic:       "topic2",
+			URI:         "/path2",
+			Compression: "gzip",
+			Batching: &entity.RouteBatching{
+				Timeout:  30,
+				Capacity: 5,
+			},
+		},
+		{
+			Mode:        entity.RouteMode("broker-http-oneway"),
+			Topic:       "topic3",
+			Endpoint:    "https://example.com/path3",
+			Compression: "gzip",
+			Batching: &entity.RouteBatching{
+				Timeout:  30,
+				Capacity: 5,
+			},
+		},
+	}, res)
+
+	assert.Nil(t, Validate())
+}
+
+func TestRoutesOnIncludeError(t *testing.T) {
+	path := testLoadFile(t, `INCLUDE=['*.broken']`)
+
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(filepath.Dir(path), "routes.broken"), []byte(`[[ROUTES]`), 0600))
+
+	_, err := Routes()
+
+	assert.NotNil(t, err)
+}
+
+func TestRoutesOnTransform(t *testing.T) {
+	viper.Reset()
+	viper.SetConfigType("toml")
+
+	err := viper.ReadConfig(strings.NewReader(`
+		[[ROUTES]]
+		MODE="http-broker-oneway"
+		TOPIC="topic"
+		URI="/path"
+		[[ROUTES.TRANSFORM]]
+		[ROUTES.TRANSFORM.RENAME]
+		createdAt="created_at"
+		[[ROUTES.TRANSFORM]]
+		ENVELOPE="data"
+	`))
+
+	assert.Nil(t, err)
+
+	res, err := Routes()
+
+	assert.Nil(t, err)
+	assert.Equal(t, []*entity.RouteTransform{
+		{Rename: map[string]string{"createdAt": "created_at"}},
+		{Envelope: "data"},
+	}, res[0].Transform)
+}
+
+func TestRoutesOnProtoDescriptor(t *testing.T) {
+	path := testLoadFile(t, `
+[[ROUTES]]
+MODE='http-broker-oneway'
+TOPIC='topic'
+URI='/path'
+[[ROUTES.TRANSFORM]]
+[ROUTES.TRANSFORM.PROTO]
+DESCRIPTOR='proto/orders.pb'
+MESSAGE='orders.Order'
+TO='proto'
+[[ROUTES.TRANSFORM]]
+[ROUTES.TRANSFORM.PROTO]
+DESCRIPTOR='/etc/natter/users.pb'
+MESSAGE='users.User'
+TO='json'
+`)
+
+	res, err := Routes()
+
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(filepath.Dir(path), "proto", "orders.pb"), res[0].Transform[0].Proto.Descriptor)
+	assert.Equal(t, "/etc/natter/users.pb", res[0].Transform[1].Proto.Descriptor)
+}
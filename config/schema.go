@@ -0,0 +1,137 @@
+package config
+
+import (
+	"time"
+
+	"NATter/entity"
+)
+
+// schema describes every key the configuration file may contain.
+// Only toml tags and field kinds are used to check the file layout.
+type schema struct {
+	Log struct {
+		LoggerLevel           string        `toml:"LOGGER_LEVEL"`
+		Format                string        `toml:"FORMAT"`
+		StreamlogEnable       bool          `toml:"STREAMLOG_ENABLE"`
+		FilelogEnable         bool          `toml:"FILELOG_ENABLE"`
+		FilelogPath           string        `toml:"FILELOG_PATH"`
+		FilelogMaxSize        int           `toml:"FILELOG_MAX_SIZE"`
+		FilelogRotateInterval time.Duration `toml:"FILELOG_ROTATE_INTERVAL"`
+		FilelogMaxFiles       int           `toml:"FILELOG_MAX_FILES"`
+		FilelogCompress       bool          `toml:"FILELOG_COMPRESS"`
+		SyslogEnable          bool          `toml:"SYSLOG_ENABLE"`
+		SyslogHost            string        `toml:"SYSLOG_HOST"`
+		PayloadEnable         bool          `toml:"PAYLOAD_ENABLE"`
+		PayloadMaxLength      int           `toml:"PAYLOAD_MAX_LENGTH"`
+		PayloadRedact         []string      `toml:"PAYLOAD_REDACT"`
+	} `toml:"LOG"`
+	MessageBroker struct {
+		Broker         string   `toml:"BROKER"`
+		NatsServers    []string `toml:"NATS_SERVERS"`
+		NatsToken      string   `toml:"NATS_TOKEN"`
+		KafkaVersion   string   `toml:"KAFKA_VERSION"`
+		KafkaServers   []string `toml:"KAFKA_SERVERS"`
+		ServiceGroup   string   `toml:"SERVICE_GROUP"`
+		ServiceName    string   `toml:"SERVICE_NAME"`
+		RequestTimeout int      `toml:"REQUEST_TIMEOUT"`
+	} `toml:"MESSAGE_BROKER"`
+	HTTP struct {
+		Host              string   `toml:"HOST"`
+		Port              string   `toml:"PORT"`
+		BaseURL           string   `toml:"BASE_URL"`
+		Timeout           int      `toml:"TIMEOUT"`
+		ReadTimeout       int      `toml:"READ_TIMEOUT"`
+		ReadHeaderTimeout int      `toml:"READ_HEADER_TIMEOUT"`
+		WriteTimeout      int      `toml:"WRITE_TIMEOUT"`
+		IdleTimeout       int      `toml:"IDLE_TIMEOUT"`
+		RequestRetention  int      `toml:"REQUEST_RETENTION"`
+		CallbackURLs      []string `toml:"CALLBACK_URLS"`
+		Client            struct {
+			MaxIdleConns        int    `toml:"MAX_IDLE_CONNS"`
+			MaxIdleConnsPerHost int    `toml:"MAX_IDLE_CONNS_PER_HOST"`
+			MaxConnsPerHost     int    `toml:"MAX_CONNS_PER_HOST"`
+			IdleConnTimeout     int    `toml:"IDLE_CONN_TIMEOUT"`
+			DisableHTTP2        bool   `toml:"DISABLE_HTTP2"`
+			ProxyURL            string `toml:"PROXY_URL"`
+			DNSCacheTTL         int    `toml:"DNS_CACHE_TTL"`
+		} `toml:"CLIENT"`
+		SSE struct {
+			Replay    int `toml:"REPLAY"`
+			Heartbeat int `toml:"HEARTBEAT"`
+			Buffer    int `toml:"BUFFER"`
+		} `toml:"SSE"`
+	} `toml:"HTTP"`
+	Compression struct {
+		MaxSize int `toml:"MAX_SIZE"`
+	} `toml:"COMPRESSION"`
+	WS struct {
+		Host           string   `toml:"HOST"`
+		Port           string   `toml:"PORT"`
+		Tokens         []string `toml:"TOKENS"`
+		AuthEndpoint   string   `toml:"AUTH_ENDPOINT"`
+		AllowedOrigins []string `toml:"ALLOWED_ORIGINS"`
+		PingInterval   int      `toml:"PING_INTERVAL"`
+		Buffer         int      `toml:"BUFFER"`
+	} `toml:"WS"`
+	MQTT struct {
+		Servers      []string `toml:"SERVERS"`
+		ClientID     string   `toml:"CLIENT_ID"`
+		Username     string   `toml:"USERNAME"`
+		Password     string   `toml:"PASSWORD"`
+		QoS          int      `toml:"QOS"`
+		CleanSession bool     `toml:"CLEAN_SESSION"`
+		ShareGroup   string   `toml:"SHARE_GROUP"`
+		Timeout      int      `toml:"TIMEOUT"`
+	} `toml:"MQTT"`
+	AMQP struct {
+		URL            string `toml:"URL"`
+		Prefetch       int    `toml:"PREFETCH"`
+		RequestTimeout int    `toml:"REQUEST_TIMEOUT"`
+	} `toml:"AMQP"`
+	Redis struct {
+		Addr          string `toml:"ADDR"`
+		Username      string `toml:"USERNAME"`
+		Password      string `toml:"PASSWORD"`
+		DB            int    `toml:"DB"`
+		Group         string `toml:"GROUP"`
+		Consumer      string `toml:"CONSUMER"`
+		ReadCount     int    `toml:"READ_COUNT"`
+		RetryInterval int    `toml:"RETRY_INTERVAL"`
+		ClaimIdle     int    `toml:"CLAIM_IDLE"`
+	} `toml:"REDIS"`
+	RouteDefaults entity.Route   `toml:"ROUTE_DEFAULTS"`
+	Include       []string       `toml:"INCLUDE"`
+	Routes        []entity.Route `toml:"ROUTES"`
+}
+
+// includeSchema describes keys of files included by INCLUDE.
+type includeSchema struct {
+	Routes []entity.Route `toml:"ROUTES"`
+}
+
+// routeDriver names route fields that a driver needs to receive and to send.
+type routeDriver struct {
+	receiver string
+	sender   string
+}
+
+// Broker that supports wildcard topics.
+const wildcardBroker = entity.BrokerNATS
+
+var routeDrivers = map[string]routeDriver{
+	entity.DriverHTTP:   {receiver: "URI", sender: "ENDPOINT"},
+	entity.DriverBroker: {receiver: "TOPIC", sender: "TOPIC"},
+	entity.DriverWS:     {receiver: "URI", sender: "URI"},
+	entity.DriverSSE:    {receiver: "URI", sender: "URI"},
+	entity.DriverMQTT:   {receiver: "MQTT_TOPIC", sender: "MQTT_TOPIC"},
+	entity.DriverAMQP:   {receiver: "AMQP_QUEUE", sender: "AMQP_ROUTING_KEY"},
+	// Either REDIS_CHANNEL or REDIS_STREAM is checked by validateRedis.
+	entity.DriverRedis: {},
+}
+
+var (
+	loggerLevels = []string{"trace", "debug", "info", "warn", "warning", "error", "fatal", "panic"}
+	logFormats   = []string{"text", "json", "logfmt"}
+	brokers      = []string{entity.BrokerNATS, entity.BrokerKafka}
+	mqttQoS      = []string{"0", "1"}
+)
@@ -0,0 +1,807 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"NATter/breaker"
+	"NATter/compression"
+	"NATter/entity"
+	"NATter/filter"
+	"NATter/msgtpl"
+	"NATter/ratelimit"
+	"NATter/transform"
+
+	"github.com/pelletier/go-toml"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+type FieldError struct {
+	File    string
+	Line    int
+	Col     int
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	if e.Line <= 0 {
+		return fmt.Sprintf("%s: %s", e.File, e.Message)
+	}
+
+	return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Col, e.Message)
+}
+
+type ValidationError struct {
+	Errors []*FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+
+	return "invalid config:\n" + strings.Join(msgs, "\n")
+}
+
+type validator struct {
+	file  string
+	files map[string]int // order the files are validated in
+	tree  *toml.Tree
+	errs  []*FieldError
+}
+
+// Validate checks the loaded configuration file against the schema
+// and reports every problem found with its position in the file.
+func Validate() error {
+	path := viper.ConfigFileUsed()
+
+	tree, err := toml.LoadFile(path)
+
+	if err != nil {
+		return err
+	}
+
+	v := &validator{file: path, files: map[string]int{}, tree: tree}
+
+	v.validateKeys(tree, reflect.TypeOf(schema{}), "")
+
+	v.validateOneOf("LOG.LOGGER_LEVEL", loggerLevels, false)
+	v.validateOneOf("LOG.FORMAT", logFormats, false)
+	v.validateOneOf("MESSAGE_BROKER.BROKER", brokers, true)
+	v.validateOneOf("MQTT.QOS", mqttQoS, false)
+	v.validateURLs("HTTP.CALLBACK_URLS")
+
+	// The broker keeps a persistent session by the client id.
+	if String("MQTT.CLEAN_SESSION") != "" && !Bool("MQTT.CLEAN_SESSION") && String("MQTT.CLIENT_ID") == "" {
+		v.errorf(position(v.tree, "MQTT.CLEAN_SESSION"), "MQTT.CLEAN_SESSION=false requires MQTT.CLIENT_ID")
+	}
+
+	if err := v.validateRoutes(); err != nil {
+		return err
+	}
+
+	if len(v.errs) == 0 {
+		return nil
+	}
+
+	// Errors of the main file go first, then the ones of included files.
+	sort.SliceStable(v.errs, func(i, j int) bool {
+		if v.errs[i].File != v.errs[j].File {
+			return v.files[v.errs[i].File] < v.files[v.errs[j].File]
+		}
+
+		if v.errs[i].Line != v.errs[j].Line {
+			return v.errs[i].Line < v.errs[j].Line
+		}
+
+		return v.errs[i].Col < v.errs[j].Col
+	})
+
+	return &ValidationError{Errors: v.errs}
+}
+
+func (v *validator) errorf(pos toml.Position, format string, args ...interface{}) {
+	if _, ok := v.files[v.file]; !ok {
+		v.files[v.file] = len(v.files)
+	}
+
+	v.errs = append(v.errs, &FieldError{
+		File:    v.file,
+		Line:    pos.Line,
+		Col:     pos.Col,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (v *validator) validateKeys(tree *toml.Tree, t reflect.Type, prefix string) {
+	for _, key := range tree.Keys() {
+		name := prefix + key
+		pos := tree.GetPositionPath([]string{key})
+
+		field, ok := fieldByTag(t, key)
+
+		if !ok {
+			v.errorf(pos, "unknown key '%s'", name)
+
+			continue
+		}
+
+		ft := deref(field.Type)
+
+		switch value := tree.GetPath([]string{key}).(type) {
+		case *toml.Tree:
+			if ft.Kind() == reflect.Map {
+				continue
+			}
+
+			if ft.Kind() != reflect.Struct {
+				v.errorf(pos, "key '%s' must not be a table", name)
+
+				continue
+			}
+
+			v.validateKeys(value, ft, name+".")
+		case []*toml.Tree:
+			if ft.Kind() != reflect.Slice || deref(ft.Elem()).Kind() != reflect.Struct {
+				v.errorf(pos, "key '%s' must not be an array of tables", name)
+
+				continue
+			}
+
+			for i, sub := range value {
+				v.validateKeys(sub, deref(ft.Elem()), fmt.Sprintf("%s[%d].", name, i))
+			}
+		default:
+			if ft.Kind() == reflect.Struct {
+				v.errorf(pos, "key '%s' must be a table", name)
+
+				continue
+			}
+
+			v.validateValue(pos, name, ft, value)
+		}
+	}
+}
+
+// validateValue checks that the value can be read as the type of the schema field. Strings are
+// read as other types after environment variables are substituted, an empty string is the zero value.
+func (v *validator) validateValue(pos toml.Position, name string, t reflect.Type, value interface{}) {
+	if t.Kind() == reflect.Slice {
+		items, ok := value.([]interface{})
+
+		if !ok {
+			if _, ok := value.(string); !ok {
+				v.errorf(pos, "invalid value of key '%s', expected an array", name)
+			}
+
+			return
+		}
+
+		for _, item := range items {
+			v.validateValue(pos, name, deref(t.Elem()), item)
+		}
+
+		return
+	}
+
+	if _, ok := value.([]interface{}); ok {
+		v.errorf(pos, "invalid value of key '%s', expected %s", name, typeName(t))
+
+		return
+	}
+
+	str, isStr := value.(string)
+
+	if isStr {
+		str = interpolate(str)
+	}
+
+	var ok bool
+
+	switch {
+	case t == durationType:
+		_, isInt := value.(int64)
+		ok = isInt || (isStr && (str == "" || validDuration(str)))
+	case t.Kind() == reflect.Bool:
+		_, isBool := value.(bool)
+		ok = isBool || (isStr && (str == "" || validBool(str)))
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Int64:
+		_, isInt := value.(int64)
+		ok = isInt || (isStr && (str == "" || validInt(str, false)))
+	case t.Kind() >= reflect.Uint && t.Kind() <= reflect.Uint64:
+		n, isInt := value.(int64)
+		ok = (isInt && n >= 0) || (isStr && (str == "" || validInt(str, true)))
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		switch value.(type) {
+		case int64, float64:
+			ok = true
+		default:
+			ok = isStr && (str == "" || validFloat(str))
+		}
+	default:
+		_, ok = value.(string)
+	}
+
+	if !ok {
+		v.errorf(pos, "invalid value '%v' of key '%s', expected %s", value, name, typeName(t))
+	}
+}
+
+func (v *validator) validateOneOf(key string, values []string, required bool) {
+	value := String(key)
+
+	if value == "" {
+		if required {
+			v.errorf(position(v.tree, key), "missing required key '%s'", key)
+		}
+
+		return
+	}
+
+	for _, allowed := range values {
+		if value == allowed {
+			return
+		}
+	}
+
+	v.errorf(position(v.tree, key), "invalid %s value '%s', possible values: %s",
+		key, value, strings.Join(values, ", "))
+}
+
+func (v *validator) validateURLs(key string) {
+	for _, value := range StringSlice(key) {
+		if u, err := url.Parse(value); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			v.errorf(position(v.tree, key), "invalid %s url '%s'", key, value)
+		}
+	}
+}
+
+func (v *validator) validateRoutes() error {
+	sources, err := routeSources()
+
+	if err != nil {
+		return err
+	}
+
+	conflicts := entity.NewRouteConflicts()
+
+	// Routes are registered in conflicts by their indexes in places.
+	var places []routePlace
+
+	for i, src := range sources {
+		tree := v.tree
+
+		if i > 0 {
+			if tree, err = toml.LoadFile(src.file); err != nil {
+				return err
+			}
+
+			v.file = src.file
+			v.validateKeys(tree, reflect.TypeOf(includeSchema{}), "")
+		}
+
+		routes, err := decodeRoutes(src.routes)
+
+		if err != nil {
+			// Values of wrong types are already reported with their positions.
+			if len(v.errs) != 0 {
+				continue
+			}
+
+			return errors.Wrap(err, src.file)
+		}
+
+		trees, _ := lookup(tree, "ROUTES").([]*toml.Tree)
+
+		for j, r := range routes {
+			var rt *toml.Tree
+
+			if j < len(trees) {
+				rt = trees[j]
+			}
+
+			v.validateRoute(rt, r)
+			v.validateConflict(rt, r, conflicts, len(places), places)
+
+			places = append(places, routePlace{file: v.file, tree: rt})
+		}
+	}
+
+	return nil
+}
+
+func (v *validator) validateRoute(tree *toml.Tree, r *entity.Route) {
+	if r.Mode == "" {
+		v.errorf(position(tree, "MODE"), "missing required key 'MODE'")
+
+		return
+	}
+
+	comp := r.Mode.Components()
+	pos := position(tree, "MODE")
+
+	receiver, ok := routeDrivers[comp.Receiver]
+
+	if !ok {
+		v.errorf(pos, "unknown receiver '%s' in mode '%s'", comp.Receiver, r.Mode)
+	} else {
+		v.require(tree, r, receiver.receiver)
+	}
+
+	sender, ok := routeDrivers[comp.Sender]
+
+	if !ok {
+		v.errorf(pos, "unknown sender '%s' in mode '%s'", comp.Sender, r.Mode)
+	} else {
+		v.require(tree, r, sender.sender)
+	}
+
+	switch comp.Direction {
+	case entity.RouteDirectionOneway, entity.RouteDirectionTwoway:
+	default:
+		v.errorf(pos, "unknown direction '%s' in mode '%s'", comp.Direction, r.Mode)
+	}
+
+	if r.Async && comp.Receiver == entity.DriverHTTP && comp.Direction == entity.RouteDirectionTwoway {
+		v.require(tree, r, "ENDPOINT")
+	}
+
+	if !r.Async && comp.Receiver == entity.DriverHTTP && comp.Direction == entity.RouteDirectionTwoway {
+		v.validateWriteTimeout(tree, r)
+	}
+
+	v.validateTemplate(tree, r, "TOPIC", r.Topic)
+	v.validateTemplate(tree, r, "ENDPOINT", r.Endpoint)
+	v.validateTemplate(tree, r, "MQTT_TOPIC", r.MQTTTopic)
+	v.validateTemplate(tree, r, "AMQP_ROUTING_KEY", r.AMQPRoutingKey)
+	v.validateTemplate(tree, r, "REDIS_CHANNEL", r.RedisChannel)
+	v.validateTemplate(tree, r, "REDIS_STREAM", r.RedisStream)
+	v.validateWildcards(tree, r)
+
+	if r.Endpoint != "" && !msgtpl.IsTemplate(r.Endpoint) {
+		if u, err := url.Parse(r.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			v.errorf(position(tree, "ENDPOINT"), "invalid ENDPOINT url '%s'", r.Endpoint)
+		}
+	}
+
+	if r.URI != "" && comp.Receiver == entity.DriverHTTP {
+		v.validateURI(tree, r.URI)
+	}
+
+	if comp.Receiver == entity.DriverWS || comp.Sender == entity.DriverWS {
+		v.validateWS(tree, r)
+	}
+
+	if comp.Receiver == entity.DriverSSE || comp.Sender == entity.DriverSSE {
+		v.validateSSE(tree, r)
+	}
+
+	if comp.Receiver == entity.DriverMQTT || comp.Sender == entity.DriverMQTT {
+		v.validateMQTT(tree, r)
+	}
+
+	if comp.Receiver == entity.DriverAMQP || comp.Sender == entity.DriverAMQP {
+		v.validateAMQP(tree, r)
+	}
+
+	if comp.Receiver == entity.DriverRedis || comp.Sender == entity.DriverRedis {
+		v.validateRedis(tree, r)
+	}
+
+	if r.RedisMaxLen != 0 && (comp.Sender != entity.DriverRedis || r.RedisStream == "") {
+		v.errorf(position(tree, "REDIS_MAXLEN"), "REDIS_MAXLEN can be used only to send to a redis stream")
+	}
+
+	if r.Retain && comp.Sender != entity.DriverMQTT {
+		v.errorf(position(tree, "RETAIN"), "RETAIN can be used only to send to mqtt")
+	}
+
+	if _, err := compression.New(r.Compression); err != nil {
+		v.errorf(position(tree, "COMPRESSION"), "unknown COMPRESSION '%s'", r.Compression)
+	}
+
+	// Replies of twoway routes are passed back as they are, a transformed request would get a reply of another format.
+	if len(r.Transform) != 0 && comp.Direction == entity.RouteDirectionTwoway {
+		v.errorf(position(tree, "TRANSFORM"), "TRANSFORM can be used only with oneway routes")
+	}
+
+	if _, err := transform.New(&transform.Config{Mode: r.Mode, Steps: r.Transform}, nil); err != nil {
+		v.errorf(position(tree, "TRANSFORM"), "TRANSFORM: %v", err)
+	}
+
+	if r.RateLimit != nil {
+		if _, err := ratelimit.New(&ratelimit.Config{
+			Rate:        r.RateLimit.Rate,
+			Burst:       r.RateLimit.Burst,
+			Concurrency: r.RateLimit.Concurrency,
+		}, nil); err != nil {
+			v.errorf(position(tree, "RATE_LIMIT"), "RATE_LIMIT: %v", err)
+		}
+	}
+
+	if r.Breaker != nil {
+		v.validateBreaker(tree, r)
+	}
+
+	if r.Filter != "" {
+		if err := filter.Validate(r.Filter); err != nil {
+			v.errorf(position(tree, "FILTER"), "FILTER: %v", err)
+		}
+	}
+}
+
+// validateWriteTimeout checks that the response of a twoway route received from HTTP
+// is written before the write timeout of the server cuts the connection.
+func (v *validator) validateWriteTimeout(tree *toml.Tree, r *entity.Route) {
+	writeTimeout := Int("HTTP.WRITE_TIMEOUT")
+	timeout := int(r.Timeout)
+
+	if timeout == 0 && r.Mode.Components().Sender == entity.DriverHTTP {
+		timeout = Int("HTTP.TIMEOUT")
+	}
+
+	if writeTimeout != 0 && timeout > writeTimeout {
+		v.errorf(position(tree, "TIMEOUT"), "route timeout %ds exceeds HTTP.WRITE_TIMEOUT %ds, the response would be cut off",
+			timeout, writeTimeout)
+	}
+}
+
+// validateWS checks a route receiving or sending through WebSocket clients,
+// routes may share the URI so the clients both receive and send messages.
+func (v *validator) validateWS(tree *toml.Tree, r *entity.Route) {
+	pos := position(tree, "MODE")
+
+	if r.Mode.Components().Direction == entity.RouteDirectionTwoway {
+		v.errorf(pos, "mode '%s' is not supported, ws routes are oneway", r.Mode)
+	}
+
+	if String("WS.PORT") == "" {
+		v.errorf(pos, "mode '%s' requires WS.PORT", r.Mode)
+	}
+
+	if _, err := url.ParseRequestURI(r.URI); r.URI != "" && err != nil {
+		v.errorf(position(tree, "URI"), "invalid URI '%s'", r.URI)
+	}
+}
+
+// validateMQTT checks a route receiving from or sending to the MQTT broker that is connected
+// besides the message broker.
+func (v *validator) validateMQTT(tree *toml.Tree, r *entity.Route) {
+	pos := position(tree, "MODE")
+
+	if r.Mode.Components().Direction == entity.RouteDirectionTwoway {
+		v.errorf(pos, "mode '%s' is not supported, mqtt routes are oneway", r.Mode)
+	}
+
+	if len(StringSlice("MQTT.SERVERS")) == 0 {
+		v.errorf(pos, "mode '%s' requires MQTT.SERVERS", r.Mode)
+	}
+}
+
+// validateAMQP checks a route receiving from or sending to the AMQP broker that is connected
+// besides the message broker.
+func (v *validator) validateAMQP(tree *toml.Tree, r *entity.Route) {
+	if String("AMQP.URL") == "" {
+		v.errorf(position(tree, "MODE"), "mode '%s' requires AMQP.URL", r.Mode)
+	}
+}
+
+// validateRedis checks a route receiving from or sending to Redis that is connected besides
+// the message broker. A route uses either a Pub/Sub channel or a stream.
+func (v *validator) validateRedis(tree *toml.Tree, r *entity.Route) {
+	pos := position(tree, "MODE")
+
+	if r.Mode.Components().Direction == entity.RouteDirectionTwoway {
+		v.errorf(pos, "mode '%s' is not supported, redis routes are oneway", r.Mode)
+	}
+
+	switch {
+	case r.RedisChannel == "" && r.RedisStream == "":
+		v.errorf(pos, "missing REDIS_CHANNEL or REDIS_STREAM required by mode '%s'", r.Mode)
+	case r.RedisChannel != "" && r.RedisStream != "":
+		v.errorf(position(tree, "REDIS_STREAM"), "REDIS_CHANNEL and REDIS_STREAM cannot be used together")
+	}
+
+	if String("REDIS.ADDR") == "" {
+		v.errorf(pos, "mode '%s' requires REDIS.ADDR", r.Mode)
+	}
+}
+
+// validateSSE checks a route streaming to clients of the HTTP server, routes may stream
+// to the same URI but HTTP routes may not receive from it.
+func (v *validator) validateSSE(tree *toml.Tree, r *entity.Route) {
+	pos := position(tree, "MODE")
+
+	if r.Mode.Components().Receiver == entity.DriverSSE {
+		v.errorf(pos, "mode '%s' is not supported, sse routes only send", r.Mode)
+
+		return
+	}
+
+	if r.Mode.Components().Direction == entity.RouteDirectionTwoway {
+		v.errorf(pos, "mode '%s' is not supported, sse routes are oneway", r.Mode)
+	}
+
+	if r.URI == "" {
+		return
+	}
+
+	pos = position(tree, "URI")
+
+	if _, err := url.ParseRequestURI(r.URI); err != nil {
+		v.errorf(pos, "invalid URI '%s'", r.URI)
+
+		return
+	}
+
+	if entity.IsReservedURI(r.URI) {
+		v.errorf(pos, "use of reserved URI pattern '%s'", r.URI)
+	}
+}
+
+func (v *validator) validateBreaker(tree *toml.Tree, r *entity.Route) {
+	pos := position(tree, "BREAKER")
+
+	if r.Mode.Components().Sender != entity.DriverHTTP {
+		v.errorf(pos, "BREAKER can be used only to send to http")
+
+		return
+	}
+
+	if _, err := breaker.New(&breaker.Config{
+		Threshold: r.Breaker.Threshold,
+		Duration:  r.Breaker.Duration,
+		Probes:    r.Breaker.Probes,
+	}); err != nil {
+		v.errorf(pos, "BREAKER: %v", err)
+	}
+}
+
+func (v *validator) validateTemplate(tree *toml.Tree, r *entity.Route, key, value string) {
+	if !msgtpl.IsTemplate(value) {
+		return
+	}
+
+	if err := msgtpl.Validate(value); err != nil {
+		v.errorf(position(tree, key), "%s: %v", key, err)
+
+		return
+	}
+
+	if r.Batching != nil {
+		v.errorf(position(tree, key), "%s template can not be used with BATCHING", key)
+	}
+}
+
+func (v *validator) validateWildcards(tree *toml.Tree, r *entity.Route) {
+	comp := r.Mode.Components()
+	count := 0
+
+	if comp.Receiver == entity.DriverBroker {
+		count = msgtpl.CountWildcards(r.Topic)
+	} else {
+		v.validateWildcardRefs(tree, "TOPIC", r.Topic, count)
+	}
+
+	v.validateWildcardRefs(tree, "ENDPOINT", r.Endpoint, count)
+	v.validateWildcardRefs(tree, "MQTT_TOPIC", r.MQTTTopic, count)
+	v.validateWildcardRefs(tree, "AMQP_ROUTING_KEY", r.AMQPRoutingKey, count)
+	v.validateWildcardRefs(tree, "REDIS_CHANNEL", r.RedisChannel, count)
+	v.validateWildcardRefs(tree, "REDIS_STREAM", r.RedisStream, count)
+
+	if !msgtpl.IsWildcard(r.Topic) {
+		return
+	}
+
+	pos := position(tree, "TOPIC")
+
+	if comp.Receiver != entity.DriverBroker || comp.Sender == entity.DriverBroker {
+		v.errorf(pos, "wildcard TOPIC '%s' can be used only to receive from broker", r.Topic)
+	} else if String("MESSAGE_BROKER.BROKER") != wildcardBroker {
+		v.errorf(pos, "wildcard TOPIC '%s' is supported only by %s broker", r.Topic, wildcardBroker)
+	}
+}
+
+func (v *validator) validateWildcardRefs(tree *toml.Tree, key, value string, count int) {
+	if refs := msgtpl.WildcardRefs(value); refs > count {
+		v.errorf(position(tree, key), "%s refers to {%d} while receiving TOPIC has %d wildcards", key, refs, count)
+	}
+}
+
+func (v *validator) validateURI(tree *toml.Tree, uri string) {
+	pos := position(tree, "URI")
+
+	if _, err := url.ParseRequestURI(uri); err != nil {
+		v.errorf(pos, "invalid URI '%s'", uri)
+
+		return
+	}
+
+	if entity.IsReservedURI(uri) {
+		v.errorf(pos, "use of reserved URI pattern '%s'", uri)
+	}
+}
+
+// validateConflict reports the route that shadows one of the routes declared before it,
+// the same check is done by the router on start.
+func (v *validator) validateConflict(tree *toml.Tree, r *entity.Route, conflicts *entity.RouteConflicts, n int, places []routePlace) {
+	m, reason, ok := conflicts.Add(n, r)
+
+	if !ok {
+		return
+	}
+
+	switch reason {
+	case entity.RouteConflictURI, entity.RouteConflictStream:
+		v.errorf(position(tree, "URI"), "duplicate URI '%s', already used at %s", r.URI, places[m].at("URI"))
+	case entity.RouteConflictReply:
+		key := routeDrivers[r.Mode.Components().Receiver].receiver
+
+		v.errorf(position(tree, key), "duplicate twoway %s '%s', already replied at %s",
+			key, routeValue(r, key), places[m].at(key))
+	default:
+		v.errorf(position(tree, "MODE"), "duplicate route, the same as at %s", places[m].at("MODE"))
+	}
+}
+
+// routePlace is the file and the table a route is declared in.
+type routePlace struct {
+	file string
+	tree *toml.Tree
+}
+
+func (p routePlace) at(key string) string {
+	return fmt.Sprintf("%s:%d", p.file, position(p.tree, key).Line)
+}
+
+func (v *validator) require(tree *toml.Tree, r *entity.Route, key string) {
+	if key != "" && routeValue(r, key) == "" {
+		v.errorf(position(tree, key), "missing %s required by mode '%s'", key, r.Mode)
+	}
+}
+
+func routeValue(r *entity.Route, key string) string {
+	values := map[string]string{
+		"TOPIC":            r.Topic,
+		"URI":              r.URI,
+		"ENDPOINT":         r.Endpoint,
+		"MQTT_TOPIC":       r.MQTTTopic,
+		"AMQP_QUEUE":       r.AMQPQueue,
+		"AMQP_ROUTING_KEY": r.AMQPRoutingKey,
+	}
+
+	return values[key]
+}
+
+// lookup finds the dotted key in the tree ignoring case as viper does.
+func lookup(tree *toml.Tree, key string) interface{} {
+	var value interface{} = tree
+
+	for _, part := range strings.Split(key, ".") {
+		sub, ok := value.(*toml.Tree)
+
+		if !ok {
+			return nil
+		}
+
+		k, ok := findKey(sub, part)
+
+		if !ok {
+			return nil
+		}
+
+		value = sub.GetPath([]string{k})
+	}
+
+	return value
+}
+
+// position returns the position of the dotted key or of the closest table containing it.
+func position(tree *toml.Tree, key string) toml.Position {
+	if tree == nil {
+		return toml.Position{}
+	}
+
+	for _, part := range strings.Split(key, ".") {
+		k, ok := findKey(tree, part)
+
+		if !ok {
+			return tree.Position()
+		}
+
+		sub, ok := tree.GetPath([]string{k}).(*toml.Tree)
+
+		if !ok {
+			return tree.GetPositionPath([]string{k})
+		}
+
+		tree = sub
+	}
+
+	return tree.Position()
+}
+
+func findKey(tree *toml.Tree, name string) (string, bool) {
+	for _, k := range tree.Keys() {
+		if strings.EqualFold(k, name) {
+			return k, true
+		}
+	}
+
+	return "", false
+}
+
+func fieldByTag(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		if strings.EqualFold(t.Field(i).Tag.Get("toml"), key) {
+			return t.Field(i), true
+		}
+	}
+
+	return reflect.StructField{}, false
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func typeName(t reflect.Type) string {
+	switch {
+	case t == durationType:
+		return "a duration"
+	case t.Kind() == reflect.Bool:
+		return "a boolean"
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Int64:
+		return "an integer"
+	case t.Kind() >= reflect.Uint && t.Kind() <= reflect.Uint64:
+		return "a non-negative integer"
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return "a number"
+	}
+
+	return "a string"
+}
+
+func validDuration(s string) bool {
+	_, err := time.ParseDuration(s)
+
+	return err == nil
+}
+
+func validBool(s string) bool {
+	_, err := strconv.ParseBool(s)
+
+	return err == nil
+}
+
+func validInt(s string, unsigned bool) bool {
+	if unsigned {
+		_, err := strconv.ParseUint(s, 10, 64)
+
+		return err == nil
+	}
+
+	_, err := strconv.ParseInt(s, 10, 64)
+
+	return err == nil
+}
+
+func validFloat(s string) bool {
+	_, err := strconv.ParseFloat(s, 64)
+
+	return err == nil
+}
+
+func deref(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	return t
+}
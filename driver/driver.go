@@ -20,6 +20,20 @@ type Receiver interface {
 }
 
 type Sender interface {
-	Send(payload []byte) error
-	Request(payload []byte) ([]byte, error)
+	Send(msg *entity.Message) error
+	Request(msg *entity.Message) (*entity.Message, error)
+}
+
+// Replayer is a receiver that reads messages again from the broker.
+type Replayer interface {
+	// Positions returns the positions of the newest messages of the source.
+	Positions() ([]*entity.Position, error)
+	// Replay passes the messages following the positions with the context to the sender
+	// the receiver listens with, the messages existing at the call are replayed.
+	Replay(ctx context.Context, after []*entity.Position) error
+}
+
+// Resumer is a sender that resumes its clients by the replayer of the route.
+type Resumer interface {
+	Resume(replayer Replayer)
 }
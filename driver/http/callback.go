@@ -0,0 +1,47 @@
+package http
+
+import (
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// parseCallbackURLs parses the URL prefixes callbacks of asynchronous requests are allowed to.
+func parseCallbackURLs(prefixes []string) ([]*url.URL, error) {
+	urls := make([]*url.URL, 0, len(prefixes))
+
+	for _, prefix := range prefixes {
+		u, err := url.Parse(prefix)
+
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, errors.Errorf("invalid callback url '%s'", prefix)
+		}
+
+		urls = append(urls, u)
+	}
+
+	return urls, nil
+}
+
+// allowedCallback reports whether the callback has the scheme and the host of one of the prefixes
+// and its path is within the prefix path. Without prefixes no callback is allowed, NATter would
+// otherwise post replies to any address a caller asks for, including internal ones.
+func allowedCallback(prefixes []*url.URL, callback *url.URL) bool {
+	p := path.Clean("/" + callback.Path)
+
+	for _, prefix := range prefixes {
+		if callback.Scheme != prefix.Scheme || !strings.EqualFold(callback.Host, prefix.Host) {
+			continue
+		}
+
+		dir := strings.TrimSuffix(path.Clean("/"+prefix.Path), "/")
+
+		if dir == "" || p == dir || strings.HasPrefix(p, dir+"/") {
+			return true
+		}
+	}
+
+	return false
+}
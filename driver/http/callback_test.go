@@ -0,0 +1,53 @@
+package http
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCallbackURLs(t *testing.T) {
+	urls, err := parseCallbackURLs([]string{"https://hooks.example.com/natter", "http://localhost:8080"})
+
+	require.NoError(t, err)
+	assert.Len(t, urls, 2)
+
+	for _, prefix := range []string{"hooks.example.com", "file:///etc", "ftp://example.com"} {
+		_, err := parseCallbackURLs([]string{prefix})
+
+		assert.EqualError(t, err, "invalid callback url '"+prefix+"'")
+	}
+}
+
+func TestAllowedCallback(t *testing.T) {
+	prefixes, err := parseCallbackURLs([]string{"https://hooks.example.com/natter/", "http://localhost:8080"})
+
+	require.NoError(t, err)
+
+	cases := map[string]bool{
+		"https://hooks.example.com/natter":          true,
+		"https://hooks.example.com/natter/orders":   true,
+		"https://HOOKS.example.com/natter/orders":   true,
+		"http://localhost:8080/any/path":            true,
+		"http://hooks.example.com/natter/orders":    false,
+		"https://hooks.example.com/natterx":         false,
+		"https://hooks.example.com/natter/../admin": false,
+		"https://hooks.example.com.evil.com/natter": false,
+		"https://hooks.example.com:8443/natter":     false,
+		"http://localhost:8081/any/path":            false,
+		"http://169.254.169.254/latest/meta-data":   false,
+	}
+
+	for callback, allowed := range cases {
+		u, err := url.Parse(callback)
+
+		require.NoError(t, err)
+		assert.Equal(t, allowed, allowedCallback(prefixes, u), callback)
+	}
+
+	u, _ := url.Parse("http://localhost:8080/callback")
+
+	assert.False(t, allowedCallback(nil, u))
+}
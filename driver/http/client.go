@@ -0,0 +1,185 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultMaxIdleConns    = 100
+	defaultIdleConnTimeout = time.Second * 90
+	defaultDialTimeout     = time.Second * 30
+	defaultKeepAlive       = time.Second * 30
+)
+
+// ClientConfig describes the client shared by routes sending to HTTP, so its connections to
+// a host are reused by every route of the host. Zero values keep the defaults of the standard
+// transport, except the idle connections per host that default to all idle connections.
+type ClientConfig struct {
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int
+	IdleConnTimeout     time.Duration
+	DisableHTTP2        bool
+	ProxyURL            string        // proxy from the environment if empty
+	DNSCacheTTL         time.Duration // no caching if zero
+}
+
+func newClient(cfg *ClientConfig) (*http.Client, error) {
+	if cfg == nil {
+		cfg = &ClientConfig{}
+	}
+
+	proxy := http.ProxyFromEnvironment
+
+	if cfg.ProxyURL != "" {
+		u, err := url.Parse(cfg.ProxyURL)
+
+		if err != nil {
+			return nil, errors.Wrap(err, "proxy url")
+		}
+
+		proxy = http.ProxyURL(u)
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   defaultDialTimeout,
+		KeepAlive: defaultKeepAlive,
+	}
+
+	dial := dialer.DialContext
+
+	if cfg.DNSCacheTTL > 0 {
+		dial = newDNSCache(cfg.DNSCacheTTL, net.DefaultResolver.LookupHost).dialContext(dial)
+	}
+
+	transport := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dial,
+		ForceAttemptHTTP2:     !cfg.DisableHTTP2,
+		MaxIdleConns:          defaultMaxIdleConns,
+		MaxConnsPerHost:       cfg.MaxConnsPerHost,
+		IdleConnTimeout:       defaultIdleConnTimeout,
+		TLSHandshakeTimeout:   time.Second * 10,
+		ExpectContinueTimeout: time.Second,
+	}
+
+	if cfg.MaxIdleConns > 0 {
+		transport.MaxIdleConns = cfg.MaxIdleConns
+	}
+
+	// The standard default of 2 idle connections per host makes routes sharing the client
+	// reconnect to a busy endpoint all the time.
+	transport.MaxIdleConnsPerHost = transport.MaxIdleConns
+
+	if cfg.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+	}
+
+	if cfg.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = cfg.IdleConnTimeout
+	}
+
+	if cfg.DisableHTTP2 {
+		// A non-nil empty map turns off the HTTP/2 upgrade of TLS connections.
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+
+	return &http.Client{Transport: transport}, nil
+}
+
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+type lookupFunc func(ctx context.Context, host string) ([]string, error)
+
+// dnsCache keeps resolved addresses of endpoint hosts for the ttl.
+type dnsCache struct {
+	ttl    time.Duration
+	lookup lookupFunc
+
+	mx      *sync.Mutex
+	entries map[string]*dnsEntry
+}
+
+type dnsEntry struct {
+	addrs   []string
+	expires time.Time
+}
+
+func newDNSCache(ttl time.Duration, lookup lookupFunc) *dnsCache {
+	return &dnsCache{
+		ttl:     ttl,
+		lookup:  lookup,
+		mx:      &sync.Mutex{},
+		entries: make(map[string]*dnsEntry),
+	}
+}
+
+func (c *dnsCache) resolve(ctx context.Context, host string) ([]string, error) {
+	c.mx.Lock()
+	entry, ok := c.entries[host]
+	c.mx.Unlock()
+
+	if ok && time.Now().Before(entry.expires) {
+		return entry.addrs, nil
+	}
+
+	addrs, err := c.lookup(ctx, host)
+
+	if err != nil {
+		return nil, err
+	}
+
+	c.mx.Lock()
+	c.entries[host] = &dnsEntry{
+		addrs:   addrs,
+		expires: time.Now().Add(c.ttl),
+	}
+	c.mx.Unlock()
+
+	return addrs, nil
+}
+
+// dialContext dials the cached addresses of the host in turn until one is connected.
+func (c *dnsCache) dialContext(dial dialFunc) dialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if net.ParseIP(host) != nil {
+			return dial(ctx, network, addr)
+		}
+
+		addrs, err := c.resolve(ctx, host)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if len(addrs) == 0 {
+			return nil, errors.Errorf("no addresses of host %s", host)
+		}
+
+		for _, ip := range addrs {
+			var conn net.Conn
+
+			conn, err = dial(ctx, network, net.JoinHostPort(ip, port))
+
+			if err == nil {
+				return conn, nil
+			}
+		}
+
+		return nil, err
+	}
+}
@@ -0,0 +1,133 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewClient(t *testing.T) {
+	client, err := newClient(&ClientConfig{
+		MaxIdleConnsPerHost: 10,
+		MaxConnsPerHost:     20,
+		ProxyURL:            "http://proxy:3128",
+		DisableHTTP2:        true,
+	})
+
+	assert.Nil(t, err)
+
+	transport, ok := client.Transport.(*http.Transport)
+
+	assert.True(t, ok)
+	assert.Equal(t, defaultMaxIdleConns, transport.MaxIdleConns)
+	assert.Equal(t, 10, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, 20, transport.MaxConnsPerHost)
+	assert.Equal(t, defaultIdleConnTimeout, transport.IdleConnTimeout)
+	assert.False(t, transport.ForceAttemptHTTP2)
+	assert.NotNil(t, transport.TLSNextProto)
+
+	proxy, err := transport.Proxy(httptest.NewRequest(http.MethodPost, "http://localhost/path", nil))
+
+	assert.Nil(t, err)
+	assert.Equal(t, &url.URL{Scheme: "http", Host: "proxy:3128"}, proxy)
+}
+
+func TestNewClientOnDefaults(t *testing.T) {
+	client, err := newClient(nil)
+
+	assert.Nil(t, err)
+
+	transport, ok := client.Transport.(*http.Transport)
+
+	assert.True(t, ok)
+	assert.True(t, transport.ForceAttemptHTTP2)
+	assert.Nil(t, transport.TLSNextProto)
+	assert.Equal(t, defaultMaxIdleConns, transport.MaxIdleConnsPerHost)
+
+	client, err = newClient(&ClientConfig{MaxIdleConns: 10})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 10, client.Transport.(*http.Transport).MaxIdleConnsPerHost)
+}
+
+func TestNewClientOnProxyError(t *testing.T) {
+	_, err := newClient(&ClientConfig{
+		ProxyURL: "http://proxy:port",
+	})
+
+	assert.NotNil(t, err)
+}
+
+func TestDNSCacheResolve(t *testing.T) {
+	lookups := 0
+
+	cache := newDNSCache(time.Millisecond*50, func(ctx context.Context, host string) ([]string, error) {
+		lookups++
+
+		return []string{"127.0.0.1"}, nil
+	})
+
+	for i := 0; i < 3; i++ {
+		addrs, err := cache.resolve(context.Background(), "localhost")
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"127.0.0.1"}, addrs)
+	}
+
+	assert.Equal(t, 1, lookups)
+
+	time.Sleep(time.Millisecond * 60)
+
+	_, err := cache.resolve(context.Background(), "localhost")
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, lookups)
+}
+
+func TestDNSCacheResolveOnError(t *testing.T) {
+	cache := newDNSCache(time.Minute, func(ctx context.Context, host string) ([]string, error) {
+		return nil, errors.New("no such host")
+	})
+
+	_, err := cache.resolve(context.Background(), "localhost")
+
+	assert.NotNil(t, err)
+	assert.Empty(t, cache.entries)
+}
+
+func TestDNSCacheDialContext(t *testing.T) {
+	cache := newDNSCache(time.Minute, func(ctx context.Context, host string) ([]string, error) {
+		return []string{"10.0.0.1", "10.0.0.2"}, nil
+	})
+
+	var dialed []string
+
+	dial := cache.dialContext(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialed = append(dialed, addr)
+
+		if addr == "10.0.0.1:80" {
+			return nil, errors.New("connection refused")
+		}
+
+		return nil, nil
+	})
+
+	_, err := dial(context.Background(), "tcp", "example.com:80")
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"10.0.0.1:80", "10.0.0.2:80"}, dialed)
+
+	dialed = nil
+
+	_, err = dial(context.Background(), "tcp", "127.0.0.1:80")
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"127.0.0.1:80"}, dialed)
+}
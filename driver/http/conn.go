@@ -4,53 +4,115 @@ import (
 	"context"
 	"net"
 	"net/http"
+	"net/url"
 	"sync"
+	"time"
 
 	"NATter/driver"
 	"NATter/entity"
 	"NATter/errtpl"
 	"NATter/log"
+	"NATter/msgtpl"
 
 	"github.com/go-chi/chi"
 	"github.com/pkg/errors"
 )
 
-const (
-	DriverName = "http"
-
-	reservedURIPattern = `^/i(/.*)?$` // /i/* or /i
-)
+const DriverName = entity.DriverHTTP
 
 type ConnConfig struct {
 	Host string
 	Port string
+
+	Timeout           time.Duration // outbound requests, a route timeout takes precedence
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+
+	Client *ClientConfig
+
+	RequestRetention time.Duration // states of asynchronous requests are kept after they are finished
+	CallbackURLs     []string      // URL prefixes a caller may pass in the callback header
+
+	SSE *SSEConfig
 }
 
 type conn struct {
 	host string
 	port string
 
-	mux    *chi.Mux
-	routes []*entity.Route
-	wg     *sync.WaitGroup
+	timeout           time.Duration
+	readTimeout       time.Duration
+	readHeaderTimeout time.Duration
+	writeTimeout      time.Duration
+	idleTimeout       time.Duration
+
+	client    *http.Client
+	requests  *requestStore
+	callbacks []*url.URL
+	mux       *chi.Mux
+	routes    []*entity.Route
+	wg        *sync.WaitGroup
+
+	sse       *SSEConfig
+	heartbeat time.Duration
+	mx        sync.Mutex
+	streams   map[string]*stream // by URI
 }
 
-func NewConn(cfg *ConnConfig) driver.Conn {
-	return &conn{
-		host:   cfg.Host,
-		port:   cfg.Port,
-		mux:    chi.NewRouter(),
-		routes: []*entity.Route{},
-		wg:     &sync.WaitGroup{},
+func NewConn(cfg *ConnConfig) (driver.Conn, error) {
+	client, err := newClient(cfg.Client)
+
+	if err != nil {
+		return nil, err
+	}
+
+	callbacks, err := parseCallbackURLs(cfg.CallbackURLs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	conn := &conn{
+		host: cfg.Host,
+		port: cfg.Port,
+
+		timeout:           cfg.Timeout,
+		readTimeout:       cfg.ReadTimeout,
+		readHeaderTimeout: cfg.ReadHeaderTimeout,
+		writeTimeout:      cfg.WriteTimeout,
+		idleTimeout:       cfg.IdleTimeout,
+
+		client:    client,
+		requests:  newRequestStore(cfg.RequestRetention),
+		callbacks: callbacks,
+		mux:       chi.NewRouter(),
+		routes:    []*entity.Route{},
+		wg:        &sync.WaitGroup{},
+
+		sse:       cfg.SSE,
+		heartbeat: defaultStreamHeartbeat,
+		streams:   make(map[string]*stream),
 	}
+
+	if cfg.SSE != nil && cfg.SSE.Heartbeat > 0 {
+		conn.heartbeat = cfg.SSE.Heartbeat
+	}
+
+	return conn, nil
 }
 
 func (c *conn) Serve(ctx context.Context) error {
 	c.registerInternalRoutes()
 
 	srv := http.Server{
-		Addr:    net.JoinHostPort(c.host, c.port),
-		Handler: c.mux,
+		Addr:              net.JoinHostPort(c.host, c.port),
+		Handler:           c.mux,
+		ReadTimeout:       c.readTimeout,
+		ReadHeaderTimeout: c.readHeaderTimeout,
+		WriteTimeout:      c.writeTimeout,
+		IdleTimeout:       c.idleTimeout,
 	}
 
 	go func() {
@@ -59,6 +121,9 @@ func (c *conn) Serve(ctx context.Context) error {
 		if err := srv.Shutdown(ctx); err != nil {
 			log.Error(errtpl.ErrClose(err, "http server"))
 		}
+
+		// Streams are not finished by the server shutdown.
+		c.closeStreams()
 	}()
 
 	err := srv.ListenAndServe()
@@ -78,6 +143,7 @@ func (c *conn) registerInternalRoutes() {
 	c.mux.Get("/i/routes", routes(func() []*entity.Route {
 		return c.routes
 	}))
+	c.mux.Get("/i/requests/{id}", requestState(c.requests.get))
 }
 
 func (c *conn) Close() error {
@@ -88,16 +154,63 @@ func (c *conn) Receiver(route *entity.Route) driver.Receiver {
 	c.routes = append(c.routes, route)
 
 	return &receiver{
-		mux:      c.mux,
-		wg:       c.wg,
-		async:    route.Async,
-		uri:      route.URI,
-		endpoint: route.Endpoint,
+		mux:         c.mux,
+		wg:          c.wg,
+		async:       route.Async,
+		uri:         route.URI,
+		endpoint:    msgtpl.NewEndpoint(route.Endpoint),
+		client:      c.client,
+		requests:    c.requests,
+		callbacks:   c.callbacks,
+		compression: route.Compression,
+		timeout:     c.routeTimeout(route),
+		route:       route.ID,
+		logPayload:  log.PayloadEnabled(route.LogPayload),
 	}
 }
 
 func (c *conn) Sender(route *entity.Route) driver.Sender {
 	c.routes = append(c.routes, route)
 
-	return &sender{endpoint: route.Endpoint}
+	return &sender{
+		endpoint:    msgtpl.NewEndpoint(route.Endpoint),
+		client:      c.client,
+		compression: route.Compression,
+		timeout:     c.routeTimeout(route),
+		route:       route.ID,
+	}
+}
+
+// stream returns the stream of the URI, routes sending to the URI share it.
+func (c *conn) stream(uri string) *stream {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if s, ok := c.streams[uri]; ok {
+		return s
+	}
+
+	s := newStream(uri, c.sse)
+
+	c.streams[uri] = s
+	c.mux.Get(uri, s.handle(c.heartbeat))
+
+	return s
+}
+
+func (c *conn) closeStreams() {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	for _, s := range c.streams {
+		s.close()
+	}
+}
+
+func (c *conn) routeTimeout(route *entity.Route) time.Duration {
+	if route.Timeout != 0 {
+		return time.Duration(route.Timeout) * time.Second
+	}
+
+	return c.timeout
 }
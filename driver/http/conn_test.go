@@ -2,6 +2,7 @@ package http
 
 import (
 	"context"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
@@ -91,10 +92,41 @@ func TestConnServeOnInternalRoutesEmpty(t *testing.T) {
 	assert.Equal(t, `[]`+"\n", resp.Body.String())
 }
 
+func TestConnOnRequestState(t *testing.T) {
+	conn := &conn{
+		mux:      chi.NewRouter(),
+		wg:       &sync.WaitGroup{},
+		requests: newRequestStore(time.Minute),
+	}
+
+	conn.registerInternalRoutes()
+
+	id, err := conn.requests.add("/path")
+
+	assert.Nil(t, err)
+
+	conn.requests.finish(id, http.StatusOK, false)
+
+	resp := httptest.NewRecorder()
+
+	conn.mux.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/i/requests/"+id, nil))
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.JSONEq(t, fmt.Sprintf(`{"id":"%s","uri":"/path","state":"done","status":200}`, id), withoutTimes(t, resp.Body.Bytes()))
+
+	resp = httptest.NewRecorder()
+
+	conn.mux.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/i/requests/unknown", nil))
+
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+}
+
 func TestConnClose(t *testing.T) {
-	conn := NewConn(&ConnConfig{})
+	conn, err := NewConn(&ConnConfig{})
 
-	err := conn.Close()
+	assert.Nil(t, err)
+
+	err = conn.Close()
 
 	assert.Nil(t, err)
 }
@@ -148,7 +180,24 @@ func TestConnSender(t *testing.T) {
 		{Endpoint: srvr.URL},
 	}, conn.routes)
 
-	err := sender.Send([]byte("request-data"))
+	err := sender.Send(entity.NewMessage([]byte("request-data")))
 
 	assert.Nil(t, err)
 }
+
+func TestConnSenderOnTimeout(t *testing.T) {
+	conn := &conn{
+		routes:  []*entity.Route{},
+		timeout: time.Second * 30,
+	}
+
+	snd, ok := conn.Sender(&entity.Route{Endpoint: "http://svc"}).(*sender)
+
+	assert.True(t, ok)
+	assert.Equal(t, time.Second*30, snd.timeout)
+
+	snd, ok = conn.Sender(&entity.Route{Endpoint: "http://svc", Timeout: 5}).(*sender)
+
+	assert.True(t, ok)
+	assert.Equal(t, time.Second*5, snd.timeout)
+}
@@ -1,39 +1,114 @@
 package http
 
 import (
+	"bytes"
+	"io"
 	"io/ioutil"
 	"net/http"
 
+	"NATter/compression"
 	"NATter/driver/http/response"
 	"NATter/entity"
+	"NATter/errtpl"
+
+	"github.com/go-chi/chi"
+	"github.com/pkg/errors"
 )
 
-func routeHTTP(handler func([]byte) ([]byte, error)) func(http.ResponseWriter, *http.Request) {
+// routeHTTP serves requests of the route, bodies of failed requests are logged if the route logs payloads.
+func routeHTTP(handler func(*entity.Message) (*entity.Message, error), route string, logPayload bool) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		reqb, err := ioutil.ReadAll(r.Body)
+		reqb, err := readBody(r.Body)
+
+		if err != nil {
+			response.RenderRouteError(w, r, err, route, logPayload)
+
+			return
+		}
+
+		reqb, err = compression.Decompress(r.Header.Get(headerContentEncoding), reqb)
 
 		if err != nil {
-			response.RenderError(w, r, err)
+			response.RenderRouteError(w, r, err, route, logPayload)
 
 			return
 		}
 
-		respb, err := handler(reqb)
+		// Keep the body readable for error logging.
+		r.Body = ioutil.NopCloser(bytes.NewReader(reqb))
+
+		msg := &entity.Message{
+			Payload: reqb,
+			Params:  urlParams(r),
+			Header:  entity.MessageHeader(r.Header),
+		}
+
+		resp, err := handler(msg.WithContext(r.Context()))
 
 		if err != nil {
-			response.RenderError(w, r, err)
+			response.RenderRouteError(w, r, err, route, logPayload)
+
+			return
+		}
+
+		if resp == nil {
+			return
+		}
+
+		for key, value := range resp.Header {
+			w.Header().Set(key, value)
+		}
 
+		if resp.Status != 0 {
+			w.WriteHeader(resp.Status)
+		}
+
+		if !bodyAllowed(resp.Status) {
 			return
 		}
 
-		if _, err := w.Write(respb); err != nil {
-			response.RenderError(w, r, err)
+		if _, err := w.Write(resp.Payload); err != nil {
+			response.RenderRouteError(w, r, err, route, logPayload)
 
 			return
 		}
 	}
 }
 
+func bodyAllowed(status int) bool {
+	return status != http.StatusNoContent && status != http.StatusNotModified
+}
+
+func urlParams(r *http.Request) map[string]string {
+	rctx := chi.RouteContext(r.Context())
+
+	if rctx == nil || len(rctx.URLParams.Keys) == 0 {
+		return nil
+	}
+
+	params := make(map[string]string, len(rctx.URLParams.Keys))
+
+	for i, key := range rctx.URLParams.Keys {
+		params[key] = rctx.URLParams.Values[i]
+	}
+
+	return params
+}
+
+func requestState(handler func(id string) (*asyncState, bool)) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		state, ok := handler(chi.URLParam(r, "id"))
+
+		if !ok {
+			response.RenderError(w, r, errors.Wrap(errtpl.ErrNotFound, "request"))
+
+			return
+		}
+
+		response.Render(state, w)
+	}
+}
+
 func responseRoutes(routes []*entity.Route) []*entity.Route {
 	if routes == nil {
 		routes = []*entity.Route{}
@@ -49,3 +124,20 @@ func routes(handler func() []*entity.Route) func(http.ResponseWriter, *http.Requ
 		response.Render(responseRoutes(routes), w)
 	}
 }
+
+// readBody reads the body until it exceeds the max size of decompressed payloads.
+func readBody(body io.Reader) ([]byte, error) {
+	limit := compression.MaxSize()
+
+	res, err := ioutil.ReadAll(io.LimitReader(body, limit+1))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(res)) > limit {
+		return nil, compression.ErrTooLarge
+	}
+
+	return res, nil
+}
@@ -1,12 +1,19 @@
 package http
 
 import (
+	"context"
+	"encoding/json"
+	"net/http"
 	"net/url"
-	"regexp"
+	"strconv"
 	"sync"
+	"time"
 
 	"NATter/driver"
+	"NATter/entity"
+	"NATter/errtpl"
 	"NATter/log"
+	"NATter/msgtpl"
 
 	"github.com/go-chi/chi"
 	"github.com/pkg/errors"
@@ -16,9 +23,16 @@ type receiver struct {
 	mux *chi.Mux
 	wg  *sync.WaitGroup
 
-	async    bool
-	uri      string
-	endpoint string
+	async       bool
+	uri         string
+	endpoint    *msgtpl.Template
+	client      *http.Client
+	requests    *requestStore
+	callbacks   []*url.URL
+	compression string
+	timeout     time.Duration
+	route       string
+	logPayload  bool
 }
 
 func (r *receiver) Listen(sender driver.Sender) error {
@@ -26,11 +40,11 @@ func (r *receiver) Listen(sender driver.Sender) error {
 		return err
 	}
 
-	r.mux.Post(r.uri, routeHTTP(func(payload []byte) ([]byte, error) {
-		log.Debugf("received request from uri: %s", r.uri)
+	r.mux.Post(r.uri, routeHTTP(func(msg *entity.Message) (*entity.Message, error) {
+		r.logReceived(msg.Payload)
 
-		return nil, sender.Send(payload)
-	}))
+		return nil, sender.Send(msg)
+	}, r.route, r.logPayload))
 
 	return nil
 }
@@ -40,17 +54,15 @@ func (r *receiver) ListenRequest(sender driver.Sender) error {
 		return err
 	}
 
-	r.mux.Post(r.uri, routeHTTP(func(payload []byte) ([]byte, error) {
-		log.Debugf("received request from uri: %s", r.uri)
+	r.mux.Post(r.uri, routeHTTP(func(msg *entity.Message) (*entity.Message, error) {
+		r.logReceived(msg.Payload)
 
 		if !r.async {
-			return sender.Request(payload)
+			return sender.Request(msg)
 		}
 
-		r.asyncRequest(sender, payload)
-
-		return nil, nil
-	}))
+		return r.asyncRequest(sender, msg)
+	}, r.route, r.logPayload))
 
 	return nil
 }
@@ -60,33 +72,127 @@ func (r *receiver) validateURI() error {
 		return err
 	}
 
-	if regexp.MustCompile(reservedURIPattern).MatchString(r.uri) {
+	if entity.IsReservedURI(r.uri) {
 		return errors.Errorf("use of reserved uri pattern: %s", r.uri)
 	}
 
 	return nil
 }
 
-func (r *receiver) asyncRequest(sender driver.Sender, payload []byte) {
+// asyncRequest responds with the state of the request that is served in the background,
+// the reply is posted to the callback of the caller or to the route endpoint.
+func (r *receiver) asyncRequest(sender driver.Sender, msg *entity.Message) (*entity.Message, error) {
+	callback := msg.Header[headerCallback]
+
+	if callback != "" {
+		u, err := url.Parse(callback)
+
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, errors.Wrapf(errtpl.ErrBadRequest, "invalid %s url '%s'", headerCallback, callback)
+		}
+
+		if !allowedCallback(r.callbacks, u) {
+			return nil, errors.Wrapf(errtpl.ErrBadRequest, "%s url '%s' is not allowed", headerCallback, callback)
+		}
+	}
+
+	id, err := r.requests.add(r.uri)
+
+	if err != nil {
+		return nil, err
+	}
+
+	state, _ := r.requests.get(id)
+
+	payload, err := json.Marshal(state)
+
+	if err != nil {
+		return nil, errtpl.ErrMarshal(err, state)
+	}
+
+	// The request is served in the background after the inbound one is responded and canceled.
+	msg = msg.WithContext(context.Background())
+
 	r.wg.Add(1)
 
 	go func() {
 		defer r.wg.Done()
 
-		respb, err := sender.Request(payload)
+		r.callback(sender, msg, id, callback)
+	}()
+
+	return &entity.Message{
+		Payload: payload,
+		Status:  http.StatusAccepted,
+		Header: map[string]string{
+			headerContentType: contentTypeJSON,
+			headerRequestID:   id,
+		},
+	}, nil
+}
+
+func (r *receiver) callback(sender driver.Sender, msg *entity.Message, id, endpoint string) {
+	ent := log.WithFields(log.Fields{
+		log.FieldRoute:     r.route,
+		log.FieldURI:       r.uri,
+		log.FieldEndpoint:  r.endpoint.String(),
+		log.FieldRequestID: id,
+	})
+
+	if endpoint == "" {
+		var err error
+
+		endpoint, err = r.endpoint.Execute(msg)
 
 		if err != nil {
-			log.Error(err)
+			r.requests.finish(id, http.StatusInternalServerError, true)
+			ent.WithError(err).Error("unable respond")
 
 			return
 		}
+	}
 
-		if _, err := request(r.endpoint, respb); err != nil {
-			log.Error(err)
+	reply, err := sender.Request(msg)
 
-			return
+	if err != nil {
+		ent.WithError(err).Error("unable request")
+
+		code, body := errtpl.Reply(err)
+
+		reply = &entity.Message{Payload: body, Status: code}
+		r.requests.finish(id, reply.Status, true)
+	} else {
+		if reply.Status == 0 {
+			reply.Status = http.StatusOK
 		}
 
-		log.Debugf("responded to endpoint: %s", r.endpoint)
-	}()
+		r.requests.finish(id, reply.Status, false)
+	}
+
+	header := http.Header{}
+	header.Set(headerRequestID, id)
+	header.Set(headerStatus, strconv.Itoa(reply.Status))
+
+	if _, err := request(msg.Context(), r.route, r.client, r.timeout, endpoint, reply.Payload, r.compression, header); err != nil {
+		ent.WithError(err).Error("unable respond")
+
+		return
+	}
+
+	ent.Debug("responded")
+}
+
+func (r *receiver) logReceived(payload []byte) {
+	ent := log.WithFields(log.Fields{
+		log.FieldRoute: r.route,
+		log.FieldURI:   r.uri,
+	})
+
+	if r.logPayload {
+		ent = ent.WithFields(log.Fields{
+			log.FieldPayload: log.FormatPayload(payload),
+		})
+	}
+
+	ent.Debug("received")
 }
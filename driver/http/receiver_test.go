@@ -1,19 +1,34 @@
 package http
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
+	"NATter/compression"
+	"NATter/driver/msgbroker"
+	"NATter/entity"
+	"NATter/errtpl"
+	"NATter/log"
 	m "NATter/mock"
+	"NATter/msgtpl"
 
 	"github.com/go-chi/chi"
 	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 func TestReceiverListen(t *testing.T) {
@@ -26,7 +41,7 @@ func TestReceiverListen(t *testing.T) {
 	sender := &m.DriverSender{}
 
 	sender.
-		On("Send", []byte("some-data")).
+		On("Send", m.Message(entity.NewMessage([]byte("some-data")))).
 		Return(nil)
 
 	err := receiver.Listen(sender)
@@ -49,6 +64,57 @@ func TestReceiverListen(t *testing.T) {
 	assert.Equal(t, http.StatusOK, resp.Code)
 }
 
+func TestReceiverListenOnPayloadLogging(t *testing.T) {
+	err := log.SetConfig(&log.Config{Level: "debug"})
+
+	assert.Nil(t, err)
+
+	hook := test.NewGlobal()
+
+	receiver := &receiver{
+		mux:        chi.NewRouter(),
+		wg:         &sync.WaitGroup{},
+		uri:        "/path",
+		route:      "route",
+		logPayload: true,
+	}
+
+	sender := &m.DriverSender{}
+
+	sender.
+		On("Send", m.Message(entity.NewMessage([]byte("some-data")))).
+		Return(errors.New("error"))
+
+	err = receiver.Listen(sender)
+
+	assert.Nil(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/path", strings.NewReader("some-data"))
+	resp := httptest.NewRecorder()
+
+	receiver.mux.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusInternalServerError, resp.Code)
+	assert.Equal(t, 2, len(hook.Entries))
+
+	// The received payload and the body of the failed request are logged by the route setting.
+	assert.Equal(t, "received", hook.Entries[0].Message)
+	assert.Equal(t, "some-data", hook.Entries[0].Data[log.FieldPayload])
+	assert.Equal(t, "some-data", hook.Entries[1].Data[log.FieldBody])
+
+	for _, ent := range hook.Entries {
+		assert.Equal(t, "route", ent.Data[log.FieldRoute])
+	}
+
+	hook.Reset()
+
+	err = log.SetConfig(&log.Config{Level: "info"})
+
+	assert.Nil(t, err)
+
+	logrus.SetOutput(os.Stderr)
+}
+
 func TestReceiverListenOnSendError(t *testing.T) {
 	receiver := &receiver{
 		mux: chi.NewRouter(),
@@ -59,7 +125,7 @@ func TestReceiverListenOnSendError(t *testing.T) {
 	sender := &m.DriverSender{}
 
 	sender.
-		On("Send", []byte("some-data")).
+		On("Send", m.Message(entity.NewMessage([]byte("some-data")))).
 		Return(errors.New("error"))
 
 	err := receiver.Listen(sender)
@@ -82,6 +148,114 @@ func TestReceiverListenOnSendError(t *testing.T) {
 	assert.Equal(t, http.StatusInternalServerError, resp.Code)
 }
 
+func TestReceiverListenOnCompressedBody(t *testing.T) {
+	receiver := &receiver{
+		mux: chi.NewRouter(),
+		wg:  &sync.WaitGroup{},
+		uri: "/path",
+	}
+
+	sender := &m.DriverSender{}
+
+	sender.
+		On("Send", m.Message(&entity.Message{
+			Payload: []byte("some-data"),
+			Header:  map[string]string{"Content-Encoding": "gzip"},
+		})).
+		Return(nil)
+
+	err := receiver.Listen(sender)
+
+	assert.Nil(t, err)
+
+	body, err := compression.Compress("gzip", []byte("some-data"))
+
+	assert.Nil(t, err)
+
+	req, err := http.NewRequestWithContext(
+		context.Background(),
+		http.MethodPost,
+		"/path",
+		bytes.NewReader(body),
+	)
+
+	assert.Nil(t, err)
+
+	req.Header.Set("Content-Encoding", "gzip")
+
+	resp := httptest.NewRecorder()
+
+	receiver.mux.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	sender.AssertExpectations(t)
+}
+
+func TestReceiverListenOnUnknownEncoding(t *testing.T) {
+	receiver := &receiver{
+		mux: chi.NewRouter(),
+		wg:  &sync.WaitGroup{},
+		uri: "/path",
+	}
+
+	err := receiver.Listen(&m.DriverSender{})
+
+	assert.Nil(t, err)
+
+	req, err := http.NewRequestWithContext(
+		context.Background(),
+		http.MethodPost,
+		"/path",
+		strings.NewReader("some-data"),
+	)
+
+	assert.Nil(t, err)
+
+	req.Header.Set("Content-Encoding", "unknown")
+
+	resp := httptest.NewRecorder()
+
+	receiver.mux.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusUnsupportedMediaType, resp.Code)
+}
+
+func TestReceiverListenOnDecompressionBomb(t *testing.T) {
+	compression.SetMaxSize(1024)
+	defer compression.SetMaxSize(0)
+
+	receiver := &receiver{
+		mux: chi.NewRouter(),
+		wg:  &sync.WaitGroup{},
+		uri: "/path",
+	}
+
+	err := receiver.Listen(&m.DriverSender{})
+
+	assert.Nil(t, err)
+
+	body, err := compression.Compress("gzip", make([]byte, 1<<20))
+
+	assert.Nil(t, err)
+
+	req, err := http.NewRequestWithContext(
+		context.Background(),
+		http.MethodPost,
+		"/path",
+		bytes.NewReader(body),
+	)
+
+	assert.Nil(t, err)
+
+	req.Header.Set("Content-Encoding", "gzip")
+
+	resp := httptest.NewRecorder()
+
+	receiver.mux.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp.Code)
+}
+
 func TestReceiverListenOnIncorrectURI(t *testing.T) {
 	receiver := &receiver{
 		mux: chi.NewRouter(),
@@ -104,8 +278,8 @@ func TestReceiverListenRequest(t *testing.T) {
 	sender := &m.DriverSender{}
 
 	sender.
-		On("Request", []byte("request-data")).
-		Return([]byte("response-data"), nil)
+		On("Request", m.Message(entity.NewMessage([]byte("request-data")))).
+		Return(entity.NewMessage([]byte("response-data")), nil)
 
 	err := receiver.ListenRequest(sender)
 
@@ -128,7 +302,67 @@ func TestReceiverListenRequest(t *testing.T) {
 	assert.Equal(t, "response-data", resp.Body.String())
 }
 
+func TestReceiverListenRequestOnStatus(t *testing.T) {
+	inputs := []struct {
+		resp *entity.Message
+		err  error
+		code int
+		body string
+	}{
+		{
+			resp: &entity.Message{Payload: []byte("response-data"), Status: http.StatusCreated},
+			code: http.StatusCreated,
+			body: "response-data",
+		},
+		{
+			resp: &entity.Message{Status: http.StatusNoContent},
+			code: http.StatusNoContent,
+		},
+		{
+			err:  &errtpl.StatusError{Code: http.StatusBadRequest, Body: []byte("bad email")},
+			code: http.StatusBadRequest,
+			body: "bad email",
+		},
+		{
+			err:  errors.Wrap(msgbroker.ErrNoResponders, "topic"),
+			code: http.StatusServiceUnavailable,
+			body: "Service Unavailable\n",
+		},
+		{
+			err:  errors.Wrap(context.DeadlineExceeded, "topic"),
+			code: http.StatusGatewayTimeout,
+			body: "Gateway Timeout\n",
+		},
+	}
+
+	for i, input := range inputs {
+		receiver := &receiver{
+			mux: chi.NewRouter(),
+			wg:  &sync.WaitGroup{},
+			uri: "/path",
+		}
+
+		sender := &m.DriverSender{}
+
+		sender.On("Request", mock.Anything).Return(input.resp, input.err)
+
+		err := receiver.ListenRequest(sender)
+
+		assert.Nil(t, err)
+
+		req := httptest.NewRequest(http.MethodPost, "/path", strings.NewReader("request-data"))
+		resp := httptest.NewRecorder()
+
+		receiver.mux.ServeHTTP(resp, req)
+
+		assert.Equalf(t, input.code, resp.Code, "case %d", i+1)
+		assert.Equalf(t, input.body, resp.Body.String(), "case %d", i+1)
+	}
+}
+
 func TestReceiverListenRequestOnAsync(t *testing.T) {
+	var id string
+
 	srvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(200)
 
@@ -136,6 +370,8 @@ func TestReceiverListenRequestOnAsync(t *testing.T) {
 
 		assert.Nil(t, err)
 		assert.EqualValues(t, []byte("response-data"), reqb)
+		assert.Equal(t, id, r.Header.Get("X-Natter-Request-Id"))
+		assert.Equal(t, "201", r.Header.Get("X-Natter-Status"))
 	}))
 
 	receiver := &receiver{
@@ -143,68 +379,187 @@ func TestReceiverListenRequestOnAsync(t *testing.T) {
 		wg:       &sync.WaitGroup{},
 		async:    true,
 		uri:      "/path",
-		endpoint: srvr.URL,
+		endpoint: msgtpl.New(srvr.URL),
+		requests: newRequestStore(time.Minute),
 	}
 
 	sender := &m.DriverSender{}
 
 	sender.
-		On("Request", []byte("request-data")).
-		Return([]byte("response-data"), nil)
+		On("Request", m.Message(entity.NewMessage([]byte("request-data")))).
+		Return(&entity.Message{Payload: []byte("response-data"), Status: http.StatusCreated}, nil).
+		After(time.Millisecond * 10)
 
 	err := receiver.ListenRequest(sender)
 
 	assert.Nil(t, err)
 
-	req, err := http.NewRequestWithContext(
-		context.Background(),
-		http.MethodPost,
-		"/path",
-		strings.NewReader("request-data"),
-	)
+	req := httptest.NewRequest(http.MethodPost, "/path", strings.NewReader("request-data"))
+	resp := httptest.NewRecorder()
+
+	receiver.mux.ServeHTTP(resp, req)
+
+	id = resp.Header().Get("X-Natter-Request-Id")
+
+	assert.Equal(t, http.StatusAccepted, resp.Code)
+	assert.Len(t, id, 32)
+	assert.JSONEq(t, fmt.Sprintf(`{"id":"%s","uri":"/path","state":"pending"}`, id), withoutTimes(t, resp.Body.Bytes()))
+
+	receiver.wg.Wait()
+
+	state, ok := receiver.requests.get(id)
+
+	assert.True(t, ok)
+	assert.Equal(t, requestDone, state.State)
+	assert.Equal(t, http.StatusCreated, state.Status)
+}
+
+func TestReceiverListenRequestOnAsyncCallback(t *testing.T) {
+	called := false
+
+	srvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+
+		assert.Equal(t, "/callback", r.URL.Path)
+	}))
+
+	callbacks, err := parseCallbackURLs([]string{srvr.URL})
+
+	require.NoError(t, err)
+
+	receiver := &receiver{
+		mux:       chi.NewRouter(),
+		wg:        &sync.WaitGroup{},
+		async:     true,
+		uri:       "/path",
+		endpoint:  msgtpl.New("http://endpoint.com"),
+		requests:  newRequestStore(time.Minute),
+		callbacks: callbacks,
+	}
+
+	sender := &m.DriverSender{}
+
+	sender.On("Request", mock.Anything).Return(entity.NewMessage([]byte("response-data")), nil)
+
+	err = receiver.ListenRequest(sender)
 
 	assert.Nil(t, err)
 
+	req := httptest.NewRequest(http.MethodPost, "/path", strings.NewReader("request-data"))
+	req.Header.Set("X-Natter-Callback", srvr.URL+"/callback")
+
 	resp := httptest.NewRecorder()
 
 	receiver.mux.ServeHTTP(resp, req)
+	receiver.wg.Wait()
 
-	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, http.StatusAccepted, resp.Code)
+	assert.True(t, called)
+}
+
+func TestReceiverListenRequestOnAsyncInvalidCallback(t *testing.T) {
+	receiver := &receiver{
+		mux:      chi.NewRouter(),
+		wg:       &sync.WaitGroup{},
+		async:    true,
+		uri:      "/path",
+		endpoint: msgtpl.New("http://endpoint.com"),
+		requests: newRequestStore(time.Minute),
+	}
+
+	err := receiver.ListenRequest(&m.DriverSender{})
+
+	assert.Nil(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/path", strings.NewReader("request-data"))
+	req.Header.Set("X-Natter-Callback", "file:///etc/passwd")
+
+	resp := httptest.NewRecorder()
+
+	receiver.mux.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Empty(t, receiver.requests.requests)
+}
+
+func TestReceiverListenRequestOnAsyncNotAllowedCallback(t *testing.T) {
+	callbacks, err := parseCallbackURLs([]string{"https://hooks.example.com/natter"})
+
+	require.NoError(t, err)
+
+	receiver := &receiver{
+		mux:       chi.NewRouter(),
+		wg:        &sync.WaitGroup{},
+		async:     true,
+		uri:       "/path",
+		endpoint:  msgtpl.New("http://endpoint.com"),
+		requests:  newRequestStore(time.Minute),
+		callbacks: callbacks,
+	}
+
+	err = receiver.ListenRequest(&m.DriverSender{})
+
+	assert.Nil(t, err)
+
+	for _, callback := range []string{
+		"http://169.254.169.254/latest/meta-data",
+		"https://hooks.example.com.evil.com/natter",
+		"https://hooks.example.com/natter/../admin",
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/path", strings.NewReader("request-data"))
+		req.Header.Set("X-Natter-Callback", callback)
+
+		resp := httptest.NewRecorder()
+
+		receiver.mux.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusBadRequest, resp.Code, callback)
+	}
+
+	assert.Empty(t, receiver.requests.requests)
 }
 
 func TestReceiverListenRequestOnAsyncRequestError(t *testing.T) {
+	srvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqb, err := ioutil.ReadAll(r.Body)
+
+		assert.Nil(t, err)
+		assert.EqualValues(t, []byte("Service Unavailable"), reqb)
+		assert.Equal(t, "503", r.Header.Get("X-Natter-Status"))
+	}))
+
 	receiver := &receiver{
 		mux:      chi.NewRouter(),
 		wg:       &sync.WaitGroup{},
 		async:    true,
 		uri:      "/path",
-		endpoint: "endpoint.com",
+		endpoint: msgtpl.New(srvr.URL),
+		requests: newRequestStore(time.Minute),
 	}
 
 	sender := &m.DriverSender{}
 
 	sender.
-		On("Request", []byte("request-data")).
-		Return([]byte(nil), errors.New("error"))
+		On("Request", m.Message(entity.NewMessage([]byte("request-data")))).
+		Return((*entity.Message)(nil), errors.Wrap(errtpl.ErrServiceUnavailable, "no responders"))
 
 	err := receiver.ListenRequest(sender)
 
 	assert.Nil(t, err)
 
-	req, err := http.NewRequestWithContext(
-		context.Background(),
-		http.MethodPost,
-		"/path",
-		strings.NewReader("request-data"),
-	)
-
-	assert.Nil(t, err)
-
+	req := httptest.NewRequest(http.MethodPost, "/path", strings.NewReader("request-data"))
 	resp := httptest.NewRecorder()
 
 	receiver.mux.ServeHTTP(resp, req)
+	receiver.wg.Wait()
 
-	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, http.StatusAccepted, resp.Code)
+
+	state, ok := receiver.requests.get(resp.Header().Get("X-Natter-Request-Id"))
+
+	assert.True(t, ok)
+	assert.Equal(t, requestFailed, state.State)
+	assert.Equal(t, http.StatusServiceUnavailable, state.Status)
 }
 
 func TestReceiverListenRequestOnAsyncResponseError(t *testing.T) {
@@ -222,24 +577,82 @@ func TestReceiverListenRequestOnAsyncResponseError(t *testing.T) {
 		wg:       &sync.WaitGroup{},
 		async:    true,
 		uri:      "/path",
-		endpoint: srvr.URL,
+		endpoint: msgtpl.New(srvr.URL),
+		requests: newRequestStore(time.Minute),
 	}
 
 	sender := &m.DriverSender{}
 
 	sender.
-		On("Request", []byte("request-data")).
-		Return([]byte("response-data"), nil)
+		On("Request", m.Message(entity.NewMessage([]byte("request-data")))).
+		Return(entity.NewMessage([]byte("response-data")), nil)
 
 	err := receiver.ListenRequest(sender)
 
 	assert.Nil(t, err)
 
+	req := httptest.NewRequest(http.MethodPost, "/path", strings.NewReader("request-data"))
+	resp := httptest.NewRecorder()
+
+	receiver.mux.ServeHTTP(resp, req)
+	receiver.wg.Wait()
+
+	assert.Equal(t, http.StatusAccepted, resp.Code)
+}
+
+// withoutTimes removes the time fields of a request state.
+func withoutTimes(t *testing.T, body []byte) string {
+	var state map[string]interface{}
+
+	assert.Nil(t, json.Unmarshal(body, &state))
+
+	delete(state, "created")
+	delete(state, "updated")
+
+	res, err := json.Marshal(state)
+
+	assert.Nil(t, err)
+
+	return string(res)
+}
+
+func TestReceiverListenRequestOnReservedURI(t *testing.T) {
+	receiver := &receiver{
+		mux: chi.NewRouter(),
+		wg:  &sync.WaitGroup{},
+		uri: "/i/reserved-pattern",
+	}
+
+	err := receiver.ListenRequest(&m.DriverSender{})
+
+	assert.Error(t, err)
+}
+
+func TestReceiverListenOnURIParams(t *testing.T) {
+	receiver := &receiver{
+		mux: chi.NewRouter(),
+		wg:  &sync.WaitGroup{},
+		uri: "/user/{id}/login",
+	}
+
+	sender := &m.DriverSender{}
+
+	sender.
+		On("Send", m.Message(&entity.Message{
+			Payload: []byte("some-data"),
+			Params:  map[string]string{"id": "42"},
+		})).
+		Return(nil)
+
+	err := receiver.Listen(sender)
+
+	assert.Nil(t, err)
+
 	req, err := http.NewRequestWithContext(
 		context.Background(),
 		http.MethodPost,
-		"/path",
-		strings.NewReader("request-data"),
+		"/user/42/login",
+		strings.NewReader("some-data"),
 	)
 
 	assert.Nil(t, err)
@@ -249,16 +662,45 @@ func TestReceiverListenRequestOnAsyncResponseError(t *testing.T) {
 	receiver.mux.ServeHTTP(resp, req)
 
 	assert.Equal(t, http.StatusOK, resp.Code)
+	sender.AssertExpectations(t)
 }
 
-func TestReceiverListenRequestOnReservedURI(t *testing.T) {
+// contextSender checks that messages are sent with the inbound request context.
+type contextSender struct {
+	t *testing.T
+}
+
+func (s *contextSender) Send(msg *entity.Message) error {
+	assert.NotNil(s.t, msg.Context().Done())
+
+	return nil
+}
+
+func (s *contextSender) Request(msg *entity.Message) (*entity.Message, error) {
+	return nil, s.Send(msg)
+}
+
+func TestReceiverListenOnRequestContext(t *testing.T) {
 	receiver := &receiver{
 		mux: chi.NewRouter(),
 		wg:  &sync.WaitGroup{},
-		uri: "/i/reserved-pattern",
+		uri: "/path",
 	}
 
-	err := receiver.ListenRequest(&m.DriverSender{})
+	err := receiver.Listen(&contextSender{t: t})
 
-	assert.Error(t, err)
+	assert.Nil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "/path", strings.NewReader("some-data"))
+
+	assert.Nil(t, err)
+
+	resp := httptest.NewRecorder()
+
+	receiver.mux.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
 }
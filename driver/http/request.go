@@ -3,17 +3,47 @@ package http
 import (
 	"bytes"
 	"context"
-	"io/ioutil"
 	"net/http"
+	"time"
 
+	"NATter/compression"
+	"NATter/entity"
+	"NATter/errtpl"
 	"NATter/log"
+)
+
+const (
+	headerContentEncoding = "Content-Encoding"
+	headerContentType     = "Content-Type"
+	headerSubject         = "X-Natter-Subject"
+	headerRequestID       = "X-Natter-Request-Id"
+	headerCallback        = "X-Natter-Callback"
+	headerStatus          = "X-Natter-Status"
+	headerLastEventID     = "Last-Event-ID"
 
-	"github.com/pkg/errors"
+	contentTypeJSON        = "application/json; charset=utf-8"
+	contentTypeEventStream = "text/event-stream"
 )
 
-func request(endpoint string, payload []byte) ([]byte, error) {
+// request posts the payload to the endpoint of the route with the client, the request is canceled
+// with the context or after the timeout if it is not zero. A response with a status
+// other than 2xx is returned as errtpl.StatusError.
+func request(ctx context.Context, route string, client *http.Client, timeout time.Duration, endpoint string, payload []byte, encoding string, header http.Header) (*entity.Message, error) {
+	payload, err := compression.Compress(encoding, payload)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
 	req, err := http.NewRequestWithContext(
-		context.Background(),
+		ctx,
 		http.MethodPost,
 		endpoint,
 		bytes.NewBuffer(payload),
@@ -23,7 +53,19 @@ func request(endpoint string, payload []byte) ([]byte, error) {
 		return nil, err
 	}
 
-	client := &http.Client{}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	if encoding != "" {
+		req.Header.Set(headerContentEncoding, encoding)
+	}
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	start := time.Now()
 
 	resp, err := client.Do(req)
 
@@ -31,13 +73,36 @@ func request(endpoint string, payload []byte) ([]byte, error) {
 		return nil, err
 	}
 
-	log.Debugf("requested to endpoint: %s", endpoint)
+	log.WithFields(log.Fields{
+		log.FieldRoute:    route,
+		log.FieldEndpoint: endpoint,
+		log.FieldStatus:   resp.StatusCode,
+		log.FieldDuration: time.Since(start).Seconds(),
+	}).Debug("requested")
 
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, errors.Errorf("unexpected response code %d from endpoint", resp.StatusCode)
+	respb, err := readBody(resp.Body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	respb, err = compression.Decompress(resp.Header.Get(headerContentEncoding), respb)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !errtpl.IsSuccess(resp.StatusCode) {
+		return nil, &errtpl.StatusError{
+			Code: resp.StatusCode,
+			Body: respb,
+		}
 	}
 
-	return ioutil.ReadAll(resp.Body)
+	return &entity.Message{
+		Payload: respb,
+		Status:  resp.StatusCode,
+	}, nil
 }
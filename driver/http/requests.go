@@ -0,0 +1,136 @@
+package http
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+const (
+	requestPending = "pending"
+	requestDone    = "done"
+	requestFailed  = "failed"
+
+	defaultRequestRetention = time.Minute * 10
+	pruneInterval           = time.Second
+)
+
+// asyncState is the state of an asynchronous request that a caller polls.
+type asyncState struct {
+	ID      string    `json:"id"`
+	URI     string    `json:"uri"`
+	State   string    `json:"state"`
+	Status  int       `json:"status,omitempty"` // status of the reply or of the failure
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+}
+
+// requestStore keeps states of asynchronous requests for the retention after they are finished.
+type requestStore struct {
+	retention time.Duration
+
+	mx       *sync.Mutex
+	requests map[string]*asyncState
+	pruned   time.Time
+}
+
+func newRequestStore(retention time.Duration) *requestStore {
+	if retention <= 0 {
+		retention = defaultRequestRetention
+	}
+
+	return &requestStore{
+		retention: retention,
+		mx:        &sync.Mutex{},
+		requests:  make(map[string]*asyncState),
+	}
+}
+
+func (s *requestStore) add(uri string) (string, error) {
+	id, err := requestID()
+
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	s.prune(now)
+
+	s.requests[id] = &asyncState{
+		ID:      id,
+		URI:     uri,
+		State:   requestPending,
+		Created: now,
+		Updated: now,
+	}
+
+	return id, nil
+}
+
+func (s *requestStore) finish(id string, status int, failed bool) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	state, ok := s.requests[id]
+
+	if !ok {
+		return
+	}
+
+	state.State = requestDone
+
+	if failed {
+		state.State = requestFailed
+	}
+
+	state.Status = status
+	state.Updated = time.Now()
+}
+
+func (s *requestStore) get(id string) (*asyncState, bool) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	state, ok := s.requests[id]
+
+	if !ok || s.expired(state, time.Now()) {
+		return nil, false
+	}
+
+	res := *state
+
+	return &res, true
+}
+
+func (s *requestStore) prune(now time.Time) {
+	if now.Sub(s.pruned) < pruneInterval {
+		return
+	}
+
+	s.pruned = now
+
+	for id, state := range s.requests {
+		if s.expired(state, now) {
+			delete(s.requests, id)
+		}
+	}
+}
+
+func (s *requestStore) expired(state *asyncState, now time.Time) bool {
+	return state.State != requestPending && now.Sub(state.Updated) > s.retention
+}
+
+func requestID() (string, error) {
+	b := make([]byte, 16)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
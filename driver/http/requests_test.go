@@ -0,0 +1,72 @@
+package http
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestStore(t *testing.T) {
+	store := newRequestStore(time.Minute)
+
+	id, err := store.add("/path")
+
+	assert.Nil(t, err)
+
+	state, ok := store.get(id)
+
+	assert.True(t, ok)
+	assert.Equal(t, id, state.ID)
+	assert.Equal(t, "/path", state.URI)
+	assert.Equal(t, requestPending, state.State)
+
+	store.finish(id, http.StatusOK, false)
+
+	state, ok = store.get(id)
+
+	assert.True(t, ok)
+	assert.Equal(t, requestDone, state.State)
+	assert.Equal(t, http.StatusOK, state.Status)
+
+	_, ok = store.get("unknown")
+
+	assert.False(t, ok)
+}
+
+func TestRequestStoreOnRetention(t *testing.T) {
+	store := newRequestStore(time.Millisecond)
+
+	done, err := store.add("/path")
+
+	assert.Nil(t, err)
+
+	pending, err := store.add("/path")
+
+	assert.Nil(t, err)
+
+	store.finish(done, http.StatusBadGateway, true)
+
+	time.Sleep(time.Millisecond * 2)
+
+	_, ok := store.get(done)
+
+	assert.False(t, ok)
+
+	_, ok = store.get(pending)
+
+	assert.True(t, ok)
+
+	store.pruned = time.Time{}
+
+	_, err = store.add("/path")
+
+	assert.Nil(t, err)
+	assert.Len(t, store.requests, 2)
+	assert.NotContains(t, store.requests, done)
+}
+
+func TestNewRequestStoreOnDefaultRetention(t *testing.T) {
+	assert.Equal(t, defaultRequestRetention, newRequestStore(0).retention)
+}
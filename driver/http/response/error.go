@@ -4,6 +4,7 @@ import (
 	"io/ioutil"
 	"net/http"
 
+	"NATter/compression"
 	"NATter/errtpl"
 	"NATter/log"
 
@@ -11,14 +12,34 @@ import (
 )
 
 func prepareError(err error) int {
-	if errors.Is(errors.Cause(err), errtpl.ErrNotFound) {
-		return http.StatusNotFound
+	if errors.Is(err, compression.ErrUnknownEncoding) {
+		return http.StatusUnsupportedMediaType
 	}
 
-	return http.StatusInternalServerError
+	if errors.Is(err, compression.ErrTooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+
+	return errtpl.Status(err)
 }
 
+// RenderError renders the error, the body of a failed request is logged if payloads are logged globally.
 func RenderError(w http.ResponseWriter, r *http.Request, err error) {
+	RenderRouteError(w, r, err, "", log.PayloadEnabled(nil))
+}
+
+// RenderRouteError renders the error of a request of the route, the body is logged if the route logs payloads.
+func RenderRouteError(w http.ResponseWriter, r *http.Request, err error, route string, logPayload bool) {
+	var serr *errtpl.StatusError
+
+	// The endpoint response is forwarded as is.
+	if errors.As(err, &serr) {
+		w.WriteHeader(serr.Code)
+		_, _ = w.Write(serr.Body)
+
+		return
+	}
+
 	httperr := prepareError(err)
 
 	http.Error(w, http.StatusText(httperr), httperr)
@@ -27,22 +48,38 @@ func RenderError(w http.ResponseWriter, r *http.Request, err error) {
 		return
 	}
 
-	body := []byte("empty body")
+	ent := log.WithFields(log.Fields{
+		log.FieldMethod: r.Method,
+		log.FieldURI:    r.RequestURI,
+		log.FieldStatus: httperr,
+	})
+
+	// Internal API requests belong to no route.
+	if route != "" {
+		ent = ent.WithFields(log.Fields{
+			log.FieldRoute: route,
+		})
+	}
+
+	if logPayload {
+		ent = ent.WithFields(log.Fields{
+			log.FieldBody: prepareBody(r),
+		})
+	}
+
+	ent.WithError(err).Error("unable handle request")
+}
 
-	if r.Body != nil {
-		var errread error
+func prepareBody(r *http.Request) string {
+	if r.Body == nil {
+		return "empty body"
+	}
 
-		body, errread = ioutil.ReadAll(r.Body)
+	body, err := ioutil.ReadAll(r.Body)
 
-		if errread != nil {
-			body = []byte("broken")
-		}
+	if err != nil {
+		return "broken"
 	}
 
-	log.WithFields(log.Fields{
-		"method": r.Method,
-		"uri":    r.RequestURI,
-		"status": httperr,
-		"body":   string(body),
-	}).Error(err)
+	return log.FormatPayload(body)
 }
@@ -2,14 +2,21 @@ package response
 
 import (
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"strings"
 	"testing"
 
+	"NATter/compression"
 	"NATter/errtpl"
+	"NATter/log"
 	m "NATter/mock"
 
 	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -24,6 +31,31 @@ func TestProcessError(t *testing.T) {
 			statusText: http.StatusText(http.StatusNotFound),
 			statusCode: http.StatusNotFound,
 		},
+		{
+			err:        errors.Wrap(errtpl.ErrTooManyRequests, "rate limit exceeded"),
+			statusText: http.StatusText(http.StatusTooManyRequests),
+			statusCode: http.StatusTooManyRequests,
+		},
+		{
+			err:        errors.Wrap(errtpl.ErrServiceUnavailable, "circuit breaker is open"),
+			statusText: http.StatusText(http.StatusServiceUnavailable),
+			statusCode: http.StatusServiceUnavailable,
+		},
+		{
+			err:        errors.Wrap(errtpl.ErrGatewayTimeout, "request timeout"),
+			statusText: http.StatusText(http.StatusGatewayTimeout),
+			statusCode: http.StatusGatewayTimeout,
+		},
+		{
+			err:        errors.Wrap(compression.ErrUnknownEncoding, "deflate"),
+			statusText: http.StatusText(http.StatusUnsupportedMediaType),
+			statusCode: http.StatusUnsupportedMediaType,
+		},
+		{
+			err:        compression.ErrTooLarge,
+			statusText: http.StatusText(http.StatusRequestEntityTooLarge),
+			statusCode: http.StatusRequestEntityTooLarge,
+		},
 		{
 			err:        errors.New("unknown error"),
 			statusText: http.StatusText(http.StatusInternalServerError),
@@ -40,6 +72,17 @@ func TestProcessError(t *testing.T) {
 	}
 }
 
+func TestProcessErrorOnStatusError(t *testing.T) {
+	w := httptest.NewRecorder()
+	RenderError(w, &http.Request{}, &errtpl.StatusError{
+		Code: http.StatusBadRequest,
+		Body: []byte(`{"error":"bad email"}`),
+	})
+
+	assert.Equal(t, `{"error":"bad email"}`, w.Body.String())
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
 func TestProcessErrorOnBrokenBody(t *testing.T) {
 	w := httptest.NewRecorder()
 	RenderError(w, &http.Request{Body: &m.ReadCloserErr{}}, errors.New("error"))
@@ -47,3 +90,96 @@ func TestProcessErrorOnBrokenBody(t *testing.T) {
 	assert.Equal(t, fmt.Sprintf("%s\n", http.StatusText(http.StatusInternalServerError)), w.Body.String())
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
 }
+
+func TestRenderRouteError(t *testing.T) {
+	hook := test.NewGlobal()
+
+	RenderRouteError(httptest.NewRecorder(), &http.Request{}, errors.New("error"), "route", false)
+
+	assert.Equal(t, 1, len(hook.Entries))
+	assert.Equal(t, "route", hook.LastEntry().Data["route"])
+
+	hook.Reset()
+
+	RenderError(httptest.NewRecorder(), &http.Request{}, errors.New("error"))
+
+	assert.Equal(t, 1, len(hook.Entries))
+	assert.NotContains(t, hook.LastEntry().Data, "route")
+}
+
+func TestProcessErrorOnPayloadLogging(t *testing.T) {
+	err := log.SetConfig(&log.Config{
+		Level: "error",
+		Payload: log.ConfigPayload{
+			Enable: true,
+			Redact: []string{"password"},
+		},
+	})
+
+	assert.Nil(t, err)
+
+	hook := test.NewGlobal()
+
+	w := httptest.NewRecorder()
+	RenderError(w, &http.Request{
+		Body: ioutil.NopCloser(strings.NewReader(`{"password":"secret"}`)),
+	}, errors.New("error"))
+
+	assert.Equal(t, 1, len(hook.Entries))
+	assert.Equal(t, `{"password":"[REDACTED]"}`, hook.LastEntry().Data["body"])
+
+	hook.Reset()
+
+	err = log.SetConfig(&log.Config{Level: "info"})
+
+	assert.Nil(t, err)
+
+	RenderError(w, &http.Request{
+		Body: ioutil.NopCloser(strings.NewReader(`{"password":"secret"}`)),
+	}, errors.New("error"))
+
+	assert.Equal(t, 1, len(hook.Entries))
+	assert.NotContains(t, hook.LastEntry().Data, "body")
+
+	hook.Reset()
+
+	logrus.SetOutput(os.Stderr)
+}
+
+func TestRenderRouteErrorOnPayloadLogging(t *testing.T) {
+	err := log.SetConfig(&log.Config{Level: "error"})
+
+	assert.Nil(t, err)
+
+	hook := test.NewGlobal()
+
+	w := httptest.NewRecorder()
+	RenderRouteError(w, &http.Request{
+		Body: ioutil.NopCloser(strings.NewReader(`some-data`)),
+	}, errors.New("error"), "route", true)
+
+	assert.Equal(t, 1, len(hook.Entries))
+	assert.Equal(t, `some-data`, hook.LastEntry().Data["body"])
+	assert.Equal(t, "route", hook.LastEntry().Data["route"])
+
+	hook.Reset()
+
+	err = log.SetConfig(&log.Config{Level: "error", Payload: log.ConfigPayload{Enable: true}})
+
+	assert.Nil(t, err)
+
+	RenderRouteError(w, &http.Request{
+		Body: ioutil.NopCloser(strings.NewReader(`some-data`)),
+	}, errors.New("error"), "route", false)
+
+	assert.Equal(t, 1, len(hook.Entries))
+	assert.NotContains(t, hook.LastEntry().Data, "body")
+
+	hook.Reset()
+
+	err = log.SetConfig(&log.Config{Level: "info"})
+
+	assert.Nil(t, err)
+
+	logrus.SetOutput(os.Stderr)
+}
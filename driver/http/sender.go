@@ -1,27 +1,61 @@
 package http
 
 import (
+	"net/http"
+	"time"
+
+	"NATter/entity"
 	"NATter/log"
+	"NATter/msgtpl"
 )
 
 type sender struct {
-	endpoint string
+	endpoint    *msgtpl.Template
+	client      *http.Client
+	compression string
+	timeout     time.Duration
+	route       string
 }
 
-func (s *sender) Send(payload []byte) error {
-	_, err := request(s.endpoint, payload)
+func (s *sender) Send(msg *entity.Message) error {
+	endpoint, err := s.endpoint.Execute(msg)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = request(msg.Context(), s.route, s.client, s.timeout, endpoint, msg.Payload, s.compression, header(msg))
 
 	return err
 }
 
-func (s *sender) Request(payload []byte) ([]byte, error) {
-	respb, err := request(s.endpoint, payload)
+func (s *sender) Request(msg *entity.Message) (*entity.Message, error) {
+	endpoint, err := s.endpoint.Execute(msg)
 
 	if err != nil {
 		return nil, err
 	}
 
-	log.Debugf("received response from endpoint: %s", s.endpoint)
+	resp, err := request(msg.Context(), s.route, s.client, s.timeout, endpoint, msg.Payload, s.compression, header(msg))
+
+	if err != nil {
+		return nil, err
+	}
+
+	log.WithFields(log.Fields{
+		log.FieldRoute:    s.route,
+		log.FieldEndpoint: endpoint,
+	}).Debug("received response")
+
+	return resp, nil
+}
+
+func header(msg *entity.Message) http.Header {
+	header := http.Header{}
+
+	if msg.Subject != "" {
+		header.Set(headerSubject, msg.Subject)
+	}
 
-	return respb, nil
+	return header
 }
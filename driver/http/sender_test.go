@@ -1,10 +1,18 @@
 package http
 
 import (
+	"context"
+	"errors"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
+
+	"NATter/compression"
+	"NATter/entity"
+	"NATter/errtpl"
+	"NATter/msgtpl"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -20,10 +28,10 @@ func TestSenderSend(t *testing.T) {
 	}))
 
 	sender := &sender{
-		endpoint: srvr.URL,
+		endpoint: msgtpl.New(srvr.URL),
 	}
 
-	err := sender.Send([]byte("request-data"))
+	err := sender.Send(entity.NewMessage([]byte("request-data")))
 
 	assert.Nil(t, err)
 }
@@ -44,22 +52,190 @@ func TestSenderRequest(t *testing.T) {
 	}))
 
 	sender := &sender{
-		endpoint: srvr.URL,
+		endpoint: msgtpl.New(srvr.URL),
+	}
+
+	resp, err := sender.Request(entity.NewMessage([]byte("request-data")))
+
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("response-data"), resp.Payload)
+	assert.Equal(t, http.StatusOK, resp.Status)
+}
+
+func TestSenderRequestOnCreated(t *testing.T) {
+	srvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+
+		_, err := w.Write([]byte("response-data"))
+
+		assert.Nil(t, err)
+	}))
+
+	sender := &sender{
+		endpoint: msgtpl.New(srvr.URL),
+	}
+
+	resp, err := sender.Request(entity.NewMessage([]byte("request-data")))
+
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("response-data"), resp.Payload)
+	assert.Equal(t, http.StatusCreated, resp.Status)
+}
+
+func TestSenderRequestOnStatusError(t *testing.T) {
+	srvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+
+		_, err := w.Write([]byte(`{"error":"bad email"}`))
+
+		assert.Nil(t, err)
+	}))
+
+	sender := &sender{
+		endpoint: msgtpl.New(srvr.URL),
+	}
+
+	resp, err := sender.Request(entity.NewMessage([]byte("request-data")))
+
+	assert.Nil(t, resp)
+	assert.Equal(t, &errtpl.StatusError{
+		Code: http.StatusBadRequest,
+		Body: []byte(`{"error":"bad email"}`),
+	}, err)
+}
+
+func TestSenderSendOnCompression(t *testing.T) {
+	srvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+
+		assert.Equal(t, "gzip", r.Header.Get("Content-Encoding"))
+
+		reqb, err := ioutil.ReadAll(r.Body)
+		assert.Nil(t, err)
+
+		reqb, err = compression.Decompress("gzip", reqb)
+		assert.Nil(t, err)
+		assert.EqualValues(t, []byte("request-data"), reqb)
+	}))
+
+	sender := &sender{
+		endpoint:    msgtpl.New(srvr.URL),
+		compression: "gzip",
 	}
 
-	respb, err := sender.Request([]byte("request-data"))
+	err := sender.Send(entity.NewMessage([]byte("request-data")))
 
 	assert.Nil(t, err)
-	assert.Equal(t, []byte("response-data"), respb)
+}
+
+func TestSenderSendOnUnknownCompression(t *testing.T) {
+	sender := &sender{
+		endpoint:    msgtpl.New("incorrect-address"),
+		compression: "unknown",
+	}
+
+	err := sender.Send(entity.NewMessage([]byte("request-data")))
+
+	assert.Error(t, err)
 }
 
 func TestSenderRequestOnError(t *testing.T) {
 	sender := &sender{
-		endpoint: "incorrect-address",
+		endpoint: msgtpl.New("incorrect-address"),
+	}
+
+	resp, err := sender.Request(entity.NewMessage([]byte("request-data")))
+
+	assert.Error(t, err)
+	assert.Nil(t, resp)
+}
+
+func TestSenderSendOnTemplate(t *testing.T) {
+	srvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+
+		assert.Equal(t, "/users/42/eu", r.URL.Path)
+	}))
+
+	sender := &sender{
+		endpoint: msgtpl.New(srvr.URL + "/users/{{ .uri.id }}/{{ .json.region }}"),
+	}
+
+	err := sender.Send(&entity.Message{
+		Payload: []byte(`{"region":"eu"}`),
+		Params:  map[string]string{"id": "42"},
+	})
+
+	assert.Nil(t, err)
+}
+
+func TestSenderSendOnTemplateError(t *testing.T) {
+	sender := &sender{
+		endpoint: msgtpl.New("http://localhost/users/{{ .uri.id }}"),
 	}
 
-	respb, err := sender.Request([]byte("request-data"))
+	err := sender.Send(entity.NewMessage([]byte("request-data")))
 
 	assert.Error(t, err)
-	assert.Nil(t, respb)
+}
+
+func TestSenderSendOnSubject(t *testing.T) {
+	srvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+
+		assert.Equal(t, "user.42.created", r.Header.Get("X-Natter-Subject"))
+		assert.Equal(t, "/hooks/42/created", r.URL.Path)
+	}))
+
+	sender := &sender{
+		endpoint: msgtpl.New(srvr.URL + "/hooks/{1}/created"),
+	}
+
+	err := sender.Send(&entity.Message{
+		Payload:   []byte("request-data"),
+		Subject:   "user.42.created",
+		Wildcards: []string{"42"},
+	})
+
+	assert.Nil(t, err)
+}
+
+func TestSenderSendOnTimeout(t *testing.T) {
+	release := make(chan struct{})
+
+	srvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+
+	defer srvr.Close()
+	defer close(release)
+
+	sender := &sender{
+		endpoint: msgtpl.New(srvr.URL),
+		timeout:  time.Millisecond * 10,
+	}
+
+	err := sender.Send(entity.NewMessage([]byte("request-data")))
+
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestSenderRequestOnCanceledContext(t *testing.T) {
+	srvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Fail(t, "canceled request is sent")
+	}))
+
+	defer srvr.Close()
+
+	sender := &sender{
+		endpoint: msgtpl.New(srvr.URL),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	resp, err := sender.Request(entity.NewMessage([]byte("request-data")).WithContext(ctx))
+
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Nil(t, resp)
 }
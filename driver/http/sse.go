@@ -0,0 +1,101 @@
+package http
+
+import (
+	"context"
+
+	"NATter/driver"
+	"NATter/entity"
+	"NATter/log"
+
+	"github.com/pkg/errors"
+)
+
+const SSEDriverName = entity.DriverSSE
+
+// sseConn streams messages to clients of the HTTP server as Server-Sent Events.
+type sseConn struct {
+	conn *conn
+}
+
+// NewSSEConn returns the connection streaming through the server of the connection created by NewConn.
+func NewSSEConn(httpConn driver.Conn) driver.Conn {
+	return &sseConn{
+		conn: httpConn.(*conn),
+	}
+}
+
+// Serve does nothing since streams are served by the HTTP connection.
+func (c *sseConn) Serve(context.Context) error {
+	return nil
+}
+
+func (c *sseConn) Close() error {
+	return nil
+}
+
+func (c *sseConn) Receiver(*entity.Route) driver.Receiver {
+	return &sseReceiver{}
+}
+
+func (c *sseConn) Sender(route *entity.Route) driver.Sender {
+	c.conn.routes = append(c.conn.routes, route)
+
+	st := c.conn.stream(route.URI)
+	st.addRoute()
+
+	return &sseSender{
+		stream:     st,
+		route:      route.ID,
+		logPayload: log.PayloadEnabled(route.LogPayload),
+	}
+}
+
+type sseSender struct {
+	stream     *stream
+	route      string
+	logPayload bool
+}
+
+// Resume makes clients of the stream resumed from the broker by the replayer, if all routes of the stream have replayers.
+func (s *sseSender) Resume(rep driver.Replayer) {
+	s.stream.addReplayer(rep)
+}
+
+// Send streams the message to the clients, a replayed message is written to the client it is replayed to.
+func (s *sseSender) Send(msg *entity.Message) error {
+	if target, ok := msg.Context().Value(replayKey{}).(*replayTarget); ok && target.stream == s.stream {
+		return target.write(&event{subject: msg.Subject, data: msg.Payload, pos: msg.Position})
+	}
+
+	count := s.stream.publish(msg.Subject, msg.Payload, msg.Position)
+
+	ent := log.WithFields(log.Fields{
+		log.FieldRoute: s.route,
+		log.FieldURI:   s.stream.uri,
+		log.FieldTopic: msg.Subject,
+	})
+
+	if s.logPayload {
+		ent = ent.WithFields(log.Fields{
+			log.FieldPayload: log.FormatPayload(msg.Payload),
+		})
+	}
+
+	ent.Debugf("streamed to %d clients", count)
+
+	return nil
+}
+
+func (s *sseSender) Request(*entity.Message) (*entity.Message, error) {
+	return nil, errors.New("request is not supported by sse")
+}
+
+type sseReceiver struct{}
+
+func (r *sseReceiver) Listen(driver.Sender) error {
+	return errors.New("receiving is not supported by sse")
+}
+
+func (r *sseReceiver) ListenRequest(driver.Sender) error {
+	return errors.New("response is not supported by sse")
+}
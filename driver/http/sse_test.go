@@ -0,0 +1,299 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"NATter/driver"
+	"NATter/entity"
+	m "NATter/mock"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// readEvent reads lines of the next event of the stream without comments.
+func readEvent(t *testing.T, r *bufio.Reader) []string {
+	t.Helper()
+
+	var lines []string
+
+	for {
+		line, err := r.ReadString('\n')
+
+		if !assert.Nil(t, err) {
+			return lines
+		}
+
+		line = strings.TrimSuffix(line, "\n")
+
+		switch {
+		case line == "" && len(lines) != 0:
+			return lines
+		case line == "" || strings.HasPrefix(line, ":"):
+		default:
+			lines = append(lines, line)
+		}
+	}
+}
+
+func subscribeStream(t *testing.T, srvr *httptest.Server, path, lastID string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, srvr.URL+path, nil)
+	assert.Nil(t, err)
+
+	if lastID != "" {
+		req.Header.Set(headerLastEventID, lastID)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+
+	return resp
+}
+
+// waitStreamClients waits until the stream has the number of clients.
+func waitStreamClients(t *testing.T, s *stream, count int) {
+	t.Helper()
+
+	assert.Eventually(t, func() bool {
+		s.mx.Lock()
+		defer s.mx.Unlock()
+
+		return len(s.clients) == count
+	}, time.Second, time.Millisecond)
+}
+
+func TestSSEConnSender(t *testing.T) {
+	httpConn, err := NewConn(&ConnConfig{})
+	assert.Nil(t, err)
+
+	c := httpConn.(*conn)
+	snd := NewSSEConn(c).Sender(&entity.Route{URI: "/events"})
+
+	srvr := httptest.NewServer(c.mux)
+	defer srvr.Close()
+
+	resp := subscribeStream(t, srvr, "/events?subject=user.*.created", "")
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, contentTypeEventStream, resp.Header.Get(headerContentType))
+
+	waitStreamClients(t, c.streams["/events"], 1)
+
+	assert.Nil(t, snd.Send(&entity.Message{Payload: []byte("deleted"), Subject: "user.42.deleted"}))
+	assert.Nil(t, snd.Send(&entity.Message{Payload: []byte("created"), Subject: "user.42.created"}))
+
+	assert.Equal(t, []string{"id: 2", "data: created"}, readEvent(t, bufio.NewReader(resp.Body)))
+}
+
+func TestSSEConnSenderOnLastEventID(t *testing.T) {
+	httpConn, err := NewConn(&ConnConfig{})
+	assert.Nil(t, err)
+
+	c := httpConn.(*conn)
+	snd := NewSSEConn(c).Sender(&entity.Route{URI: "/events"})
+
+	srvr := httptest.NewServer(c.mux)
+	defer srvr.Close()
+
+	for _, payload := range []string{"first", "second", "third"} {
+		assert.Nil(t, snd.Send(&entity.Message{Payload: []byte(payload)}))
+	}
+
+	resp := subscribeStream(t, srvr, "/events", "1")
+	defer resp.Body.Close()
+
+	r := bufio.NewReader(resp.Body)
+
+	assert.Equal(t, []string{"id: 2", "data: second"}, readEvent(t, r))
+	assert.Equal(t, []string{"id: 3", "data: third"}, readEvent(t, r))
+}
+
+// testReplayer replays the messages following the positions to the sender, the live message
+// is published in the middle of the replay.
+type testReplayer struct {
+	sender    driver.Sender
+	positions []*entity.Position
+	msgs      []*entity.Message
+	live      *entity.Message
+}
+
+func (r *testReplayer) Positions() ([]*entity.Position, error) {
+	return r.positions, nil
+}
+
+func (r *testReplayer) Replay(ctx context.Context, after []*entity.Position) error {
+	for i, msg := range r.msgs {
+		if msg.Position.Offset <= after[0].Offset {
+			continue
+		}
+
+		if err := r.sender.Send(msg.WithContext(ctx)); err != nil {
+			return err
+		}
+
+		if i == 1 && r.live != nil {
+			if err := r.sender.Send(r.live); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// positionMessage returns the message of the topic partition at the offset.
+func positionMessage(payload string, partition int32, offset int64) *entity.Message {
+	return &entity.Message{
+		Payload:  []byte(payload),
+		Subject:  "topic",
+		Position: &entity.Position{Topic: "topic", Partition: partition, Offset: offset},
+	}
+}
+
+func TestSSEConnSenderOnResume(t *testing.T) {
+	httpConn, err := NewConn(&ConnConfig{})
+	assert.Nil(t, err)
+
+	c := httpConn.(*conn)
+	snd := NewSSEConn(c).Sender(&entity.Route{URI: "/events"})
+
+	snd.(driver.Resumer).Resume(&testReplayer{
+		sender: snd,
+		msgs: []*entity.Message{
+			positionMessage("third", 0, 3),
+			positionMessage("fourth", 0, 4),
+			positionMessage("fifth", 0, 5),
+		},
+		live: positionMessage("sixth", 0, 6),
+	})
+
+	srvr := httptest.NewServer(c.mux)
+	defer srvr.Close()
+
+	resp := subscribeStream(t, srvr, "/events", "topic:0:3")
+	defer resp.Body.Close()
+
+	r := bufio.NewReader(resp.Body)
+
+	// The live message is held until the client is resumed.
+	assert.Equal(t, []string{"id: topic:0:4", "data: fourth"}, readEvent(t, r))
+	assert.Equal(t, []string{"id: topic:0:5", "data: fifth"}, readEvent(t, r))
+	assert.Equal(t, []string{"id: topic:0:6", "data: sixth"}, readEvent(t, r))
+
+	// The message the client got already is skipped.
+	assert.Nil(t, snd.Send(positionMessage("sixth", 0, 6)))
+	assert.Nil(t, snd.Send(positionMessage("seventh", 0, 7)))
+
+	assert.Equal(t, []string{"id: topic:0:7", "data: seventh"}, readEvent(t, r))
+}
+
+func TestSSEConnSenderOnNewResumedClient(t *testing.T) {
+	httpConn, err := NewConn(&ConnConfig{})
+	assert.Nil(t, err)
+
+	c := httpConn.(*conn)
+	snd := NewSSEConn(c).Sender(&entity.Route{URI: "/events"})
+
+	snd.(driver.Resumer).Resume(&testReplayer{
+		positions: []*entity.Position{
+			{Topic: "topic", Partition: 0, Offset: 5},
+			{Topic: "topic", Partition: 1, Offset: -1},
+		},
+	})
+
+	srvr := httptest.NewServer(c.mux)
+	defer srvr.Close()
+
+	resp := subscribeStream(t, srvr, "/events", "")
+	defer resp.Body.Close()
+
+	waitStreamClients(t, c.streams["/events"], 1)
+
+	// The client starts at the newest messages, the ID lists positions of every partition.
+	assert.Eventually(t, func() bool {
+		c.streams["/events"].mx.Lock()
+		defer c.streams["/events"].mx.Unlock()
+
+		for cl := range c.streams["/events"].clients {
+			return !cl.resuming
+		}
+
+		return false
+	}, time.Second, time.Millisecond)
+
+	assert.Nil(t, snd.Send(positionMessage("fifth", 0, 5)))
+	assert.Nil(t, snd.Send(positionMessage("sixth", 0, 6)))
+
+	assert.Equal(t, []string{"id: topic:0:6,topic:1:-1", "data: sixth"}, readEvent(t, bufio.NewReader(resp.Body)))
+}
+
+func TestSSEConnSenderOnRouteWithoutReplayer(t *testing.T) {
+	httpConn, err := NewConn(&ConnConfig{})
+	assert.Nil(t, err)
+
+	c := httpConn.(*conn)
+	sse := NewSSEConn(c)
+
+	snd := sse.Sender(&entity.Route{URI: "/events", Topic: "topic1"})
+	snd.(driver.Resumer).Resume(&testReplayer{})
+
+	sse.Sender(&entity.Route{URI: "/events", Topic: "topic2"})
+
+	srvr := httptest.NewServer(c.mux)
+	defer srvr.Close()
+
+	assert.Nil(t, snd.Send(positionMessage("first", 0, 1)))
+	assert.Nil(t, snd.Send(positionMessage("second", 0, 2)))
+
+	// Another route of the stream can not replay, so clients are resumed from memory.
+	resp := subscribeStream(t, srvr, "/events", "1")
+	defer resp.Body.Close()
+
+	assert.Equal(t, []string{"id: 2", "data: second"}, readEvent(t, bufio.NewReader(resp.Body)))
+}
+
+func TestSSEConnServeOnShutdown(t *testing.T) {
+	httpConn, err := NewConn(&ConnConfig{Port: "0"})
+	assert.Nil(t, err)
+
+	c := httpConn.(*conn)
+	NewSSEConn(c).Sender(&entity.Route{URI: "/events"})
+
+	srvr := httptest.NewServer(c.mux)
+	defer srvr.Close()
+
+	resp := subscribeStream(t, srvr, "/events", "")
+	defer resp.Body.Close()
+
+	waitStreamClients(t, c.streams["/events"], 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.Nil(t, c.Serve(ctx))
+
+	waitStreamClients(t, c.streams["/events"], 0)
+}
+
+func TestSSEConnReceiver(t *testing.T) {
+	httpConn, err := NewConn(&ConnConfig{})
+	assert.Nil(t, err)
+
+	c := NewSSEConn(httpConn)
+
+	assert.Error(t, c.Receiver(&entity.Route{}).Listen(&m.DriverSender{}))
+	assert.Error(t, c.Receiver(&entity.Route{}).ListenRequest(&m.DriverSender{}))
+
+	resp, err := c.Sender(&entity.Route{URI: "/events"}).Request(entity.NewMessage(nil))
+
+	assert.Error(t, err)
+	assert.Nil(t, resp)
+}
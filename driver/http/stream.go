@@ -0,0 +1,543 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"NATter/driver"
+	"NATter/entity"
+	"NATter/log"
+	"NATter/msgtpl"
+)
+
+const (
+	defaultStreamReplay    = 100
+	defaultStreamHeartbeat = time.Second * 15
+	defaultStreamBuffer    = 64
+
+	subjectParam = "subject"
+)
+
+type SSEConfig struct {
+	Replay    int           // events kept per URI to resume clients by Last-Event-ID
+	Heartbeat time.Duration // interval of comments keeping idle streams open
+	Buffer    int           // events queued per client, a slower client is disconnected
+}
+
+type event struct {
+	id      uint64
+	subject string
+	data    []byte
+	pos     *entity.Position
+}
+
+// stream is a set of clients receiving events of a URI as Server-Sent Events. If every route
+// streaming to the URI replays its messages from the broker, the ID of an event lists positions
+// of the messages the client got, and the client is resumed from the broker by Last-Event-ID.
+// Otherwise events are numbered by the stream and the client is resumed from the last events
+// kept in memory, so the numbering starts over on restart.
+type stream struct {
+	uri    string
+	replay int
+	buffer int
+
+	mx        *sync.Mutex
+	seq       uint64
+	history   []*event // the last events to resume clients from
+	clients   map[*streamClient]struct{}
+	routes    int
+	replayers []driver.Replayer
+}
+
+type streamClient struct {
+	uri     string
+	subject string
+	events  chan *event
+	once    *sync.Once
+	done    chan struct{}
+
+	// Live events are held until the client is resumed from the broker, guarded by the stream.
+	resuming bool
+	pending  []*event
+
+	positions map[string]*entity.Position // the last positions the client got by topic and partition
+}
+
+// replayKey is the context key of the client that messages are replayed to.
+type replayKey struct{}
+
+// replayTarget writes replayed events of the stream to a client.
+type replayTarget struct {
+	stream *stream
+	write  func(ev *event) error
+}
+
+func newStream(uri string, cfg *SSEConfig) *stream {
+	s := &stream{
+		uri:     uri,
+		replay:  defaultStreamReplay,
+		buffer:  defaultStreamBuffer,
+		mx:      &sync.Mutex{},
+		clients: make(map[*streamClient]struct{}),
+	}
+
+	if cfg != nil && cfg.Replay > 0 {
+		s.replay = cfg.Replay
+	}
+
+	if cfg != nil && cfg.Buffer > 0 {
+		s.buffer = cfg.Buffer
+	}
+
+	return s
+}
+
+// addRoute counts the route streaming to the URI, the replayer of the route is added by addReplayer.
+func (s *stream) addRoute() {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	s.routes++
+}
+
+func (s *stream) addReplayer(rep driver.Replayer) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	s.replayers = append(s.replayers, rep)
+}
+
+// resumable reports whether clients are resumed from the broker.
+func (s *stream) resumable() bool {
+	return len(s.replayers) != 0 && len(s.replayers) == s.routes
+}
+
+// subscribe adds a client of the subject pattern, any subject if it is empty. It returns events
+// following the last event the client has received if they are still kept. A client of the stream
+// resumed from the broker gets no events until it is resumed, see resume.
+func (s *stream) subscribe(subject, lastID string) (*streamClient, []*event) {
+	cl := &streamClient{
+		uri:       s.uri,
+		subject:   subject,
+		events:    make(chan *event, s.buffer),
+		once:      &sync.Once{},
+		done:      make(chan struct{}),
+		positions: make(map[string]*entity.Position),
+	}
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	s.clients[cl] = struct{}{}
+
+	if s.resumable() {
+		cl.resuming = true
+
+		return cl, nil
+	}
+
+	return cl, s.missed(cl, lastID)
+}
+
+// resume starts the client at the positions of the last event ID and replays the messages it missed
+// from the broker through the routes, a new client starts at the newest messages. Events published
+// meanwhile are sent to the client after that.
+func (s *stream) resume(ctx context.Context, w io.Writer, flusher http.Flusher, cl *streamClient, lastID string) error {
+	after, ok := parsePositions(lastID)
+
+	if !ok {
+		for _, rep := range s.replayers {
+			positions, err := rep.Positions()
+
+			if err != nil {
+				return err
+			}
+
+			cl.start(positions)
+		}
+
+		s.resumed(cl)
+
+		return nil
+	}
+
+	cl.start(after)
+
+	ctx = context.WithValue(ctx, replayKey{}, &replayTarget{
+		stream: s,
+		write: func(ev *event) error {
+			if !cl.matches(ev.subject) {
+				return nil
+			}
+
+			if err := cl.write(w, ev); err != nil {
+				return err
+			}
+
+			flusher.Flush()
+
+			return nil
+		},
+	})
+
+	for _, rep := range s.replayers {
+		if err := rep.Replay(ctx, after); err != nil {
+			return err
+		}
+	}
+
+	s.resumed(cl)
+
+	return nil
+}
+
+// resumed passes the held events to the client.
+func (s *stream) resumed(cl *streamClient) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	for _, ev := range cl.pending {
+		if !cl.push(ev) {
+			break
+		}
+	}
+
+	cl.pending = nil
+	cl.resuming = false
+}
+
+func (s *stream) missed(cl *streamClient, lastID string) []*event {
+	if lastID == "" {
+		return nil
+	}
+
+	id, err := strconv.ParseUint(lastID, 10, 64)
+
+	if err != nil {
+		return nil
+	}
+
+	var res []*event
+
+	for i, ev := range s.history {
+		if ev.id != id {
+			continue
+		}
+
+		for _, ev := range s.history[i+1:] {
+			if cl.matches(ev.subject) {
+				res = append(res, ev)
+			}
+		}
+
+		break
+	}
+
+	return res
+}
+
+func (s *stream) unsubscribe(cl *streamClient) {
+	s.mx.Lock()
+	delete(s.clients, cl)
+	s.mx.Unlock()
+
+	cl.close()
+}
+
+// publish queues the event to clients subscribed to the subject, it returns their number.
+// The position of the message the event is made of identifies it in a stream resumed from the broker.
+func (s *stream) publish(subject string, data []byte, pos *entity.Position) int {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	s.seq++
+
+	ev := &event{
+		id:      s.seq,
+		subject: subject,
+		data:    data,
+	}
+
+	if s.resumable() {
+		ev.pos = pos
+	} else {
+		s.history = append(s.history, ev)
+
+		if len(s.history) > s.replay {
+			s.history = s.history[len(s.history)-s.replay:]
+		}
+	}
+
+	count := 0
+
+	for cl := range s.clients {
+		if !cl.matches(subject) {
+			continue
+		}
+
+		if cl.resuming {
+			if cl.hold(ev, s.buffer) {
+				count++
+			}
+
+			continue
+		}
+
+		if cl.push(ev) {
+			count++
+		}
+	}
+
+	return count
+}
+
+func (s *stream) close() {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	for cl := range s.clients {
+		cl.close()
+	}
+}
+
+func (s *stream) handle(heartbeat time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+
+		if !ok {
+			http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+
+			return
+		}
+
+		// A stream outlives HTTP.WRITE_TIMEOUT, a gone client is noticed by a failed heartbeat instead.
+		// A writer that can not set the deadline has none.
+		if wd, ok := w.(writeDeadliner); ok {
+			_ = wd.SetWriteDeadline(time.Time{})
+		}
+
+		lastID := r.Header.Get(headerLastEventID)
+
+		cl, missed := s.subscribe(r.URL.Query().Get(subjectParam), lastID)
+		defer s.unsubscribe(cl)
+
+		w.Header().Set(headerContentType, contentTypeEventStream)
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		for _, ev := range missed {
+			if err := cl.write(w, ev); err != nil {
+				return
+			}
+		}
+
+		if cl.resuming {
+			if err := s.resume(r.Context(), w, flusher, cl, lastID); err != nil {
+				log.WithFields(log.Fields{
+					log.FieldURI: s.uri,
+				}).WithError(err).Warn("unable resume stream client")
+
+				return
+			}
+		}
+
+		flusher.Flush()
+
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+
+		for {
+			var err error
+
+			select {
+			case <-r.Context().Done():
+				return
+			case <-cl.done:
+				return
+			case ev := <-cl.events:
+				err = cl.write(w, ev)
+			case <-ticker.C:
+				_, err = io.WriteString(w, ":\n\n")
+			}
+
+			if err != nil {
+				log.WithFields(log.Fields{
+					log.FieldURI: s.uri,
+				}).WithError(err).Debug("stream is closed")
+
+				return
+			}
+
+			flusher.Flush()
+		}
+	}
+}
+
+// writeDeadliner is a response writer that sets its write deadline, like the one of the server since Go 1.20.
+type writeDeadliner interface {
+	SetWriteDeadline(deadline time.Time) error
+}
+
+// writeEvent writes the event with the ID in the text/event-stream format, every line of the data
+// is a separate data field.
+func writeEvent(w io.Writer, id string, ev *event) error {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "id: %s\n", id)
+
+	for _, line := range bytes.Split(ev.data, []byte("\n")) {
+		buf.WriteString("data: ")
+		buf.Write(bytes.TrimSuffix(line, []byte("\r")))
+		buf.WriteString("\n")
+	}
+
+	buf.WriteString("\n")
+
+	_, err := w.Write(buf.Bytes())
+
+	return err
+}
+
+// positionKey identifies the partition of the position.
+func positionKey(pos *entity.Position) string {
+	return pos.Topic + ":" + strconv.FormatInt(int64(pos.Partition), 10)
+}
+
+// formatPositions returns the event ID made of the positions, topic:partition:offset separated by commas.
+func formatPositions(positions map[string]*entity.Position) string {
+	res := make([]string, 0, len(positions))
+
+	for key, pos := range positions {
+		res = append(res, key+":"+strconv.FormatInt(pos.Offset, 10))
+	}
+
+	sort.Strings(res)
+
+	return strings.Join(res, ",")
+}
+
+// parsePositions parses the event ID made by formatPositions.
+func parsePositions(id string) ([]*entity.Position, bool) {
+	if id == "" {
+		return nil, false
+	}
+
+	var res []*entity.Position
+
+	for _, field := range strings.Split(id, ",") {
+		parts := strings.Split(field, ":")
+
+		if len(parts) < 3 {
+			return nil, false
+		}
+
+		n := len(parts)
+
+		partition, err := strconv.ParseInt(parts[n-2], 10, 32)
+
+		if err != nil {
+			return nil, false
+		}
+
+		offset, err := strconv.ParseInt(parts[n-1], 10, 64)
+
+		if err != nil {
+			return nil, false
+		}
+
+		res = append(res, &entity.Position{
+			Topic:     strings.Join(parts[:n-2], ":"),
+			Partition: int32(partition),
+			Offset:    offset,
+		})
+	}
+
+	return res, true
+}
+
+// start sets the positions the client is resumed from.
+func (c *streamClient) start(positions []*entity.Position) {
+	for _, pos := range positions {
+		c.positions[positionKey(pos)] = pos
+	}
+}
+
+// write writes the event unless the client got its message already. The ID of an event with
+// a position lists the last positions the client got in every partition.
+func (c *streamClient) write(w io.Writer, ev *event) error {
+	if ev.pos == nil {
+		return writeEvent(w, strconv.FormatUint(ev.id, 10), ev)
+	}
+
+	key := positionKey(ev.pos)
+
+	if last, ok := c.positions[key]; ok && ev.pos.Offset <= last.Offset {
+		return nil
+	}
+
+	c.positions[key] = ev.pos
+
+	return writeEvent(w, formatPositions(c.positions), ev)
+}
+
+func (c *streamClient) matches(subject string) bool {
+	return c.subject == "" || msgtpl.Match(c.subject, subject)
+}
+
+// push queues the event, the client that does not keep up with events is disconnected,
+// it may resume by Last-Event-ID.
+func (c *streamClient) push(ev *event) bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+
+	select {
+	case c.events <- ev:
+		return true
+	default:
+		log.Warnf("slow client of stream %s is disconnected", c.uri)
+
+		c.close()
+
+		return false
+	}
+}
+
+// hold keeps the event until the client is resumed, the client that does not keep up with events
+// is disconnected.
+func (c *streamClient) hold(ev *event, buffer int) bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+
+	if len(c.pending) >= buffer {
+		log.Warnf("slow client of stream %s is disconnected", c.uri)
+
+		c.close()
+
+		return false
+	}
+
+	c.pending = append(c.pending, ev)
+
+	return true
+}
+
+func (c *streamClient) close() {
+	c.once.Do(func() {
+		close(c.done)
+	})
+}
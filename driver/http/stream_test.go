@@ -0,0 +1,133 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"NATter/entity"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamPublish(t *testing.T) {
+	s := newStream("/events", nil)
+
+	all, _ := s.subscribe("", "")
+	created, _ := s.subscribe("user.*.created", "")
+
+	assert.Equal(t, 1, s.publish("user.42.deleted", []byte("deleted"), nil))
+	assert.Equal(t, 2, s.publish("user.42.created", []byte("created"), nil))
+
+	assert.Equal(t, "deleted", string((<-all.events).data))
+	assert.Equal(t, "created", string((<-all.events).data))
+	assert.Equal(t, "created", string((<-created.events).data))
+
+	s.unsubscribe(all)
+
+	assert.Equal(t, 1, s.publish("user.43.created", []byte("created"), nil))
+}
+
+func TestStreamSubscribeOnLastEventID(t *testing.T) {
+	s := newStream("/events", &SSEConfig{Replay: 3})
+
+	for _, subject := range []string{"user.1.created", "user.2.deleted", "user.3.created", "user.4.created"} {
+		s.publish(subject, []byte(subject), nil)
+	}
+
+	_, missed := s.subscribe("user.*.created", "2")
+
+	assert.Len(t, missed, 2)
+	assert.Equal(t, uint64(3), missed[0].id)
+	assert.Equal(t, uint64(4), missed[1].id)
+
+	// The first event is not kept anymore.
+	_, missed = s.subscribe("", "1")
+
+	assert.Nil(t, missed)
+
+	_, missed = s.subscribe("", "unknown")
+
+	assert.Nil(t, missed)
+}
+
+func TestStreamPublishOnSlowClient(t *testing.T) {
+	s := newStream("/events", &SSEConfig{Buffer: 1})
+
+	cl, _ := s.subscribe("", "")
+
+	assert.Equal(t, 1, s.publish("user.created", []byte("1"), nil))
+	assert.Equal(t, 0, s.publish("user.created", []byte("2"), nil))
+
+	select {
+	case <-cl.done:
+	default:
+		assert.Fail(t, "slow client is not disconnected")
+	}
+}
+
+func TestStreamHandleOnWriteTimeout(t *testing.T) {
+	s := newStream("/events", nil)
+
+	srv := httptest.NewUnstartedServer(s.handle(time.Hour))
+	srv.Config.WriteTimeout = time.Millisecond * 50
+	srv.Start()
+
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+
+	assert.Nil(t, err)
+
+	defer resp.Body.Close()
+
+	// The event is published after the write timeout, the stream is still open.
+	time.Sleep(time.Millisecond * 100)
+
+	assert.Equal(t, 1, s.publish("user.created", []byte("created"), nil))
+
+	r := bufio.NewReader(resp.Body)
+
+	line, err := r.ReadString('\n')
+
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(line, "id: "))
+}
+
+func TestWriteEvent(t *testing.T) {
+	var buf bytes.Buffer
+
+	err := writeEvent(&buf, "7", &event{data: []byte("{\"id\":42,\r\n\"name\":\"John\"}")})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "id: 7\ndata: {\"id\":42,\ndata: \"name\":\"John\"}\n\n", buf.String())
+}
+
+func TestParsePositions(t *testing.T) {
+	positions, ok := parsePositions("orders:0:5,orders:1:-1")
+
+	assert.True(t, ok)
+	assert.Equal(t, []*entity.Position{
+		{Topic: "orders", Partition: 0, Offset: 5},
+		{Topic: "orders", Partition: 1, Offset: -1},
+	}, positions)
+
+	for _, id := range []string{"", "5", "orders:0", "orders:x:5", "orders:0:x"} {
+		_, ok := parsePositions(id)
+
+		assert.False(t, ok, id)
+	}
+}
+
+func TestFormatPositions(t *testing.T) {
+	id := formatPositions(map[string]*entity.Position{
+		"orders:1": {Topic: "orders", Partition: 1, Offset: 7},
+		"orders:0": {Topic: "orders", Partition: 0, Offset: 5},
+	})
+
+	assert.Equal(t, "orders:0:5,orders:1:7", id)
+}
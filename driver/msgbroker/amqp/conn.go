@@ -0,0 +1,406 @@
+package amqp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"NATter/driver"
+	"NATter/driver/msgbroker"
+	"NATter/entity"
+	"NATter/errtpl"
+	"NATter/log"
+	"NATter/msgtpl"
+
+	"github.com/pkg/errors"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	DriverName = entity.DriverAMQP
+
+	defaultPrefetch       = 10
+	defaultRequestTimeout = time.Second * 10
+	reconnectDelay        = time.Second * 5
+
+	// Direct reply-to pseudo queue of RabbitMQ, replies are delivered to the channel the request
+	// is published with without declaring a queue.
+	replyQueue = "amq.rabbitmq.reply-to"
+)
+
+var ErrClosed = errors.Wrap(errtpl.ErrServiceUnavailable, "amqp connection is closed")
+
+type ConnConfig struct {
+	URL            string
+	Prefetch       int           // deliveries of a queue handled at once before they are acknowledged
+	RequestTimeout time.Duration // a route timeout takes precedence
+}
+
+type Conn interface {
+	Consume(queue string, handler func(*amqp.Delivery) error) error
+	Publish(ctx context.Context, exchange, key string, msg amqp.Publishing) error
+	Request(ctx context.Context, exchange, key string, msg amqp.Publishing) (*amqp.Delivery, error)
+}
+
+type conn struct {
+	url      string
+	prefetch int
+	timeout  time.Duration
+
+	mx       *sync.RWMutex
+	conn     *amqp.Connection
+	channel  *amqp.Channel // publishes messages and requests and receives replies
+	closed   chan *amqp.Error
+	handlers map[string][]func(*amqp.Delivery) error // by queue
+	pending  map[string]chan *amqp.Delivery          // replies by correlation ID
+	wg       *sync.WaitGroup
+}
+
+func NewConn(cfg *ConnConfig) (driver.Conn, error) {
+	conn := &conn{
+		url:      cfg.URL,
+		prefetch: cfg.Prefetch,
+		timeout:  cfg.RequestTimeout,
+
+		mx:       &sync.RWMutex{},
+		handlers: make(map[string][]func(*amqp.Delivery) error),
+		pending:  make(map[string]chan *amqp.Delivery),
+		wg:       &sync.WaitGroup{},
+	}
+
+	if conn.prefetch <= 0 {
+		conn.prefetch = defaultPrefetch
+	}
+
+	if err := conn.connect(); err != nil {
+		return nil, errtpl.ErrConnect(err, "amqp")
+	}
+
+	return conn, nil
+}
+
+// connect opens the connection and restores consumers of queues.
+func (c *conn) connect() error {
+	amqpConn, err := amqp.Dial(c.url)
+
+	if err != nil {
+		return err
+	}
+
+	channel, err := amqpConn.Channel()
+
+	if err != nil {
+		amqpConn.Close()
+
+		return err
+	}
+
+	replies, err := channel.Consume(replyQueue, "", true, false, false, false, nil)
+
+	if err != nil {
+		amqpConn.Close()
+
+		return err
+	}
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	c.conn = amqpConn
+	c.channel = channel
+	c.closed = amqpConn.NotifyClose(make(chan *amqp.Error, 1))
+
+	go c.receiveReplies(replies)
+
+	for queue := range c.handlers {
+		if err := c.consume(queue); err != nil {
+			amqpConn.Close()
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Serve reconnects to the broker when the connection is lost until the context is done.
+func (c *conn) Serve(ctx context.Context) error {
+	for {
+		c.mx.RLock()
+		closed := c.closed
+		c.mx.RUnlock()
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-closed:
+			// The connection closed by Close has no error.
+			if err == nil {
+				return nil
+			}
+
+			log.Error(errtpl.ErrConnect(err, "amqp"))
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(reconnectDelay):
+			}
+
+			err := c.connect()
+
+			if err == nil {
+				log.Info("amqp connection is restored")
+
+				break
+			}
+
+			log.Error(errtpl.ErrConnect(err, "amqp"))
+		}
+	}
+}
+
+func (c *conn) Close() error {
+	c.mx.RLock()
+	amqpConn := c.conn
+	c.mx.RUnlock()
+
+	if err := amqpConn.Close(); err != nil && !errors.Is(err, amqp.ErrClosed) {
+		return errtpl.ErrClose(err, "amqp")
+	}
+
+	c.wg.Wait()
+
+	return nil
+}
+
+func (c *conn) Receiver(route *entity.Route) driver.Receiver {
+	return &receiver{
+		conn:       c,
+		queue:      route.AMQPQueue,
+		route:      route.ID,
+		logPayload: log.PayloadEnabled(route.LogPayload),
+	}
+}
+
+func (c *conn) Sender(route *entity.Route) driver.Sender {
+	return &sender{
+		conn:        c,
+		exchange:    route.AMQPExchange,
+		key:         msgtpl.New(route.AMQPRoutingKey),
+		compression: route.Compression,
+		timeout:     c.routeTimeout(route),
+		route:       route.ID,
+		logPayload:  log.PayloadEnabled(route.LogPayload),
+	}
+}
+
+func (c *conn) routeTimeout(route *entity.Route) time.Duration {
+	if route.Timeout != 0 {
+		return time.Duration(route.Timeout) * time.Second
+	}
+
+	if c.timeout != 0 {
+		return c.timeout
+	}
+
+	return defaultRequestTimeout
+}
+
+// Consume adds the handler to the queue consumer, so every route receiving from the queue
+// gets each delivery.
+func (c *conn) Consume(queue string, handler func(*amqp.Delivery) error) error {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if _, ok := c.handlers[queue]; !ok {
+		if err := c.consume(queue); err != nil {
+			return err
+		}
+	}
+
+	c.handlers[queue] = append(c.handlers[queue], handler)
+
+	msgbroker.LogDebugSubscribed(queue)
+
+	return nil
+}
+
+// consume starts the consumer of the queue on its own channel, so the prefetch limit is per queue.
+func (c *conn) consume(queue string) error {
+	channel, err := c.conn.Channel()
+
+	if err != nil {
+		return msgbroker.ErrSubscribe(err, queue)
+	}
+
+	if err := channel.Qos(c.prefetch, 0, false); err != nil {
+		return msgbroker.ErrSubscribe(err, queue)
+	}
+
+	deliveries, err := channel.Consume(queue, "", false, false, false, false, nil)
+
+	if err != nil {
+		return msgbroker.ErrSubscribe(err, queue)
+	}
+
+	c.wg.Add(1)
+
+	go func() {
+		defer c.wg.Done()
+
+		for d := range deliveries {
+			d := d
+
+			c.wg.Add(1)
+
+			// The number of deliveries handled at once is limited by the prefetch.
+			go func() {
+				defer c.wg.Done()
+
+				c.handle(queue, &d)
+			}()
+		}
+	}()
+
+	return nil
+}
+
+// handle passes the delivery to every handler of the queue and acknowledges it if they succeed.
+// A failed delivery is requeued once, then it is rejected to the dead letter exchange of the queue if any.
+func (c *conn) handle(queue string, d *amqp.Delivery) {
+	c.mx.RLock()
+	handlers := c.handlers[queue]
+	c.mx.RUnlock()
+
+	failed := false
+
+	for _, handler := range handlers {
+		if err := handler(d); err != nil {
+			msgbroker.LogErrorHandle(err, queue)
+
+			failed = true
+		}
+	}
+
+	var err error
+
+	if failed {
+		err = d.Nack(false, !d.Redelivered)
+	} else {
+		err = d.Ack(false)
+	}
+
+	if err != nil {
+		msgbroker.LogErrorHandle(errors.Wrap(err, "unable acknowledge delivery"), queue)
+	}
+}
+
+func (c *conn) Publish(ctx context.Context, exchange, key string, msg amqp.Publishing) error {
+	c.mx.RLock()
+	channel := c.channel
+	c.mx.RUnlock()
+
+	if err := channel.PublishWithContext(ctx, exchange, key, false, false, msg); err != nil {
+		return msgbroker.ErrPublish(prepareError(err), key)
+	}
+
+	return nil
+}
+
+// Request publishes the message with the direct reply-to queue and waits for the reply
+// with the same correlation ID.
+func (c *conn) Request(ctx context.Context, exchange, key string, msg amqp.Publishing) (*amqp.Delivery, error) {
+	id, err := correlationID()
+
+	if err != nil {
+		return nil, err
+	}
+
+	reply := make(chan *amqp.Delivery, 1)
+
+	c.mx.Lock()
+	c.pending[id] = reply
+	c.mx.Unlock()
+
+	defer func() {
+		c.mx.Lock()
+		delete(c.pending, id)
+		c.mx.Unlock()
+	}()
+
+	msg.ReplyTo = replyQueue
+	msg.CorrelationId = id
+
+	if err := c.Publish(ctx, exchange, key, msg); err != nil {
+		return nil, err
+	}
+
+	select {
+	case d, ok := <-reply:
+		if !ok {
+			return nil, msgbroker.ErrBadReply(ErrClosed, key)
+		}
+
+		return d, nil
+	case <-ctx.Done():
+		return nil, msgbroker.ErrBadReply(prepareError(ctx.Err()), key)
+	}
+}
+
+// receiveReplies passes replies to waiting requests, requests are failed once the channel is closed.
+func (c *conn) receiveReplies(replies <-chan amqp.Delivery) {
+	for d := range replies {
+		d := d
+
+		c.mx.RLock()
+		reply, ok := c.pending[d.CorrelationId]
+		c.mx.RUnlock()
+
+		if !ok {
+			log.WithFields(log.Fields{
+				log.FieldTopic: d.RoutingKey,
+			}).Debug("reply is ignored, request is finished")
+
+			continue
+		}
+
+		select {
+		case reply <- &d:
+		default:
+		}
+	}
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	for id, reply := range c.pending {
+		close(reply)
+		delete(c.pending, id)
+	}
+}
+
+func correlationID() (string, error) {
+	b := make([]byte, 16)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+func prepareError(err error) error {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return errtpl.ErrGatewayTimeout
+	case errors.Is(err, amqp.ErrClosed):
+		return ErrClosed
+	}
+
+	return err
+}
@@ -0,0 +1,117 @@
+package amqp
+
+import (
+	"context"
+	"fmt"
+
+	"NATter/compression"
+	"NATter/driver"
+	"NATter/driver/msgbroker"
+	"NATter/entity"
+	"NATter/errtpl"
+
+	"github.com/pkg/errors"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+type receiver struct {
+	conn       Conn
+	queue      string
+	route      string
+	logPayload bool
+}
+
+func (r *receiver) Listen(sender driver.Sender) error {
+	return r.conn.Consume(r.queue, func(d *amqp.Delivery) error {
+		msg, err := r.message(d)
+
+		if err != nil {
+			return err
+		}
+
+		return sender.Send(msg)
+	})
+}
+
+func (r *receiver) ListenRequest(sender driver.Sender) error {
+	return r.conn.Consume(r.queue, func(d *amqp.Delivery) error {
+		if d.ReplyTo == "" {
+			return errors.New("request has no reply_to queue")
+		}
+
+		msg, err := r.message(d)
+
+		if err != nil {
+			return err
+		}
+
+		resp, err := sender.Request(msg)
+
+		if err != nil {
+			// The requester gets the error status at once, the request is not redelivered
+			// since it is answered.
+			msgbroker.LogErrorHandle(err, r.queue)
+
+			code, body := errtpl.Reply(err)
+
+			resp = &entity.Message{Payload: body, Status: code}
+		}
+
+		if err := r.respond(d, resp); err != nil {
+			return err
+		}
+
+		msgbroker.LogDebugResponded(r.route, d.ReplyTo, msgbroker.Loggable(resp.Payload, r.logPayload))
+
+		return nil
+	})
+}
+
+func (r *receiver) respond(d *amqp.Delivery, resp *entity.Message) error {
+	reply := amqp.Publishing{
+		CorrelationId: d.CorrelationId,
+		Body:          resp.Payload,
+	}
+
+	if resp.Status != 0 {
+		reply.Headers = amqp.Table{msgbroker.HeaderStatus: int32(resp.Status)}
+	}
+
+	// Replies are sent to the queue by the default exchange.
+	if err := r.conn.Publish(context.Background(), "", d.ReplyTo, reply); err != nil {
+		return msgbroker.ErrRespond(err, d.ReplyTo)
+	}
+
+	return nil
+}
+
+func (r *receiver) message(d *amqp.Delivery) (*entity.Message, error) {
+	payload, err := compression.Decompress(d.ContentEncoding, d.Body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	msgbroker.LogDebugReceived(r.route, r.queue, msgbroker.Loggable(payload, r.logPayload))
+
+	return &entity.Message{
+		Payload: payload,
+		Subject: d.RoutingKey,
+		Header:  header(d.Headers),
+	}, nil
+}
+
+// header returns headers of the delivery as strings.
+func header(table amqp.Table) map[string]string {
+	if len(table) == 0 {
+		return nil
+	}
+
+	res := make(map[string]string, len(table))
+
+	for key, value := range table {
+		res[key] = fmt.Sprint(value)
+	}
+
+	return res
+}
@@ -0,0 +1,164 @@
+package amqp
+
+import (
+	"errors"
+	"testing"
+
+	"NATter/entity"
+	"NATter/errtpl"
+	m "NATter/mock"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// consumeWith makes the conn pass the delivery to the handler of the queue and checks the handler result.
+func consumeWith(t *testing.T, conn *m.DriverAMQPConn, d *amqp.Delivery, expectErr bool) {
+	conn.
+		On("Consume", "orders", mock.AnythingOfType("func(*amqp091.Delivery) error")).
+		Run(func(args mock.Arguments) {
+			err := args.Get(1).(func(*amqp.Delivery) error)(d)
+
+			if expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+		}).
+		Return(nil)
+}
+
+func TestReceiverListen(t *testing.T) {
+	conn := &m.DriverAMQPConn{}
+
+	consumeWith(t, conn, &amqp.Delivery{
+		RoutingKey: "order.created",
+		Headers:    amqp.Table{"X-Priority": int32(5)},
+		Body:       []byte("some-data"),
+	}, false)
+
+	sender := &m.DriverSender{}
+
+	sender.
+		On("Send", &entity.Message{
+			Payload: []byte("some-data"),
+			Subject: "order.created",
+			Header:  map[string]string{"X-Priority": "5"},
+		}).
+		Return(nil)
+
+	receiver := &receiver{
+		conn:  conn,
+		queue: "orders",
+	}
+
+	err := receiver.Listen(sender)
+
+	assert.Nil(t, err)
+	sender.AssertExpectations(t)
+}
+
+func TestReceiverListenOnSendError(t *testing.T) {
+	conn := &m.DriverAMQPConn{}
+
+	consumeWith(t, conn, &amqp.Delivery{Body: []byte("some-data")}, true)
+
+	sender := &m.DriverSender{}
+
+	sender.
+		On("Send", mock.AnythingOfType("*entity.Message")).
+		Return(errors.New("error"))
+
+	receiver := &receiver{
+		conn:  conn,
+		queue: "orders",
+	}
+
+	err := receiver.Listen(sender)
+
+	assert.Nil(t, err)
+}
+
+func TestReceiverListenRequest(t *testing.T) {
+	conn := &m.DriverAMQPConn{}
+
+	consumeWith(t, conn, &amqp.Delivery{
+		ReplyTo:       "amq.rabbitmq.reply-to.g1h2",
+		CorrelationId: "42",
+		Body:          []byte("request-data"),
+	}, false)
+
+	conn.
+		On("Publish", "", "amq.rabbitmq.reply-to.g1h2", amqp.Publishing{
+			CorrelationId: "42",
+			Headers:       amqp.Table{"Natter-Status": int32(201)},
+			Body:          []byte("response-data"),
+		}).
+		Return(nil)
+
+	sender := &m.DriverSender{}
+
+	sender.
+		On("Request", entity.NewMessage([]byte("request-data"))).
+		Return(&entity.Message{Payload: []byte("response-data"), Status: 201}, nil)
+
+	receiver := &receiver{
+		conn:  conn,
+		queue: "orders",
+	}
+
+	err := receiver.ListenRequest(sender)
+
+	assert.Nil(t, err)
+	conn.AssertExpectations(t)
+}
+
+func TestReceiverListenRequestOnError(t *testing.T) {
+	conn := &m.DriverAMQPConn{}
+
+	consumeWith(t, conn, &amqp.Delivery{
+		ReplyTo:       "amq.rabbitmq.reply-to.g1h2",
+		CorrelationId: "42",
+		Body:          []byte("request-data"),
+	}, false)
+
+	conn.
+		On("Publish", "", "amq.rabbitmq.reply-to.g1h2", amqp.Publishing{
+			CorrelationId: "42",
+			Headers:       amqp.Table{"Natter-Status": int32(422)},
+			Body:          []byte(`{"error":"invalid"}`),
+		}).
+		Return(nil)
+
+	sender := &m.DriverSender{}
+
+	sender.
+		On("Request", mock.AnythingOfType("*entity.Message")).
+		Return((*entity.Message)(nil), &errtpl.StatusError{Code: 422, Body: []byte(`{"error":"invalid"}`)})
+
+	receiver := &receiver{
+		conn:  conn,
+		queue: "orders",
+	}
+
+	err := receiver.ListenRequest(sender)
+
+	assert.Nil(t, err)
+	conn.AssertExpectations(t)
+}
+
+func TestReceiverListenRequestOnNoReplyTo(t *testing.T) {
+	conn := &m.DriverAMQPConn{}
+
+	consumeWith(t, conn, &amqp.Delivery{Body: []byte("request-data")}, true)
+
+	receiver := &receiver{
+		conn:  conn,
+		queue: "orders",
+	}
+
+	err := receiver.ListenRequest(&m.DriverSender{})
+
+	assert.Nil(t, err)
+}
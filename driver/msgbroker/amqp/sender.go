@@ -0,0 +1,94 @@
+package amqp
+
+import (
+	"context"
+	"time"
+
+	"NATter/compression"
+	"NATter/driver/msgbroker"
+	"NATter/entity"
+	"NATter/msgtpl"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+type sender struct {
+	conn        Conn
+	exchange    string
+	key         *msgtpl.Template
+	compression string
+	timeout     time.Duration
+	route       string
+	logPayload  bool
+}
+
+func (s *sender) Send(msg *entity.Message) error {
+	key, pub, err := s.publishing(msg)
+
+	if err != nil {
+		return err
+	}
+
+	if err := s.conn.Publish(msg.Context(), s.exchange, key, pub); err != nil {
+		return err
+	}
+
+	msgbroker.LogDebugPublished(s.route, key, msgbroker.Loggable(msg.Payload, s.logPayload))
+
+	return nil
+}
+
+func (s *sender) Request(msg *entity.Message) (*entity.Message, error) {
+	key, pub, err := s.publishing(msg)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(msg.Context(), s.timeout)
+	defer cancel()
+
+	start := time.Now()
+
+	d, err := s.conn.Request(ctx, s.exchange, key, pub)
+
+	if err != nil {
+		return nil, err
+	}
+
+	respb, err := compression.Decompress(d.ContentEncoding, d.Body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	msgbroker.LogDebugRequested(
+		s.route,
+		key,
+		msgbroker.Loggable(msg.Payload, s.logPayload),
+		msgbroker.Loggable(respb, s.logPayload),
+		time.Since(start),
+	)
+
+	return msgbroker.Reply(d.Headers[msgbroker.HeaderStatus], respb)
+}
+
+func (s *sender) publishing(msg *entity.Message) (string, amqp.Publishing, error) {
+	key, err := s.key.Execute(msg)
+
+	if err != nil {
+		return "", amqp.Publishing{}, err
+	}
+
+	body, err := compression.Compress(s.compression, msg.Payload)
+
+	if err != nil {
+		return "", amqp.Publishing{}, err
+	}
+
+	return key, amqp.Publishing{
+		ContentEncoding: s.compression,
+		DeliveryMode:    amqp.Persistent,
+		Body:            body,
+	}, nil
+}
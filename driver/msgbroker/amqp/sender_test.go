@@ -0,0 +1,146 @@
+package amqp
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"NATter/entity"
+	"NATter/errtpl"
+	m "NATter/mock"
+	"NATter/msgtpl"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSenderSend(t *testing.T) {
+	conn := &m.DriverAMQPConn{}
+
+	conn.
+		On("Publish", "orders", "order.42.created", amqp.Publishing{
+			DeliveryMode: amqp.Persistent,
+			Body:         []byte("some-data"),
+		}).
+		Return(nil)
+
+	sender := &sender{
+		conn:     conn,
+		exchange: "orders",
+		key:      msgtpl.New("order.{{ .uri.id }}.created"),
+	}
+
+	err := sender.Send(&entity.Message{
+		Payload: []byte("some-data"),
+		Params:  map[string]string{"id": "42"},
+	})
+
+	assert.Nil(t, err)
+	conn.AssertExpectations(t)
+}
+
+func TestSenderSendOnCompression(t *testing.T) {
+	conn := &m.DriverAMQPConn{}
+
+	conn.
+		On("Publish", "", "orders", mock.AnythingOfType("amqp091.Publishing")).
+		Run(func(args mock.Arguments) {
+			pub := args.Get(2).(amqp.Publishing)
+
+			assert.Equal(t, "gzip", pub.ContentEncoding)
+			assert.NotEqual(t, []byte("some-data"), pub.Body)
+		}).
+		Return(nil)
+
+	sender := &sender{
+		conn:        conn,
+		key:         msgtpl.New("orders"),
+		compression: "gzip",
+	}
+
+	err := sender.Send(entity.NewMessage([]byte("some-data")))
+
+	assert.Nil(t, err)
+	conn.AssertExpectations(t)
+}
+
+func TestSenderSendOnError(t *testing.T) {
+	conn := &m.DriverAMQPConn{}
+
+	conn.
+		On("Publish", "", "orders", mock.AnythingOfType("amqp091.Publishing")).
+		Return(errors.New("error"))
+
+	sender := &sender{
+		conn: conn,
+		key:  msgtpl.New("orders"),
+	}
+
+	err := sender.Send(entity.NewMessage([]byte("some-data")))
+
+	assert.Error(t, err)
+}
+
+func TestSenderRequest(t *testing.T) {
+	for _, tc := range []struct {
+		headers  amqp.Table
+		expected *entity.Message
+		err      error
+	}{
+		{
+			expected: entity.NewMessage([]byte("response-data")),
+		},
+		{
+			headers:  amqp.Table{"Natter-Status": int32(201)},
+			expected: &entity.Message{Payload: []byte("response-data"), Status: 201},
+		},
+		{
+			headers:  amqp.Table{"Natter-Status": "201"},
+			expected: &entity.Message{Payload: []byte("response-data"), Status: 201},
+		},
+		{
+			headers: amqp.Table{"Natter-Status": int64(404)},
+			err:     &errtpl.StatusError{Code: 404, Body: []byte("response-data")},
+		},
+	} {
+		conn := &m.DriverAMQPConn{}
+
+		conn.
+			On("Request", "", "rpc", amqp.Publishing{
+				DeliveryMode: amqp.Persistent,
+				Body:         []byte("request-data"),
+			}).
+			Return(&amqp.Delivery{Headers: tc.headers, Body: []byte("response-data")}, nil)
+
+		sender := &sender{
+			conn:    conn,
+			key:     msgtpl.New("rpc"),
+			timeout: time.Second,
+		}
+
+		resp, err := sender.Request(entity.NewMessage([]byte("request-data")))
+
+		assert.Equal(t, tc.err, err)
+		assert.Equal(t, tc.expected, resp)
+	}
+}
+
+func TestSenderRequestOnError(t *testing.T) {
+	conn := &m.DriverAMQPConn{}
+
+	conn.
+		On("Request", "", "rpc", mock.AnythingOfType("amqp091.Publishing")).
+		Return((*amqp.Delivery)(nil), errors.New("error"))
+
+	sender := &sender{
+		conn:    conn,
+		key:     msgtpl.New("rpc"),
+		timeout: time.Second,
+	}
+
+	resp, err := sender.Request(entity.NewMessage([]byte("request-data")))
+
+	assert.Error(t, err)
+	assert.Nil(t, resp)
+}
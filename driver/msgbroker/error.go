@@ -1,11 +1,14 @@
 package msgbroker
 
 import (
+	"NATter/errtpl"
+
 	"github.com/pkg/errors"
 )
 
 var (
-	ErrNoResponders = errors.New("no responders available for request")
+	ErrNoResponders = errors.Wrap(errtpl.ErrServiceUnavailable, "no responders available for request")
+	ErrQueueFull    = errors.Wrap(errtpl.ErrServiceUnavailable, "route queue is full")
 )
 
 func ErrBadReply(err error, topic string) error {
@@ -0,0 +1,50 @@
+package msgbroker
+
+import (
+	"strconv"
+
+	"NATter/entity"
+	"NATter/errtpl"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	HeaderContentEncoding = "Content-Encoding"
+	HeaderStatus          = "Natter-Status" // HTTP status code of a reply
+)
+
+// Reply returns the reply with the status set by the responder in the HeaderStatus header, the status
+// is nil if the header is missing. A status other than 2xx is returned as errtpl.StatusError.
+func Reply(status interface{}, payload []byte) (*entity.Message, error) {
+	var code int
+
+	switch value := status.(type) {
+	case nil:
+		return entity.NewMessage(payload), nil
+	case int32:
+		code = int(value)
+	case int64:
+		code = int(value)
+	case string:
+		var err error
+
+		if code, err = strconv.Atoi(value); err != nil {
+			return nil, errors.Wrapf(err, "%s header", HeaderStatus)
+		}
+	default:
+		return nil, errors.Errorf("%s header has unexpected type %T", HeaderStatus, status)
+	}
+
+	if !errtpl.IsSuccess(code) {
+		return nil, &errtpl.StatusError{
+			Code: code,
+			Body: payload,
+		}
+	}
+
+	return &entity.Message{
+		Payload: payload,
+		Status:  code,
+	}, nil
+}
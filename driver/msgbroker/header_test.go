@@ -0,0 +1,50 @@
+package msgbroker
+
+import (
+	"testing"
+
+	"NATter/entity"
+	"NATter/errtpl"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReply(t *testing.T) {
+	inputs := []struct {
+		status interface{}
+		resp   *entity.Message
+		err    error
+	}{
+		{
+			resp: entity.NewMessage([]byte("data")),
+		},
+		{
+			status: "201",
+			resp:   &entity.Message{Payload: []byte("data"), Status: 201},
+		},
+		{
+			status: int32(202),
+			resp:   &entity.Message{Payload: []byte("data"), Status: 202},
+		},
+		{
+			status: int64(404),
+			err:    &errtpl.StatusError{Code: 404, Body: []byte("data")},
+		},
+	}
+
+	for i, input := range inputs {
+		resp, err := Reply(input.status, []byte("data"))
+
+		assert.Equalf(t, input.resp, resp, "case %d", i+1)
+		assert.Equalf(t, input.err, err, "case %d", i+1)
+	}
+}
+
+func TestReplyOnInvalidStatus(t *testing.T) {
+	for _, status := range []interface{}{"abc", 2.5} {
+		resp, err := Reply(status, []byte("data"))
+
+		assert.Nil(t, resp)
+		assert.Error(t, err)
+	}
+}
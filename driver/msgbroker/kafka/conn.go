@@ -3,17 +3,25 @@ package kafka
 import (
 	"context"
 	"strings"
+	"sync"
 
 	"NATter/driver"
 	"NATter/driver/msgbroker"
 	"NATter/entity"
 	"NATter/errtpl"
 	"NATter/log"
+	"NATter/msgtpl"
 
 	"github.com/Shopify/sarama"
 )
 
-const DriverName = "kafka"
+const (
+	DriverName = entity.BrokerKafka
+
+	// queueSize is the number of messages a route may fall behind the claim,
+	// then the claim waits for it.
+	queueSize = 64
+)
 
 type ConnConfig struct {
 	Version string
@@ -22,8 +30,17 @@ type ConnConfig struct {
 }
 
 type Conn interface {
-	Subscribe(topic string, handler func([]byte) error) error
-	Publish(topic string, payload []byte) error
+	Subscribe(topic string, workers int, handler func(*sarama.ConsumerMessage) error) error
+	Publish(msg *sarama.ProducerMessage) error
+	Positions(topic string) ([]*entity.Position, error)
+	Replay(ctx context.Context, topic string, after []*entity.Position, handler func(*sarama.ConsumerMessage) error) error
+}
+
+// offsetClient returns partitions of topics and their offsets, it is implemented by sarama.Client.
+type offsetClient interface {
+	Partitions(topic string) ([]int32, error)
+	GetOffset(topic string, partition int32, time int64) (int64, error)
+	Close() error
 }
 
 type conn struct {
@@ -34,7 +51,11 @@ type conn struct {
 	consumer sarama.ConsumerGroup
 	producer sarama.AsyncProducer
 
-	handlers map[string]func([]byte) error
+	// Partitions are read apart from the group to replay messages.
+	client offsetClient
+	reader sarama.Consumer
+
+	handlers map[string][]*subscription
 	gch      *consumerHandler
 }
 
@@ -44,7 +65,7 @@ func NewConn(cfg *ConnConfig) (driver.Conn, error) {
 		servers: cfg.Servers,
 		group:   cfg.Group,
 
-		handlers: map[string]func([]byte) error{},
+		handlers: map[string][]*subscription{},
 		gch:      &consumerHandler{},
 	}
 
@@ -71,6 +92,19 @@ func NewConn(cfg *ConnConfig) (driver.Conn, error) {
 		return nil, errtpl.ErrConnect(err, "kafka")
 	}
 
+	client, err := sarama.NewClient(conn.servers, saramaConf)
+
+	if err != nil {
+		return nil, errtpl.ErrConnect(err, "kafka")
+	}
+
+	conn.client = client
+	conn.reader, err = sarama.NewConsumerFromClient(client)
+
+	if err != nil {
+		return nil, errtpl.ErrConnect(err, "kafka")
+	}
+
 	return conn, nil
 }
 
@@ -105,49 +139,166 @@ func (c *conn) Serve(ctx context.Context) error {
 func (c *conn) Close() error {
 	c.consumer.Close()
 	c.producer.Close()
+	c.reader.Close()
+	c.client.Close()
 
 	return nil
 }
 
 func (c *conn) Receiver(route *entity.Route) driver.Receiver {
 	return &receiver{
-		conn:  c,
-		topic: route.Topic,
+		conn:       c,
+		topic:      route.Topic,
+		workers:    msgbroker.Workers(route),
+		route:      route.ID,
+		logPayload: log.PayloadEnabled(route.LogPayload),
 	}
 }
 
 func (c *conn) Sender(route *entity.Route) driver.Sender {
 	return &sender{
-		conn:  c,
-		topic: route.Topic,
+		conn:        c,
+		topic:       msgtpl.New(route.Topic),
+		compression: route.Compression,
+		route:       route.ID,
+		logPayload:  log.PayloadEnabled(route.LogPayload),
 	}
 }
 
-func (c *conn) Publish(topic string, message []byte) error {
-	c.producer.Input() <- &sarama.ProducerMessage{
-		Topic: topic,
-		Value: sarama.StringEncoder(message),
-	}
-
-	msgbroker.LogDebugPublished(topic, nil)
+func (c *conn) Publish(msg *sarama.ProducerMessage) error {
+	c.producer.Input() <- msg
 
 	return nil
 }
 
-func (c *conn) Subscribe(topic string, handler func([]byte) error) error {
-	c.handlers[topic] = handler
+// Subscribe adds the handler to the topic, so every route receiving from the topic gets each message.
+func (c *conn) Subscribe(topic string, workers int, handler func(*sarama.ConsumerMessage) error) error {
+	c.handlers[topic] = append(c.handlers[topic], &subscription{handler: handler, workers: workers})
 
 	msgbroker.LogDebugSubscribed(topic)
 
 	return nil
 }
 
+// Positions returns the positions of the newest messages of every partition of the topic,
+// the offset of an empty partition is -1.
+func (c *conn) Positions(topic string) ([]*entity.Position, error) {
+	partitions, err := c.client.Partitions(topic)
+
+	if err != nil {
+		return nil, msgbroker.ErrSubscribe(err, topic)
+	}
+
+	res := make([]*entity.Position, 0, len(partitions))
+
+	for _, p := range partitions {
+		newest, err := c.client.GetOffset(topic, p, sarama.OffsetNewest)
+
+		if err != nil {
+			return nil, msgbroker.ErrSubscribe(err, topic)
+		}
+
+		res = append(res, &entity.Position{Topic: topic, Partition: p, Offset: newest - 1})
+	}
+
+	return res, nil
+}
+
+// Replay passes messages of the topic following the positions to the handler partition by partition,
+// up to the newest messages at the call. A partition missing in the positions is replayed from
+// the oldest message, so is a partition whose messages following the position are deleted already.
+// A failed message is logged and does not stop the replay unless the context is done.
+func (c *conn) Replay(ctx context.Context, topic string, after []*entity.Position, handler func(*sarama.ConsumerMessage) error) error {
+	partitions, err := c.client.Partitions(topic)
+
+	if err != nil {
+		return msgbroker.ErrSubscribe(err, topic)
+	}
+
+	next := map[int32]int64{}
+
+	for _, pos := range after {
+		if pos.Topic == topic {
+			next[pos.Partition] = pos.Offset + 1
+		}
+	}
+
+	for _, p := range partitions {
+		oldest, err := c.client.GetOffset(topic, p, sarama.OffsetOldest)
+
+		if err != nil {
+			return msgbroker.ErrSubscribe(err, topic)
+		}
+
+		newest, err := c.client.GetOffset(topic, p, sarama.OffsetNewest)
+
+		if err != nil {
+			return msgbroker.ErrSubscribe(err, topic)
+		}
+
+		start, ok := next[p]
+
+		if !ok || start < oldest {
+			start = oldest
+		}
+
+		if start >= newest {
+			continue
+		}
+
+		if err := c.replayPartition(ctx, topic, p, start, newest, handler); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// replayPartition passes messages of the partition from the start offset up to the end one.
+func (c *conn) replayPartition(ctx context.Context, topic string, partition int32, start, end int64, handler func(*sarama.ConsumerMessage) error) error {
+	pc, err := c.reader.ConsumePartition(topic, partition, start)
+
+	if err != nil {
+		return msgbroker.ErrSubscribe(err, topic)
+	}
+
+	// Close drains messages fetched past the end.
+	defer pc.Close()
+
+	for {
+		select {
+		case msg := <-pc.Messages():
+			if err := handler(msg); err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+
+				msgbroker.LogErrorHandle(err, topic)
+			}
+
+			if msg.Offset >= end-1 {
+				return nil
+			}
+		case cerr := <-pc.Errors():
+			return msgbroker.ErrSubscribe(cerr.Err, topic)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// subscription is the handler of a route and the number of messages it handles at once.
+type subscription struct {
+	handler func(*sarama.ConsumerMessage) error
+	workers int
+}
+
 type consumerHandler struct {
-	handlers map[string]func([]byte) error
+	handlers map[string][]*subscription
 	ready    chan bool
 }
 
-func (ch *consumerHandler) reset(handlers map[string]func([]byte) error) {
+func (ch *consumerHandler) reset(handlers map[string][]*subscription) {
 	ch.handlers = handlers
 	ch.ready = make(chan bool)
 }
@@ -160,20 +311,79 @@ func (ch consumerHandler) Setup(_ sarama.ConsumerGroupSession) error {
 
 func (ch consumerHandler) Cleanup(_ sarama.ConsumerGroupSession) error { return nil }
 
+// ConsumeClaim passes messages of the claim to every route receiving from the topic. Every route
+// handles them on its own workers, so a slow route does not hold the others up. A message
+// is marked as consumed once all the routes handled it, the offsets are marked in order.
 func (ch consumerHandler) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
-	for msg := range claim.Messages() {
-		msgbroker.LogDebugReceived(msg.Topic, nil)
+	subs := ch.handlers[claim.Topic()]
+	queues := make([]chan *delivery, len(subs))
 
-		err := ch.handlers[msg.Topic](msg.Value)
+	for i, sub := range subs {
+		queues[i] = make(chan *delivery, queueSize)
 
-		if err != nil {
-			msgbroker.LogErrorHandle(err, msg.Topic)
+		for j := 0; j < sub.workers; j++ {
+			go work(sub.handler, queues[i])
+		}
+	}
 
-			continue
+	marks := make(chan *delivery, queueSize)
+	marked := make(chan struct{})
+
+	go func() {
+		for d := range marks {
+			d.wg.Wait()
+
+			sess.MarkMessage(d.msg, "")
 		}
 
-		sess.MarkMessage(msg, "")
+		close(marked)
+	}()
+
+	for msg := range claim.Messages() {
+		d := &delivery{msg: msg}
+		d.wg.Add(len(queues))
+
+		for _, queue := range queues {
+			queue <- d
+		}
+
+		marks <- d
 	}
 
+	for _, queue := range queues {
+		close(queue)
+	}
+
+	close(marks)
+	<-marked
+
 	return nil
 }
+
+// delivery is a message being handled by the routes of its topic.
+type delivery struct {
+	msg *sarama.ConsumerMessage
+	wg  sync.WaitGroup
+}
+
+// work passes queued messages to the handler of a route, a failed one is logged and does not affect the other routes.
+// Messages are handled in order unless the route has several workers.
+func work(handler func(*sarama.ConsumerMessage) error, queue <-chan *delivery) {
+	for d := range queue {
+		if err := handler(d.msg); err != nil {
+			msgbroker.LogErrorHandle(err, d.msg.Topic)
+		}
+
+		d.wg.Done()
+	}
+}
+
+func header(msg *sarama.ConsumerMessage, key string) string {
+	for _, h := range msg.Headers {
+		if h != nil && string(h.Key) == key {
+			return string(h.Value)
+		}
+	}
+
+	return ""
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/Shopify/sarama/mocks"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func testConnEnv(t *testing.T) (*m.ConsumerGroup, *mocks.AsyncProducer, *conn) {
@@ -23,13 +24,43 @@ func testConnEnv(t *testing.T) (*m.ConsumerGroup, *mocks.AsyncProducer, *conn) {
 	conn := &conn{
 		consumer: consumer,
 		producer: producer,
-		handlers: map[string]func([]byte) error{},
+		client:   &testOffsets{},
+		reader:   mocks.NewConsumer(t, nil),
+		handlers: map[string][]*subscription{},
 		gch:      &consumerHandler{},
 	}
 
 	return consumer, producer, conn
 }
 
+// testOffsets is the client of a topic, its partitions keep messages from the oldest offset up to the newest one.
+type testOffsets struct {
+	oldest []int64
+	newest []int64
+}
+
+func (c *testOffsets) Partitions(string) ([]int32, error) {
+	res := make([]int32, len(c.newest))
+
+	for i := range c.newest {
+		res[i] = int32(i)
+	}
+
+	return res, nil
+}
+
+func (c *testOffsets) GetOffset(_ string, partition int32, time int64) (int64, error) {
+	if time == sarama.OffsetOldest {
+		return c.oldest[partition], nil
+	}
+
+	return c.newest[partition], nil
+}
+
+func (c *testOffsets) Close() error {
+	return nil
+}
+
 func TestNewConnOnError(t *testing.T) {
 	_, err := NewConn(&ConnConfig{})
 
@@ -39,7 +70,7 @@ func TestNewConnOnError(t *testing.T) {
 func TestConnServe(t *testing.T) {
 	consumer, _, conn := testConnEnv(t)
 
-	err := conn.Subscribe("topic", func([]byte) error { return nil })
+	err := conn.Subscribe("topic", 1, func(*sarama.ConsumerMessage) error { return nil })
 	assert.Nil(t, err)
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -65,7 +96,7 @@ func TestConnServe(t *testing.T) {
 func TestConnServeOnError(t *testing.T) {
 	consumer, _, conn := testConnEnv(t)
 
-	err := conn.Subscribe("topic", func([]byte) error { return nil })
+	err := conn.Subscribe("topic", 1, func(*sarama.ConsumerMessage) error { return nil })
 	assert.Nil(t, err)
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -126,7 +157,7 @@ func TestConnSender(t *testing.T) {
 		assert.Fail(t, "type assertion error")
 	}
 
-	assert.Equal(t, "topic", snd.topic)
+	assert.Equal(t, "topic", snd.topic.String())
 	assert.Equal(t, conn, snd.conn)
 }
 
@@ -135,7 +166,10 @@ func TestConnPublish(t *testing.T) {
 
 	producer.ExpectInputAndSucceed()
 
-	err := conn.Publish("topic", []byte("message"))
+	err := conn.Publish(&sarama.ProducerMessage{
+		Topic: "topic",
+		Value: sarama.ByteEncoder("message"),
+	})
 
 	assert.Nil(t, err)
 }
@@ -143,14 +177,84 @@ func TestConnPublish(t *testing.T) {
 func TestConnSubscribe(t *testing.T) {
 	_, _, conn := testConnEnv(t)
 
-	err := conn.Subscribe("topic", func([]byte) error { return nil })
+	err := conn.Subscribe("topic", 1, func(*sarama.ConsumerMessage) error { return nil })
+	assert.Nil(t, err)
+}
+
+func TestConnPositions(t *testing.T) {
+	_, _, conn := testConnEnv(t)
+
+	conn.client = &testOffsets{oldest: []int64{0, 0}, newest: []int64{3, 0}}
+
+	positions, err := conn.Positions("topic")
+
+	assert.Nil(t, err)
+	assert.Equal(t, []*entity.Position{
+		{Topic: "topic", Partition: 0, Offset: 2},
+		{Topic: "topic", Partition: 1, Offset: -1},
+	}, positions)
+}
+
+func TestConnReplay(t *testing.T) {
+	_, _, conn := testConnEnv(t)
+
+	// The messages following the position, the ones of the partition missing in the positions,
+	// and no messages of the empty partition are replayed.
+	conn.client = &testOffsets{oldest: []int64{0, 1, 0}, newest: []int64{3, 2, 0}}
+
+	reader := mocks.NewConsumer(t, nil)
+	conn.reader = reader
+
+	first := reader.ExpectConsumePartition("topic", 0, 1)
+	first.YieldMessage(&sarama.ConsumerMessage{Value: []byte("1")})
+	first.YieldMessage(&sarama.ConsumerMessage{Value: []byte("2")})
+	first.YieldMessage(&sarama.ConsumerMessage{Value: []byte("published after the replay started")})
+
+	second := reader.ExpectConsumePartition("topic", 1, 1)
+	second.YieldMessage(&sarama.ConsumerMessage{Value: []byte("3")})
+
+	var replayed []string
+
+	err := conn.Replay(context.Background(), "topic", []*entity.Position{
+		{Topic: "topic", Partition: 0, Offset: 0},
+		{Topic: "other", Partition: 1, Offset: 5},
+	}, func(msg *sarama.ConsumerMessage) error {
+		replayed = append(replayed, string(msg.Value))
+
+		// A failed message does not stop the replay.
+		return errors.New("error")
+	})
+
 	assert.Nil(t, err)
+	assert.Equal(t, []string{"1", "2", "3"}, replayed)
+}
+
+func TestConnReplayOnCanceled(t *testing.T) {
+	_, _, conn := testConnEnv(t)
+
+	conn.client = &testOffsets{oldest: []int64{0}, newest: []int64{3}}
+
+	reader := mocks.NewConsumer(t, nil)
+	conn.reader = reader
+
+	pc := reader.ExpectConsumePartition("topic", 0, 0)
+	pc.YieldMessage(&sarama.ConsumerMessage{Value: []byte("1")})
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	err := conn.Replay(ctx, "topic", nil, func(msg *sarama.ConsumerMessage) error {
+		cancel()
+
+		return ctx.Err()
+	})
+
+	assert.Equal(t, context.Canceled, err)
 }
 
 func TestConsumerHandlerSetup(t *testing.T) {
 	gch := consumerHandler{}
 
-	gch.reset(map[string]func([]byte) error{})
+	gch.reset(map[string][]*subscription{})
 
 	err := gch.Setup(&m.ConsumerGroupSession{})
 
@@ -171,6 +275,10 @@ func TestConsumerHandlerConsumeClaim(t *testing.T) {
 
 	msg := &sarama.ConsumerMessage{Topic: "internal"}
 
+	claim.
+		On("Topic").Once().
+		Return("internal")
+
 	claim.
 		On("Messages").Once().
 		Return(msg)
@@ -181,7 +289,7 @@ func TestConsumerHandlerConsumeClaim(t *testing.T) {
 
 	gch := consumerHandler{}
 
-	gch.reset(map[string]func([]byte) error{"internal": func([]byte) error { return nil }})
+	gch.reset(map[string][]*subscription{"internal": {{handler: func(*sarama.ConsumerMessage) error { return nil }, workers: 1}}})
 
 	err := gch.ConsumeClaim(sess, claim)
 
@@ -196,19 +304,145 @@ func TestConsumerHandlerConsumeClaimOnError(t *testing.T) {
 
 	msg := &sarama.ConsumerMessage{Topic: "internal"}
 
+	claim.
+		On("Topic").Once().
+		Return("internal")
+
 	claim.
 		On("Messages").Once().
 		Return(msg)
 
+	// The failed message is not retried, it is marked once every route handled it.
+	sess.
+		On("MarkMessage", msg, "").Once().
+		Return()
+
 	gch := consumerHandler{}
 
-	gch.reset(map[string]func([]byte) error{
-		"internal": func([]byte) error { return errors.New("error") },
+	handled := 0
+
+	gch.reset(map[string][]*subscription{
+		"internal": {
+			{handler: func(*sarama.ConsumerMessage) error { return errors.New("error") }, workers: 1},
+			{handler: func(*sarama.ConsumerMessage) error { handled++; return nil }, workers: 1},
+		},
 	})
 
 	err := gch.ConsumeClaim(sess, claim)
 
 	assert.Nil(t, err)
+	assert.Equal(t, 1, handled)
 	sess.AssertExpectations(t)
 	claim.AssertExpectations(t)
 }
+
+func TestConsumerHandlerConsumeClaimOnSlowRoute(t *testing.T) {
+	sess := &m.ConsumerGroupSession{}
+	claim := &m.ConsumerGroupClaim{}
+
+	msgs := make(chan *sarama.ConsumerMessage, 2)
+	msgs <- &sarama.ConsumerMessage{Topic: "internal", Offset: 1}
+	msgs <- &sarama.ConsumerMessage{Topic: "internal", Offset: 2}
+	close(msgs)
+
+	claimStub := &claimWithMessages{ConsumerGroupClaim: claim, msgs: msgs}
+
+	claim.
+		On("Topic").Once().
+		Return("internal")
+
+	var marked []int64
+
+	sess.
+		On("MarkMessage", mock.AnythingOfType("*sarama.ConsumerMessage"), "").
+		Run(func(args mock.Arguments) {
+			marked = append(marked, args.Get(0).(*sarama.ConsumerMessage).Offset)
+		}).
+		Return()
+
+	release := make(chan struct{})
+	fast := make(chan int64, 2)
+
+	gch := consumerHandler{}
+
+	gch.reset(map[string][]*subscription{
+		"internal": {
+			{handler: func(*sarama.ConsumerMessage) error { <-release; return nil }, workers: 1},
+			{handler: func(msg *sarama.ConsumerMessage) error { fast <- msg.Offset; return nil }, workers: 1},
+		},
+	})
+
+	done := make(chan error)
+
+	go func() {
+		done <- gch.ConsumeClaim(sess, claimStub)
+	}()
+
+	// The fast route gets both messages while the slow one handles the first.
+	assert.Equal(t, int64(1), <-fast)
+	assert.Equal(t, int64(2), <-fast)
+
+	close(release)
+
+	assert.Nil(t, <-done)
+	assert.Equal(t, []int64{1, 2}, marked)
+	claim.AssertExpectations(t)
+}
+
+func TestConsumerHandlerConsumeClaimOnWorkers(t *testing.T) {
+	sess := &m.ConsumerGroupSession{}
+	claim := &m.ConsumerGroupClaim{}
+
+	msgs := make(chan *sarama.ConsumerMessage, 2)
+	msgs <- &sarama.ConsumerMessage{Topic: "internal", Offset: 1}
+	msgs <- &sarama.ConsumerMessage{Topic: "internal", Offset: 2}
+	close(msgs)
+
+	claim.
+		On("Topic").Once().
+		Return("internal")
+
+	var marked []int64
+
+	sess.
+		On("MarkMessage", mock.AnythingOfType("*sarama.ConsumerMessage"), "").
+		Run(func(args mock.Arguments) {
+			marked = append(marked, args.Get(0).(*sarama.ConsumerMessage).Offset)
+		}).
+		Return()
+
+	second := make(chan struct{})
+
+	gch := consumerHandler{}
+
+	gch.reset(map[string][]*subscription{
+		"internal": {{
+			handler: func(msg *sarama.ConsumerMessage) error {
+				// The first message is handled after the second one.
+				if msg.Offset == 1 {
+					<-second
+				} else {
+					close(second)
+				}
+
+				return nil
+			},
+			workers: 2,
+		}},
+	})
+
+	err := gch.ConsumeClaim(sess, &claimWithMessages{ConsumerGroupClaim: claim, msgs: msgs})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{1, 2}, marked)
+}
+
+// claimWithMessages is a claim that passes several messages.
+type claimWithMessages struct {
+	*m.ConsumerGroupClaim
+	msgs chan *sarama.ConsumerMessage
+}
+
+func (c *claimWithMessages) Messages() <-chan *sarama.ConsumerMessage {
+	return c.msgs
+}
@@ -1,25 +1,100 @@
 package kafka
 
 import (
+	"context"
+
+	"NATter/compression"
 	"NATter/driver"
 	"NATter/driver/msgbroker"
+	"NATter/entity"
 
+	"github.com/Shopify/sarama"
 	"github.com/pkg/errors"
 )
 
 type receiver struct {
-	conn  Conn
-	topic string
+	conn       Conn
+	topic      string
+	workers    int
+	sender     driver.Sender // the sender the receiver listens with, messages are replayed to it
+	route      string
+	logPayload bool
 }
 
 func (r *receiver) Listen(sender driver.Sender) error {
-	return r.conn.Subscribe(r.topic, func(payload []byte) error {
-		msgbroker.LogDebugReceived(r.topic, payload)
+	r.sender = sender
+
+	return r.conn.Subscribe(r.topic, r.workers, func(kafkaMsg *sarama.ConsumerMessage) error {
+		msg, err := r.message(kafkaMsg)
+
+		if err != nil {
+			return err
+		}
+
+		return sender.Send(msg)
+	})
+}
+
+func (r *receiver) Positions() ([]*entity.Position, error) {
+	return r.conn.Positions(r.topic)
+}
+
+func (r *receiver) Replay(ctx context.Context, after []*entity.Position) error {
+	if r.sender == nil {
+		return errors.Errorf("route %s does not listen to kafka", r.route)
+	}
+
+	return r.conn.Replay(ctx, r.topic, after, func(kafkaMsg *sarama.ConsumerMessage) error {
+		msg, err := r.message(kafkaMsg)
+
+		if err != nil {
+			return err
+		}
 
-		return sender.Send(payload)
+		return r.sender.Send(msg.WithContext(ctx))
 	})
 }
 
+func (r *receiver) message(kafkaMsg *sarama.ConsumerMessage) (*entity.Message, error) {
+	payload, err := compression.Decompress(header(kafkaMsg, msgbroker.HeaderContentEncoding), kafkaMsg.Value)
+
+	if err != nil {
+		return nil, err
+	}
+
+	msgbroker.LogDebugReceived(r.route, r.topic, msgbroker.Loggable(payload, r.logPayload))
+
+	return &entity.Message{
+		Payload: payload,
+		Subject: r.topic,
+		Header:  headers(kafkaMsg),
+		Position: &entity.Position{
+			Topic:     kafkaMsg.Topic,
+			Partition: kafkaMsg.Partition,
+			Offset:    kafkaMsg.Offset,
+		},
+	}, nil
+}
+
 func (r *receiver) ListenRequest(driver.Sender) error {
 	return errors.New("response is not supported by kafka")
 }
+
+// headers returns the record headers of the message, nil if it has none.
+func headers(msg *sarama.ConsumerMessage) map[string]string {
+	var header map[string]string
+
+	for _, h := range msg.Headers {
+		if h == nil {
+			continue
+		}
+
+		if header == nil {
+			header = make(map[string]string, len(msg.Headers))
+		}
+
+		header[string(h.Key)] = string(h.Value)
+	}
+
+	return header
+}
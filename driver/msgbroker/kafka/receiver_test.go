@@ -1,21 +1,134 @@
 package kafka
 
 import (
+	"context"
 	"testing"
 
+	"NATter/compression"
+	"NATter/entity"
 	m "NATter/mock"
 
+	"github.com/Shopify/sarama"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
+// subscribeWith makes the conn pass the message to the handler of the topic and checks the handler result.
+func subscribeWith(t *testing.T, conn *m.DriverKafkaConn, msg *sarama.ConsumerMessage, expectErr bool) {
+	conn.
+		On("Subscribe", "topic", 1, mock.AnythingOfType("func(*sarama.ConsumerMessage) error")).
+		Run(func(args mock.Arguments) {
+			err := args.Get(2).(func(*sarama.ConsumerMessage) error)(msg)
+
+			if expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+		}).
+		Return(nil)
+}
+
 func TestReceiverListen(t *testing.T) {
 	conn := &m.DriverKafkaConn{}
 
+	subscribeWith(t, conn, &sarama.ConsumerMessage{Topic: "topic", Partition: 2, Offset: 7, Value: []byte("some-data")}, false)
+
+	sender := &m.DriverSender{}
+
+	sender.
+		On("Send", &entity.Message{
+			Payload:  []byte("some-data"),
+			Subject:  "topic",
+			Position: &entity.Position{Topic: "topic", Partition: 2, Offset: 7},
+		}).
+		Return(nil)
+
+	receiver := &receiver{
+		conn:    conn,
+		topic:   "topic",
+		workers: 1,
+	}
+
+	err := receiver.Listen(sender)
+
+	assert.Nil(t, err)
+	sender.AssertExpectations(t)
+}
+
+func TestReceiverListenOnHeaders(t *testing.T) {
+	payload, err := compression.Compress("gzip", []byte("some-data"))
+	assert.Nil(t, err)
+
+	conn := &m.DriverKafkaConn{}
+
+	subscribeWith(t, conn, &sarama.ConsumerMessage{
+		Topic: "topic",
+		Value: payload,
+		Headers: []*sarama.RecordHeader{
+			{Key: []byte("Content-Encoding"), Value: []byte("gzip")},
+			nil,
+			{Key: []byte("X-Priority"), Value: []byte("5")},
+		},
+	}, false)
+
+	sender := &m.DriverSender{}
+
+	sender.
+		On("Send", &entity.Message{
+			Payload:  []byte("some-data"),
+			Subject:  "topic",
+			Header:   map[string]string{"Content-Encoding": "gzip", "X-Priority": "5"},
+			Position: &entity.Position{Topic: "topic"},
+		}).
+		Return(nil)
+
+	receiver := &receiver{
+		conn:    conn,
+		topic:   "topic",
+		workers: 1,
+	}
+
+	err = receiver.Listen(sender)
+
+	assert.Nil(t, err)
+	sender.AssertExpectations(t)
+}
+
+func TestReceiverListenOnUnknownCompression(t *testing.T) {
+	conn := &m.DriverKafkaConn{}
+
+	subscribeWith(t, conn, &sarama.ConsumerMessage{
+		Topic:   "topic",
+		Value:   []byte("some-data"),
+		Headers: []*sarama.RecordHeader{{Key: []byte("Content-Encoding"), Value: []byte("unknown")}},
+	}, true)
+
+	receiver := &receiver{
+		conn:    conn,
+		topic:   "topic",
+		workers: 1,
+	}
+
+	err := receiver.Listen(&m.DriverSender{})
+
+	assert.Nil(t, err)
+}
+
+func TestReceiverReplay(t *testing.T) {
+	ctx := context.Background()
+	after := []*entity.Position{{Topic: "topic", Offset: 3}}
+
+	conn := &m.DriverKafkaConn{}
+
 	conn.
-		On("Subscribe", "topic", mock.AnythingOfType("func([]uint8) error")).
+		On("Replay", ctx, "topic", after, mock.AnythingOfType("func(*sarama.ConsumerMessage) error")).
 		Run(func(args mock.Arguments) {
-			err := args.Get(1).(func([]byte) error)([]byte("some-data"))
+			err := args.Get(3).(func(*sarama.ConsumerMessage) error)(&sarama.ConsumerMessage{
+				Topic:  "topic",
+				Offset: 4,
+				Value:  []byte("some-data"),
+			})
 
 			assert.Nil(t, err)
 		}).
@@ -23,18 +136,31 @@ func TestReceiverListen(t *testing.T) {
 
 	sender := &m.DriverSender{}
 
+	// The replayed message carries the context of the replay.
 	sender.
-		On("Send", []byte("some-data")).
+		On("Send", mock.MatchedBy(func(msg *entity.Message) bool {
+			return msg.Context() == ctx && msg.Position.Offset == 4 && string(msg.Payload) == "some-data"
+		})).
 		Return(nil)
 
 	receiver := &receiver{
-		conn:  conn,
-		topic: "topic",
+		conn:   conn,
+		topic:  "topic",
+		sender: sender,
 	}
 
-	err := receiver.Listen(sender)
+	err := receiver.Replay(ctx, after)
 
 	assert.Nil(t, err)
+	sender.AssertExpectations(t)
+}
+
+func TestReceiverReplayOnNotListening(t *testing.T) {
+	receiver := &receiver{topic: "topic"}
+
+	err := receiver.Replay(context.Background(), nil)
+
+	assert.Error(t, err)
 }
 
 func TestReceiverListenRequest(t *testing.T) {
@@ -1,18 +1,57 @@
 package kafka
 
 import (
+	"NATter/compression"
+	"NATter/driver/msgbroker"
+	"NATter/entity"
+	"NATter/msgtpl"
+
+	"github.com/Shopify/sarama"
 	"github.com/pkg/errors"
 )
 
 type sender struct {
-	conn  Conn
-	topic string
+	conn        Conn
+	topic       *msgtpl.Template
+	compression string
+	route       string
+	logPayload  bool
 }
 
-func (s *sender) Send(payload []byte) error {
-	return s.conn.Publish(s.topic, payload)
+func (s *sender) Send(msg *entity.Message) error {
+	topic, err := s.topic.Execute(msg)
+
+	if err != nil {
+		return err
+	}
+
+	compressed, err := compression.Compress(s.compression, msg.Payload)
+
+	if err != nil {
+		return err
+	}
+
+	prodMsg := &sarama.ProducerMessage{
+		Topic: topic,
+		Value: sarama.ByteEncoder(compressed),
+	}
+
+	if s.compression != "" {
+		prodMsg.Headers = []sarama.RecordHeader{{
+			Key:   []byte(msgbroker.HeaderContentEncoding),
+			Value: []byte(s.compression),
+		}}
+	}
+
+	if err := s.conn.Publish(prodMsg); err != nil {
+		return err
+	}
+
+	msgbroker.LogDebugPublished(s.route, topic, msgbroker.Loggable(msg.Payload, s.logPayload))
+
+	return nil
 }
 
-func (s *sender) Request(payload []byte) ([]byte, error) {
+func (s *sender) Request(*entity.Message) (*entity.Message, error) {
 	return nil, errors.New("request is not supported by kafka")
 }
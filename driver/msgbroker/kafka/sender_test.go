@@ -3,33 +3,80 @@ package kafka
 import (
 	"testing"
 
+	"NATter/entity"
 	m "NATter/mock"
+	"NATter/msgtpl"
 
+	"github.com/Shopify/sarama"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func TestSenderSend(t *testing.T) {
 	conn := &m.DriverKafkaConn{}
 
 	conn.
-		On("Publish", "topic", []byte("some-data")).
+		On("Publish", &sarama.ProducerMessage{
+			Topic: "topic",
+			Value: sarama.ByteEncoder("some-data"),
+		}).
 		Return(nil)
 
 	sender := &sender{
 		conn:  conn,
-		topic: "topic",
+		topic: msgtpl.New("topic"),
 	}
 
-	err := sender.Send([]byte("some-data"))
+	err := sender.Send(entity.NewMessage([]byte("some-data")))
 
 	assert.Nil(t, err)
 }
 
+func TestSenderSendOnCompression(t *testing.T) {
+	conn := &m.DriverKafkaConn{}
+
+	conn.
+		On("Publish", mock.AnythingOfType("*sarama.ProducerMessage")).
+		Run(func(args mock.Arguments) {
+			msg := args.Get(0).(*sarama.ProducerMessage)
+
+			assert.Equal(t, []sarama.RecordHeader{{
+				Key:   []byte("Content-Encoding"),
+				Value: []byte("snappy"),
+			}}, msg.Headers)
+			assert.NotEqual(t, sarama.ByteEncoder("some-data"), msg.Value)
+		}).
+		Return(nil)
+
+	sender := &sender{
+		conn:        conn,
+		topic:       msgtpl.New("topic"),
+		compression: "snappy",
+	}
+
+	err := sender.Send(entity.NewMessage([]byte("some-data")))
+
+	assert.Nil(t, err)
+	conn.AssertExpectations(t)
+}
+
+func TestSenderSendOnUnknownCompression(t *testing.T) {
+	sender := &sender{
+		conn:        &m.DriverKafkaConn{},
+		topic:       msgtpl.New("topic"),
+		compression: "unknown",
+	}
+
+	err := sender.Send(entity.NewMessage([]byte("some-data")))
+
+	assert.Error(t, err)
+}
+
 func TestSenderRequest(t *testing.T) {
 	sender := &sender{}
 
-	respb, err := sender.Request([]byte("request-data"))
+	resp, err := sender.Request(entity.NewMessage([]byte("request-data")))
 
 	assert.Error(t, err)
-	assert.Nil(t, respb)
+	assert.Nil(t, resp)
 }
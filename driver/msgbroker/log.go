@@ -1,85 +1,108 @@
 package msgbroker
 
 import (
+	"time"
+
 	"NATter/log"
 )
 
+func Loggable(payload []byte, enable bool) []byte {
+	if !enable {
+		return nil
+	}
+
+	return payload
+}
+
 func LogErrorHandle(err error, topic string) {
 	ent := log.WithFields(log.Fields{
-		"topic": topic,
+		log.FieldTopic: topic,
 	})
 
 	ent.WithError(err).Error("unable handle message")
 }
 
+// LogWarnDropped reports the message the route has no room for, it is not handled by the route.
+func LogWarnDropped(route, topic string) {
+	log.WithFields(log.Fields{
+		log.FieldRoute: route,
+		log.FieldTopic: topic,
+	}).Warn("route queue is full, message is dropped")
+}
+
 func LogDebugSubscribed(topic string) {
 	log.WithFields(log.Fields{
-		"topic": topic,
+		log.FieldTopic: topic,
 	}).Debug("subscribed")
 }
 
 func LogDebugUnsubscribed(topic string) {
 	log.WithFields(log.Fields{
-		"topic": topic,
+		log.FieldTopic: topic,
 	}).Debug("unsubscribed")
 }
 
-func LogDebugPublished(topic string, payload interface{}) {
+func LogDebugPublished(route, topic string, payload []byte) {
 	ent := log.WithFields(log.Fields{
-		"topic": topic,
+		log.FieldRoute: route,
+		log.FieldTopic: topic,
 	})
 
 	if payload != nil {
-		ent.WithFields(log.Fields{
-			"payload": log.FormatStruct(payload),
+		ent = ent.WithFields(log.Fields{
+			log.FieldPayload: log.FormatPayload(payload),
 		})
 	}
 
 	ent.Debug("published")
 }
 
-func LogDebugReceived(topic string, payload interface{}) {
+func LogDebugReceived(route, topic string, payload []byte) {
 	ent := log.WithFields(log.Fields{
-		"topic": topic,
+		log.FieldRoute: route,
+		log.FieldTopic: topic,
 	})
 
 	if payload != nil {
-		ent.WithFields(log.Fields{
-			"payload": log.FormatStruct(payload),
+		ent = ent.WithFields(log.Fields{
+			log.FieldPayload: log.FormatPayload(payload),
 		})
 	}
 
 	ent.Debug("received")
 }
 
-func LogDebugRequested(topic string, req, resp interface{}) {
+func LogDebugRequested(route, topic string, req, resp []byte, duration time.Duration) {
 	ent := log.WithFields(log.Fields{
-		"topic": topic,
+		log.FieldRoute:    route,
+		log.FieldTopic:    topic,
+		log.FieldDuration: duration.Seconds(),
 	})
 
 	if req != nil {
-		ent.WithFields(log.Fields{
-			"request": log.FormatStruct(req),
+		ent = ent.WithFields(log.Fields{
+			log.FieldRequest: log.FormatPayload(req),
 		})
 	}
 
 	if resp != nil {
-		ent.WithFields(log.Fields{
-			"response": log.FormatStruct(resp),
+		ent = ent.WithFields(log.Fields{
+			log.FieldResponse: log.FormatPayload(resp),
 		})
 	}
 
 	ent.Debug("requested")
 }
 
-func LogDebugResponded(topic string, resp interface{}) {
+func LogDebugResponded(route, topic string, resp []byte) {
 	ent := log.WithFields(log.Fields{
-		"topic": topic,
+		log.FieldRoute: route,
+		log.FieldTopic: topic,
 	})
 
 	if resp != nil {
-		ent.WithFields(log.Fields{
-			"payload": log.FormatStruct(resp),
+		ent = ent.WithFields(log.Fields{
+			log.FieldPayload: log.FormatPayload(resp),
 		})
 	}
 
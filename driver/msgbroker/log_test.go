@@ -2,6 +2,7 @@ package msgbroker
 
 import (
 	"testing"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -9,6 +10,11 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+func TestLoggable(t *testing.T) {
+	assert.Equal(t, []byte("payload"), Loggable([]byte("payload"), true))
+	assert.Nil(t, Loggable([]byte("payload"), false))
+}
+
 func TestLogErrorHandle(t *testing.T) {
 	hook := test.NewGlobal()
 
@@ -76,13 +82,14 @@ func TestLogDebugPublished(t *testing.T) {
 
 	logrus.SetLevel(logrus.DebugLevel)
 
-	LogDebugPublished("topic", "payload")
+	LogDebugPublished("route", "topic", []byte("payload"))
 
 	assert.Equal(t, 1, len(hook.Entries))
 	assert.Equal(t, logrus.DebugLevel, hook.LastEntry().Level)
 	assert.Equal(t, "published", hook.LastEntry().Message)
 	assert.Equal(t, logrus.Fields{
 		"payload": "payload",
+		"route":   "route",
 		"topic":   "topic",
 	}, hook.LastEntry().Data)
 
@@ -92,12 +99,30 @@ func TestLogDebugPublished(t *testing.T) {
 	logrus.SetLevel(logrus.InfoLevel)
 }
 
+func TestLogDebugPublishedOnNoPayload(t *testing.T) {
+	hook := test.NewGlobal()
+
+	logrus.SetLevel(logrus.DebugLevel)
+
+	LogDebugPublished("route", "topic", nil)
+
+	assert.Equal(t, 1, len(hook.Entries))
+	assert.Equal(t, logrus.Fields{
+		"route": "route",
+		"topic": "topic",
+	}, hook.LastEntry().Data)
+
+	hook.Reset()
+
+	logrus.SetLevel(logrus.InfoLevel)
+}
+
 func TestLogDebugRequested(t *testing.T) {
 	hook := test.NewGlobal()
 
 	logrus.SetLevel(logrus.DebugLevel)
 
-	LogDebugRequested("topic", "req", "resp")
+	LogDebugRequested("route", "topic", []byte("req"), []byte("resp"), time.Second)
 
 	assert.Equal(t, 1, len(hook.Entries))
 	assert.Equal(t, logrus.DebugLevel, hook.LastEntry().Level)
@@ -105,7 +130,9 @@ func TestLogDebugRequested(t *testing.T) {
 	assert.Equal(t, logrus.Fields{
 		"request":  "req",
 		"response": "resp",
+		"route":    "route",
 		"topic":    "topic",
+		"duration": float64(1),
 	}, hook.LastEntry().Data)
 
 	hook.Reset()
@@ -119,13 +146,14 @@ func TestLogDebugReceived(t *testing.T) {
 
 	logrus.SetLevel(logrus.DebugLevel)
 
-	LogDebugReceived("topic", "payload")
+	LogDebugReceived("route", "topic", []byte("payload"))
 
 	assert.Equal(t, 1, len(hook.Entries))
 	assert.Equal(t, logrus.DebugLevel, hook.LastEntry().Level)
 	assert.Equal(t, "received", hook.LastEntry().Message)
 	assert.Equal(t, logrus.Fields{
 		"payload": "payload",
+		"route":   "route",
 		"topic":   "topic",
 	}, hook.LastEntry().Data)
 
@@ -140,13 +168,14 @@ func TestLogDebugResponded(t *testing.T) {
 
 	logrus.SetLevel(logrus.DebugLevel)
 
-	LogDebugResponded("topic", "payload")
+	LogDebugResponded("route", "topic", []byte("payload"))
 
 	assert.Equal(t, 1, len(hook.Entries))
 	assert.Equal(t, logrus.DebugLevel, hook.LastEntry().Level)
 	assert.Equal(t, "responded", hook.LastEntry().Message)
 	assert.Equal(t, logrus.Fields{
 		"payload": "payload",
+		"route":   "route",
 		"topic":   "topic",
 	}, hook.LastEntry().Data)
 
@@ -0,0 +1,232 @@
+package mqtt
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"NATter/driver"
+	"NATter/driver/msgbroker"
+	"NATter/entity"
+	"NATter/errtpl"
+	"NATter/log"
+	"NATter/msgtpl"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/pkg/errors"
+)
+
+const (
+	DriverName = entity.DriverMQTT
+
+	HeaderRetained = "Mqtt-Retained" // set to 'true' for a retained message
+
+	defaultTimeout    = time.Second * 10
+	disconnectQuiesce = 250 // milliseconds to finish work on disconnect
+
+	sharePrefix = "$share/"
+)
+
+var ErrTimeout = errors.Wrap(errtpl.ErrGatewayTimeout, "mqtt broker does not respond")
+
+type ConnConfig struct {
+	Servers  []string
+	ClientID string
+	Username string
+	Password string
+
+	QoS          byte          // of subscriptions and published messages, 0 or 1
+	CleanSession bool          // the broker drops the session on disconnect, otherwise ClientID is required
+	ShareGroup   string        // subscriptions are shared by clients of the group if set
+	Timeout      time.Duration // to wait for connecting, subscribing and publishing
+}
+
+type Conn interface {
+	Subscribe(topic string, handler func(mqtt.Message) error) error
+	Publish(topic string, payload []byte, retained bool) error
+}
+
+type conn struct {
+	qos        byte
+	shareGroup string
+	timeout    time.Duration
+
+	client   mqtt.Client
+	mx       *sync.RWMutex
+	handlers map[string][]func(mqtt.Message) error
+}
+
+func NewConn(cfg *ConnConfig) (driver.Conn, error) {
+	if !cfg.CleanSession && cfg.ClientID == "" {
+		return nil, errors.New("mqtt client id is required by a persistent session")
+	}
+
+	conn := &conn{
+		qos:        cfg.QoS,
+		shareGroup: cfg.ShareGroup,
+		timeout:    cfg.Timeout,
+
+		mx:       &sync.RWMutex{},
+		handlers: make(map[string][]func(mqtt.Message) error),
+	}
+
+	if conn.timeout <= 0 {
+		conn.timeout = defaultTimeout
+	}
+
+	opts := mqtt.NewClientOptions().
+		SetClientID(cfg.ClientID).
+		SetUsername(cfg.Username).
+		SetPassword(cfg.Password).
+		SetCleanSession(cfg.CleanSession).
+		SetConnectTimeout(conn.timeout).
+		SetWriteTimeout(conn.timeout).
+		SetAutoReconnect(true).
+		// Handlers run concurrently, so a handler publishing to MQTT does not block receiving.
+		SetOrderMatters(false).
+		// A message is acknowledged only once every handler has succeeded.
+		SetAutoAckDisabled(true).
+		SetOnConnectHandler(func(mqtt.Client) {
+			conn.resubscribe()
+		}).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			log.Error(errtpl.ErrConnect(err, "mqtt"))
+		})
+
+	for _, server := range cfg.Servers {
+		opts.AddBroker(server)
+	}
+
+	conn.client = mqtt.NewClient(opts)
+
+	if err := conn.wait(conn.client.Connect()); err != nil {
+		return nil, errtpl.ErrConnect(err, "mqtt")
+	}
+
+	return conn, nil
+}
+
+func (c *conn) Serve(ctx context.Context) error {
+	<-ctx.Done()
+
+	return nil
+}
+
+func (c *conn) Close() error {
+	c.client.Disconnect(disconnectQuiesce)
+
+	return nil
+}
+
+func (c *conn) Receiver(route *entity.Route) driver.Receiver {
+	return &receiver{
+		conn:       c,
+		topic:      route.MQTTTopic,
+		route:      route.ID,
+		logPayload: log.PayloadEnabled(route.LogPayload),
+	}
+}
+
+func (c *conn) Sender(route *entity.Route) driver.Sender {
+	return &sender{
+		conn:        c,
+		topic:       msgtpl.New(route.MQTTTopic),
+		retain:      route.Retain,
+		compression: route.Compression,
+		route:       route.ID,
+		logPayload:  log.PayloadEnabled(route.LogPayload),
+	}
+}
+
+// Subscribe adds the handler to the topic subscription, so every route
+// receiving from the topic gets each message.
+func (c *conn) Subscribe(topic string, handler func(mqtt.Message) error) error {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if _, ok := c.handlers[topic]; !ok {
+		if err := c.subscribe(topic); err != nil {
+			return err
+		}
+	}
+
+	c.handlers[topic] = append(c.handlers[topic], handler)
+
+	msgbroker.LogDebugSubscribed(topic)
+
+	return nil
+}
+
+func (c *conn) subscribe(topic string) error {
+	err := c.wait(c.client.Subscribe(c.subscription(topic), c.qos, func(_ mqtt.Client, msg mqtt.Message) {
+		c.handle(topic, msg)
+	}))
+
+	if err != nil {
+		return msgbroker.ErrSubscribe(err, topic)
+	}
+
+	return nil
+}
+
+// resubscribe restores subscriptions after the client reconnects since a clean session drops them
+// and a persistent one may have expired.
+func (c *conn) resubscribe() {
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+
+	for topic := range c.handlers {
+		if err := c.subscribe(topic); err != nil {
+			log.Error(err)
+		}
+	}
+}
+
+// subscription returns the topic filter the client subscribes with, clients of the share group
+// get messages of the topic in turn.
+func (c *conn) subscription(topic string) string {
+	if c.shareGroup == "" {
+		return topic
+	}
+
+	return sharePrefix + c.shareGroup + "/" + topic
+}
+
+// handle passes the message to every handler of the topic, a failed one does not affect the others.
+// The message is acknowledged only if all the handlers succeed, otherwise the broker redelivers it
+// to a persistent session when the client reconnects.
+func (c *conn) handle(topic string, msg mqtt.Message) {
+	c.mx.RLock()
+	handlers := c.handlers[topic]
+	c.mx.RUnlock()
+
+	failed := false
+
+	for _, handler := range handlers {
+		if err := handler(msg); err != nil {
+			msgbroker.LogErrorHandle(err, msg.Topic())
+
+			failed = true
+		}
+	}
+
+	if !failed {
+		msg.Ack()
+	}
+}
+
+func (c *conn) Publish(topic string, payload []byte, retained bool) error {
+	if err := c.wait(c.client.Publish(topic, c.qos, retained, payload)); err != nil {
+		return msgbroker.ErrPublish(err, topic)
+	}
+
+	return nil
+}
+
+func (c *conn) wait(token mqtt.Token) error {
+	if !token.WaitTimeout(c.timeout) {
+		return ErrTimeout
+	}
+
+	return token.Error()
+}
@@ -0,0 +1,193 @@
+package mqtt
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/stretchr/testify/assert"
+)
+
+type testToken struct {
+	mqtt.Token
+
+	timeout bool
+	err     error
+}
+
+func (t *testToken) WaitTimeout(time.Duration) bool {
+	return !t.timeout
+}
+
+func (t *testToken) Error() error {
+	return t.err
+}
+
+type subscription struct {
+	filter  string
+	qos     byte
+	handler mqtt.MessageHandler
+}
+
+type published struct {
+	topic    string
+	qos      byte
+	retained bool
+	payload  []byte
+}
+
+// testClient records subscriptions and published messages instead of talking to a broker.
+type testClient struct {
+	mqtt.Client
+
+	token     *testToken
+	subs      []*subscription
+	published []*published
+}
+
+func (c *testClient) Subscribe(filter string, qos byte, handler mqtt.MessageHandler) mqtt.Token {
+	c.subs = append(c.subs, &subscription{filter: filter, qos: qos, handler: handler})
+
+	return c.token
+}
+
+func (c *testClient) Publish(topic string, qos byte, retained bool, payload interface{}) mqtt.Token {
+	c.published = append(c.published, &published{
+		topic:    topic,
+		qos:      qos,
+		retained: retained,
+		payload:  payload.([]byte),
+	})
+
+	return c.token
+}
+
+func newTestConn(client *testClient, shareGroup string) *conn {
+	return &conn{
+		qos:        1,
+		shareGroup: shareGroup,
+		timeout:    time.Second,
+		client:     client,
+		mx:         &sync.RWMutex{},
+		handlers:   make(map[string][]func(mqtt.Message) error),
+	}
+}
+
+func TestConnSubscribe(t *testing.T) {
+	client := &testClient{token: &testToken{}}
+	c := newTestConn(client, "")
+
+	var received []string
+
+	for _, name := range []string{"first", "second"} {
+		name := name
+
+		err := c.Subscribe("devices/+/state", func(msg mqtt.Message) error {
+			received = append(received, name+":"+string(msg.Payload()))
+
+			return errors.New("error")
+		})
+
+		assert.Nil(t, err)
+	}
+
+	assert.Len(t, client.subs, 1)
+	assert.Equal(t, "devices/+/state", client.subs[0].filter)
+	assert.Equal(t, byte(1), client.subs[0].qos)
+
+	msg := &testMessage{topic: "devices/42/state", payload: []byte("on")}
+
+	client.subs[0].handler(client, msg)
+
+	// A failed handler does not affect the others, the message is not acknowledged to be redelivered.
+	assert.Equal(t, []string{"first:on", "second:on"}, received)
+	assert.False(t, msg.acked)
+}
+
+func TestConnSubscribeOnAck(t *testing.T) {
+	client := &testClient{token: &testToken{}}
+	c := newTestConn(client, "")
+
+	for i := 0; i < 2; i++ {
+		err := c.Subscribe("devices/+/state", func(mqtt.Message) error { return nil })
+
+		assert.Nil(t, err)
+	}
+
+	msg := &testMessage{topic: "devices/42/state", payload: []byte("on")}
+
+	client.subs[0].handler(client, msg)
+
+	assert.True(t, msg.acked)
+}
+
+func TestNewConnOnPersistentSession(t *testing.T) {
+	_, err := NewConn(&ConnConfig{Servers: []string{"tcp://127.0.0.1:1883"}})
+
+	assert.EqualError(t, err, "mqtt client id is required by a persistent session")
+}
+
+func TestConnSubscribeOnShareGroup(t *testing.T) {
+	client := &testClient{token: &testToken{}}
+	c := newTestConn(client, "natter")
+
+	err := c.Subscribe("devices/+/state", func(mqtt.Message) error { return nil })
+
+	assert.Nil(t, err)
+	assert.Equal(t, "$share/natter/devices/+/state", client.subs[0].filter)
+
+	// Subscriptions are restored on reconnect.
+	c.resubscribe()
+
+	assert.Len(t, client.subs, 2)
+	assert.Equal(t, "$share/natter/devices/+/state", client.subs[1].filter)
+}
+
+func TestConnSubscribeOnError(t *testing.T) {
+	for _, token := range []*testToken{
+		{err: errors.New("error")},
+		{timeout: true},
+	} {
+		c := newTestConn(&testClient{token: token}, "")
+
+		err := c.Subscribe("devices/+/state", func(mqtt.Message) error { return nil })
+
+		assert.Error(t, err)
+		assert.Empty(t, c.handlers)
+	}
+}
+
+func TestConnPublish(t *testing.T) {
+	client := &testClient{token: &testToken{}}
+	c := newTestConn(client, "")
+
+	err := c.Publish("devices/42/command", []byte("on"), true)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []*published{{
+		topic:    "devices/42/command",
+		qos:      1,
+		retained: true,
+		payload:  []byte("on"),
+	}}, client.published)
+}
+
+func TestConnPublishOnTimeout(t *testing.T) {
+	c := newTestConn(&testClient{token: &testToken{timeout: true}}, "")
+
+	err := c.Publish("devices/42/command", []byte("on"), false)
+
+	assert.True(t, errors.Is(err, ErrTimeout))
+}
+
+func TestNewConnOnError(t *testing.T) {
+	conn, err := NewConn(&ConnConfig{
+		Servers: []string{"tcp://127.0.0.1:1"},
+		Timeout: time.Second,
+	})
+
+	assert.Error(t, err)
+	assert.Nil(t, conn)
+}
@@ -0,0 +1,44 @@
+package mqtt
+
+import (
+	"NATter/driver"
+	"NATter/driver/msgbroker"
+	"NATter/entity"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/pkg/errors"
+)
+
+type receiver struct {
+	conn       Conn
+	topic      string
+	route      string
+	logPayload bool
+}
+
+func (r *receiver) Listen(sender driver.Sender) error {
+	return r.conn.Subscribe(r.topic, func(mqttMsg mqtt.Message) error {
+		msgbroker.LogDebugReceived(r.route, mqttMsg.Topic(), msgbroker.Loggable(mqttMsg.Payload(), r.logPayload))
+
+		return sender.Send(message(mqttMsg))
+	})
+}
+
+func (r *receiver) ListenRequest(driver.Sender) error {
+	return errors.New("response is not supported by mqtt")
+}
+
+// message returns the message of MQTT, a retained message is marked by the header
+// since it may have been published long before the subscription.
+func message(mqttMsg mqtt.Message) *entity.Message {
+	msg := &entity.Message{
+		Payload: mqttMsg.Payload(),
+		Subject: mqttMsg.Topic(),
+	}
+
+	if mqttMsg.Retained() {
+		msg.Header = map[string]string{HeaderRetained: "true"}
+	}
+
+	return msg
+}
@@ -0,0 +1,95 @@
+package mqtt
+
+import (
+	"testing"
+
+	"NATter/entity"
+	m "NATter/mock"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type testMessage struct {
+	mqtt.Message
+
+	topic    string
+	payload  []byte
+	retained bool
+	acked    bool
+}
+
+func (m *testMessage) Topic() string {
+	return m.topic
+}
+
+func (m *testMessage) Payload() []byte {
+	return m.payload
+}
+
+func (m *testMessage) Retained() bool {
+	return m.retained
+}
+
+func (m *testMessage) Ack() {
+	m.acked = true
+}
+
+func TestReceiverListen(t *testing.T) {
+	for _, tc := range []struct {
+		msg      *testMessage
+		expected *entity.Message
+	}{
+		{
+			msg: &testMessage{topic: "devices/42/state", payload: []byte("on")},
+			expected: &entity.Message{
+				Payload: []byte("on"),
+				Subject: "devices/42/state",
+			},
+		},
+		{
+			msg: &testMessage{topic: "devices/42/state", payload: []byte("off"), retained: true},
+			expected: &entity.Message{
+				Payload: []byte("off"),
+				Subject: "devices/42/state",
+				Header:  map[string]string{"Mqtt-Retained": "true"},
+			},
+		},
+	} {
+		conn := &m.DriverMQTTConn{}
+
+		conn.
+			On("Subscribe", "devices/+/state", mock.AnythingOfType("func(mqtt.Message) error")).
+			Run(func(args mock.Arguments) {
+				err := args.Get(1).(func(mqtt.Message) error)(tc.msg)
+
+				assert.Nil(t, err)
+			}).
+			Return(nil)
+
+		sender := &m.DriverSender{}
+
+		sender.
+			On("Send", tc.expected).
+			Return(nil)
+
+		receiver := &receiver{
+			conn:  conn,
+			topic: "devices/+/state",
+		}
+
+		err := receiver.Listen(sender)
+
+		assert.Nil(t, err)
+		sender.AssertExpectations(t)
+	}
+}
+
+func TestReceiverListenRequest(t *testing.T) {
+	receiver := &receiver{}
+
+	err := receiver.ListenRequest(&m.DriverSender{})
+
+	assert.Error(t, err)
+}
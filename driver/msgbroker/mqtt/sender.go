@@ -0,0 +1,46 @@
+package mqtt
+
+import (
+	"NATter/compression"
+	"NATter/driver/msgbroker"
+	"NATter/entity"
+	"NATter/msgtpl"
+
+	"github.com/pkg/errors"
+)
+
+type sender struct {
+	conn        Conn
+	topic       *msgtpl.Template
+	retain      bool
+	compression string
+	route       string
+	logPayload  bool
+}
+
+// Send publishes the message, MQTT has no headers so subscribers have to know the compression of the route.
+func (s *sender) Send(msg *entity.Message) error {
+	topic, err := s.topic.Execute(msg)
+
+	if err != nil {
+		return err
+	}
+
+	payload, err := compression.Compress(s.compression, msg.Payload)
+
+	if err != nil {
+		return err
+	}
+
+	if err := s.conn.Publish(topic, payload, s.retain); err != nil {
+		return err
+	}
+
+	msgbroker.LogDebugPublished(s.route, topic, msgbroker.Loggable(msg.Payload, s.logPayload))
+
+	return nil
+}
+
+func (s *sender) Request(*entity.Message) (*entity.Message, error) {
+	return nil, errors.New("request is not supported by mqtt")
+}
@@ -0,0 +1,83 @@
+package mqtt
+
+import (
+	"errors"
+	"testing"
+
+	"NATter/entity"
+	m "NATter/mock"
+	"NATter/msgtpl"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSenderSend(t *testing.T) {
+	conn := &m.DriverMQTTConn{}
+
+	conn.
+		On("Publish", "devices/42/command", []byte("on"), true).
+		Return(nil)
+
+	sender := &sender{
+		conn:   conn,
+		topic:  msgtpl.New("devices/{{ .uri.id }}/command"),
+		retain: true,
+	}
+
+	err := sender.Send(&entity.Message{
+		Payload: []byte("on"),
+		Params:  map[string]string{"id": "42"},
+	})
+
+	assert.Nil(t, err)
+	conn.AssertExpectations(t)
+}
+
+func TestSenderSendOnCompression(t *testing.T) {
+	conn := &m.DriverMQTTConn{}
+
+	conn.
+		On("Publish", "devices", mock.AnythingOfType("[]uint8"), false).
+		Run(func(args mock.Arguments) {
+			assert.NotEqual(t, []byte("some-data"), args.Get(1))
+		}).
+		Return(nil)
+
+	sender := &sender{
+		conn:        conn,
+		topic:       msgtpl.New("devices"),
+		compression: "gzip",
+	}
+
+	err := sender.Send(entity.NewMessage([]byte("some-data")))
+
+	assert.Nil(t, err)
+	conn.AssertExpectations(t)
+}
+
+func TestSenderSendOnError(t *testing.T) {
+	conn := &m.DriverMQTTConn{}
+
+	conn.
+		On("Publish", "devices", []byte("some-data"), false).
+		Return(errors.New("error"))
+
+	sender := &sender{
+		conn:  conn,
+		topic: msgtpl.New("devices"),
+	}
+
+	err := sender.Send(entity.NewMessage([]byte("some-data")))
+
+	assert.Error(t, err)
+}
+
+func TestSenderRequest(t *testing.T) {
+	sender := &sender{}
+
+	resp, err := sender.Request(entity.NewMessage(nil))
+
+	assert.Error(t, err)
+	assert.Nil(t, resp)
+}
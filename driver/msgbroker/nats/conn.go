@@ -12,27 +12,33 @@ import (
 	"NATter/entity"
 	"NATter/errtpl"
 	"NATter/log"
+	"NATter/msgtpl"
 
 	nats "github.com/nats-io/nats.go"
 )
 
 const (
-	DriverName = "nats"
+	DriverName = entity.BrokerNATS
 
-	requestTimeout = time.Second * 10
+	defaultRequestTimeout = time.Second * 10
+
+	// queueSize is the number of messages a route may fall behind the subscription,
+	// then messages of the route are dropped.
+	queueSize = 64
 )
 
 type ConnConfig struct {
-	Servers []string
-	Token   string
-	Group   string
-	Name    string
+	Servers        []string
+	Token          string
+	Group          string
+	Name           string
+	RequestTimeout time.Duration // a route timeout takes precedence
 }
 
 type Conn interface {
-	Subscribe(topic string, handler func(*nats.Msg) error) error
-	Publish(topic string, payload []byte) error
-	Request(topic string, payload []byte) (*nats.Msg, error)
+	Subscribe(topic string, workers int, handler func(*nats.Msg) error, drop func(*nats.Msg)) error
+	Publish(msg *nats.Msg) error
+	Request(ctx context.Context, msg *nats.Msg) (*nats.Msg, error)
 }
 
 type conn struct {
@@ -40,10 +46,12 @@ type conn struct {
 	token   string
 	group   string
 	name    string
+	timeout time.Duration
 
 	*nats.Conn
-	mx   *sync.RWMutex
-	subs map[string]*nats.Subscription
+	mx      *sync.RWMutex
+	subs    map[string]*nats.Subscription
+	workers map[string][]*worker
 }
 
 func NewConn(cfg *ConnConfig) (driver.Conn, error) {
@@ -52,9 +60,11 @@ func NewConn(cfg *ConnConfig) (driver.Conn, error) {
 		token:   cfg.Token,
 		group:   cfg.Group,
 		name:    cfg.Name,
+		timeout: cfg.RequestTimeout,
 
-		mx:   &sync.RWMutex{},
-		subs: make(map[string]*nats.Subscription),
+		mx:      &sync.RWMutex{},
+		subs:    make(map[string]*nats.Subscription),
+		workers: make(map[string][]*worker),
 	}
 
 	var err error
@@ -94,7 +104,12 @@ func (c *conn) unsubscribe(topic string) error {
 		return msgbroker.ErrUnsubscribe(prepareError(err), topic)
 	}
 
+	for _, w := range c.workers[topic] {
+		w.stop()
+	}
+
 	delete(c.subs, topic)
+	delete(c.workers, topic)
 
 	msgbroker.LogDebugUnsubscribed(topic)
 
@@ -109,70 +124,153 @@ func (c *conn) Close() error {
 
 func (c *conn) Receiver(route *entity.Route) driver.Receiver {
 	return &receiver{
-		conn:  c,
-		topic: route.Topic,
+		conn:       c,
+		topic:      route.Topic,
+		stats:      route.Stats,
+		workers:    msgbroker.Workers(route),
+		route:      route.ID,
+		logPayload: log.PayloadEnabled(route.LogPayload),
 	}
 }
 
 func (c *conn) Sender(route *entity.Route) driver.Sender {
 	return &sender{
-		conn:  c,
-		topic: route.Topic,
+		conn:        c,
+		topic:       msgtpl.New(route.Topic),
+		compression: route.Compression,
+		timeout:     c.routeTimeout(route),
+		route:       route.ID,
+		logPayload:  log.PayloadEnabled(route.LogPayload),
 	}
 }
 
-func (c *conn) Subscribe(topic string, handler func(*nats.Msg) error) error {
-	// Check if already subscribed
-	c.mx.RLock()
-	_, ok := c.subs[topic] //nolint:ifshort
-	c.mx.RUnlock()
-
-	if ok {
-		return nil
+func (c *conn) routeTimeout(route *entity.Route) time.Duration {
+	if route.Timeout != 0 {
+		return time.Duration(route.Timeout) * time.Second
 	}
 
-	sub, err := c.QueueSubscribe(topic, c.group, func(msg *nats.Msg) {
-		if err := handler(msg); err != nil {
-			msgbroker.LogErrorHandle(err, topic)
-		}
-	})
-
-	if err != nil {
-		return msgbroker.ErrSubscribe(prepareError(err), topic)
+	if c.timeout != 0 {
+		return c.timeout
 	}
 
+	return defaultRequestTimeout
+}
+
+// Subscribe adds the handler to the topic subscription, so every route
+// receiving from the topic gets each message. Every handler runs on its own
+// workers, a message that does not fit the full queue of the route is passed
+// to drop instead, so a slow route does not hold the others up.
+func (c *conn) Subscribe(topic string, workers int, handler func(*nats.Msg) error, drop func(*nats.Msg)) error {
 	c.mx.Lock()
 	defer c.mx.Unlock()
 
-	c.subs[topic] = sub
+	if _, ok := c.subs[topic]; !ok {
+		sub, err := c.QueueSubscribe(topic, c.group, func(msg *nats.Msg) {
+			c.handle(topic, msg)
+		})
+
+		if err != nil {
+			return msgbroker.ErrSubscribe(prepareError(err), topic)
+		}
+
+		c.subs[topic] = sub
+	}
+
+	c.workers[topic] = append(c.workers[topic], newWorker(topic, workers, handler, drop))
 
 	msgbroker.LogDebugSubscribed(topic)
 
 	return nil
 }
 
-func (c *conn) Publish(topic string, payload []byte) error {
-	err := c.Conn.Publish(topic, payload)
+// handle queues the message to the worker of every route receiving from the topic.
+func (c *conn) handle(topic string, msg *nats.Msg) {
+	c.mx.RLock()
+	workers := c.workers[topic]
+	c.mx.RUnlock()
 
-	if err != nil {
-		return msgbroker.ErrPublish(prepareError(err), topic)
+	for _, w := range workers {
+		w.push(msg)
 	}
+}
 
-	msgbroker.LogDebugPublished(topic, payload)
+// worker passes messages of the topic to the handler of a route, a failed one does not affect the other routes.
+// Messages are handled in the order they are received unless the worker has several goroutines.
+type worker struct {
+	topic   string
+	handler func(*nats.Msg) error
+	drop    func(*nats.Msg)
+	queue   chan *nats.Msg
+	done    chan struct{}
+}
 
-	return nil
+func newWorker(topic string, n int, handler func(*nats.Msg) error, drop func(*nats.Msg)) *worker {
+	w := &worker{
+		topic:   topic,
+		handler: handler,
+		drop:    drop,
+		queue:   make(chan *nats.Msg, queueSize),
+		done:    make(chan struct{}),
+	}
+
+	for i := 0; i < n; i++ {
+		go w.run()
+	}
+
+	return w
+}
+
+func (w *worker) run() {
+	for {
+		select {
+		case msg := <-w.queue:
+			if err := w.handler(msg); err != nil {
+				msgbroker.LogErrorHandle(err, w.topic)
+			}
+		case <-w.done:
+			return
+		}
+	}
 }
 
-func (c *conn) Request(topic string, payload []byte) (*nats.Msg, error) {
-	msg, err := c.Conn.Request(topic, payload, requestTimeout)
+// push queues the message without waiting for the worker, the message that does not fit the full queue
+// is dropped. Messages are ignored once the worker is stopped.
+func (w *worker) push(msg *nats.Msg) {
+	select {
+	case <-w.done:
+		return
+	default:
+	}
+
+	select {
+	case w.queue <- msg:
+	default:
+		w.drop(msg)
+	}
+}
+
+func (w *worker) stop() {
+	close(w.done)
+}
+
+func (c *conn) Publish(msg *nats.Msg) error {
+	err := c.Conn.PublishMsg(msg)
 
 	if err != nil {
-		return nil, msgbroker.ErrBadReply(prepareError(err), topic)
+		return msgbroker.ErrPublish(prepareError(err), msg.Subject)
 	}
 
-	msgbroker.LogDebugRequested(topic, nil, nil)
+	return nil
+}
+
+func (c *conn) Request(ctx context.Context, msg *nats.Msg) (*nats.Msg, error) {
+	resp, err := c.Conn.RequestMsgWithContext(ctx, msg)
 
-	return msg, nil
+	if err != nil {
+		return nil, msgbroker.ErrBadReply(prepareError(err), msg.Subject)
+	}
+
+	return resp, nil
 }
 
 func prepareError(err error) error {
@@ -180,5 +278,9 @@ func prepareError(err error) error {
 		return msgbroker.ErrNoResponders
 	}
 
+	if errors.Is(err, nats.ErrTimeout) {
+		return errtpl.ErrGatewayTimeout
+	}
+
 	return err
 }
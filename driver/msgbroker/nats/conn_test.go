@@ -31,8 +31,9 @@ func (s *ConnTestSuite) SetupSuite() {
 
 func (s *ConnTestSuite) SetupTest() {
 	s.conn = &conn{
-		mx:   &sync.RWMutex{},
-		subs: make(map[string]*nats.Subscription),
+		mx:      &sync.RWMutex{},
+		subs:    make(map[string]*nats.Subscription),
+		workers: make(map[string][]*worker),
 	}
 
 	var err error
@@ -71,9 +72,9 @@ func (s *ConnTestSuite) TestNewConnOnConnectError() {
 }
 
 func (s *ConnTestSuite) TestServe() {
-	err := s.conn.Subscribe("hey", func(*nats.Msg) error {
+	err := s.conn.Subscribe("hey", 1, func(*nats.Msg) error {
 		return nil
-	})
+	}, noDrop)
 
 	assert.Nil(s.T(), err)
 
@@ -89,9 +90,9 @@ func (s *ConnTestSuite) TestServe() {
 }
 
 func (s *ConnTestSuite) TestServeOnUnsubscribeError() {
-	err := s.conn.Subscribe("hey", func(*nats.Msg) error {
+	err := s.conn.Subscribe("hey", 1, func(*nats.Msg) error {
 		return nil
-	})
+	}, noDrop)
 
 	assert.Nil(s.T(), err)
 
@@ -108,9 +109,9 @@ func (s *ConnTestSuite) TestServeOnUnsubscribeError() {
 }
 
 func (s *ConnTestSuite) TestUnsubscribe() {
-	err := s.conn.Subscribe("hey", func(*nats.Msg) error {
+	err := s.conn.Subscribe("hey", 1, func(*nats.Msg) error {
 		return nil
-	})
+	}, noDrop)
 
 	assert.Nil(s.T(), err)
 
@@ -121,9 +122,9 @@ func (s *ConnTestSuite) TestUnsubscribe() {
 }
 
 func (s *ConnTestSuite) TestUnsubscribeOnError() {
-	err := s.conn.Subscribe("hey", func(*nats.Msg) error {
+	err := s.conn.Subscribe("hey", 1, func(*nats.Msg) error {
 		return nil
-	})
+	}, noDrop)
 
 	assert.Nil(s.T(), err)
 
@@ -135,9 +136,9 @@ func (s *ConnTestSuite) TestUnsubscribeOnError() {
 }
 
 func (s *ConnTestSuite) TestUnsubscribeTwice() {
-	err := s.conn.Subscribe("hey", func(*nats.Msg) error {
+	err := s.conn.Subscribe("hey", 1, func(*nats.Msg) error {
 		return nil
-	})
+	}, noDrop)
 
 	assert.Nil(s.T(), err)
 
@@ -174,21 +175,40 @@ func (s *ConnTestSuite) TestSender() {
 		s.Fail("type assertion error")
 	}
 
-	assert.Equal(s.T(), "topic", snd.topic)
+	assert.Equal(s.T(), "topic", snd.topic.String())
 	assert.Equal(s.T(), s.conn, snd.conn)
 }
 
+func (s *ConnTestSuite) TestSenderOnTimeout() {
+	snd, ok := s.conn.Sender(&entity.Route{Topic: "topic"}).(*sender)
+
+	assert.True(s.T(), ok)
+	assert.Equal(s.T(), defaultRequestTimeout, snd.timeout)
+
+	s.conn.timeout = time.Second * 30
+
+	snd, ok = s.conn.Sender(&entity.Route{Topic: "topic"}).(*sender)
+
+	assert.True(s.T(), ok)
+	assert.Equal(s.T(), time.Second*30, snd.timeout)
+
+	snd, ok = s.conn.Sender(&entity.Route{Topic: "topic", Timeout: 5}).(*sender)
+
+	assert.True(s.T(), ok)
+	assert.Equal(s.T(), time.Second*5, snd.timeout)
+}
+
 func (s *ConnTestSuite) TestSubscribe() {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
 
-	err := s.conn.Subscribe("hey", func(msg *nats.Msg) error {
+	err := s.conn.Subscribe("hey", 1, func(msg *nats.Msg) error {
 		assert.Equal(s.T(), "hey", msg.Subject)
 		assert.Equal(s.T(), []byte("hello"), msg.Data)
 
 		cancel()
 
 		return nil
-	})
+	}, noDrop)
 
 	assert.Nil(s.T(), err)
 
@@ -203,57 +223,130 @@ func (s *ConnTestSuite) TestSubscribe() {
 func (s *ConnTestSuite) TestSubscribeOnError() {
 	s.TearDownTest()
 
-	err := s.conn.Subscribe("hey", func(*nats.Msg) error {
+	err := s.conn.Subscribe("hey", 1, func(*nats.Msg) error {
 		return nil
-	})
+	}, noDrop)
 
 	assert.Error(s.T(), err)
 }
 
 func (s *ConnTestSuite) TestSubscribeOnSecondTime() {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	received := make(chan string, 2)
+	release := make(chan struct{})
 
-	err := s.conn.Subscribe("hey", func(msg *nats.Msg) error {
-		assert.Equal(s.T(), "hey", msg.Subject)
+	err := s.conn.Subscribe("hey", 1, func(msg *nats.Msg) error {
+		// The slow route does not hold the second one up.
+		<-release
+
+		received <- "first"
+
+		return errors.New("error")
+	}, noDrop)
+
+	assert.Nil(s.T(), err)
+
+	err = s.conn.Subscribe("hey", 1, func(msg *nats.Msg) error {
 		assert.Equal(s.T(), []byte("hello"), msg.Data)
 
-		cancel()
+		received <- "second"
+
+		close(release)
 
 		return nil
-	})
+	}, noDrop)
 
 	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), 1, len(s.conn.subs))
+	assert.Equal(s.T(), 2, len(s.conn.workers["hey"]))
 
-	err = s.conn.Subscribe("hey", func(msg *nats.Msg) error {
-		assert.Equal(s.T(), "hey", msg.Subject)
-		assert.Equal(s.T(), []byte("h0lle"), msg.Data)
+	err = s.conn.Conn.Publish("hey", []byte("hello"))
 
-		cancel()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "second", <-received)
+	assert.Equal(s.T(), "first", <-received)
+}
+
+func (s *ConnTestSuite) TestSubscribeOnWorkers() {
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+
+	err := s.conn.Subscribe("hey", 2, func(msg *nats.Msg) error {
+		started <- struct{}{}
+
+		<-release
 
 		return nil
+	}, noDrop)
+
+	assert.Nil(s.T(), err)
+
+	assert.Nil(s.T(), s.conn.Conn.Publish("hey", []byte("first")))
+	assert.Nil(s.T(), s.conn.Conn.Publish("hey", []byte("second")))
+
+	// Both messages are handled at once.
+	<-started
+	<-started
+
+	close(release)
+}
+
+func (s *ConnTestSuite) TestSubscribeOnSlowRoute() {
+	release := make(chan struct{})
+	dropped := make(chan struct{}, queueSize)
+
+	err := s.conn.Subscribe("hey", 1, func(msg *nats.Msg) error {
+		<-release
+
+		return nil
+	}, func(msg *nats.Msg) {
+		dropped <- struct{}{}
 	})
 
 	assert.Nil(s.T(), err)
 
-	err = s.conn.Conn.Publish("hey", []byte("hello"))
+	fast := make(chan struct{})
 
-	<-ctx.Done()
+	err = s.conn.Subscribe("hey", 1, func(msg *nats.Msg) error {
+		fast <- struct{}{}
+
+		return nil
+	}, func(msg *nats.Msg) {
+		s.Fail("message of the fast route is dropped")
+	})
 
-	assert.Equal(s.T(), context.Canceled, ctx.Err())
 	assert.Nil(s.T(), err)
+
+	// The slow route holds one message and queues queueSize more, the rest is dropped.
+	for i := 0; i < queueSize+3; i++ {
+		assert.Nil(s.T(), s.conn.Conn.Publish("hey", []byte("hello")))
+
+		select {
+		case <-fast:
+		case <-time.After(time.Second):
+			s.FailNow("fast route is held up by the slow one")
+		}
+	}
+
+	select {
+	case <-dropped:
+	case <-time.After(time.Second):
+		s.Fail("message of the slow route is not dropped")
+	}
+
+	close(release)
 }
 
 func (s *ConnTestSuite) TestSubscribeOnHandlerError() {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
 
-	err := s.conn.Subscribe("hey", func(msg *nats.Msg) error {
+	err := s.conn.Subscribe("hey", 1, func(msg *nats.Msg) error {
 		assert.Equal(s.T(), "hey", msg.Subject)
 		assert.Equal(s.T(), []byte("hello"), msg.Data)
 
 		cancel()
 
 		return errors.New("error")
-	})
+	}, noDrop)
 
 	assert.Nil(s.T(), err)
 
@@ -276,7 +369,7 @@ func (s *ConnTestSuite) TestPublish() {
 
 	assert.Nil(s.T(), err)
 
-	err = s.conn.Publish("hey", []byte("hello"))
+	err = s.conn.Publish(&nats.Msg{Subject: "hey", Data: []byte("hello")})
 
 	<-ctx.Done()
 
@@ -290,7 +383,7 @@ func (s *ConnTestSuite) TestPublish() {
 func (s *ConnTestSuite) TestPublishOnError() {
 	s.TearDownTest()
 
-	err := s.conn.Publish("hey", []byte("hello"))
+	err := s.conn.Publish(&nats.Msg{Subject: "hey", Data: []byte("hello")})
 
 	assert.Error(s.T(), err)
 }
@@ -304,14 +397,20 @@ func (s *ConnTestSuite) TestRequest() {
 
 	assert.Nil(s.T(), err)
 
-	msg, err := s.conn.Request("hey", []byte("hello"))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	msg, err := s.conn.Request(ctx, &nats.Msg{Subject: "hey", Data: []byte("hello")})
 
 	assert.Equal(s.T(), []byte("hi"), msg.Data)
 	assert.Nil(s.T(), err)
 }
 
 func (s *ConnTestSuite) TestRequestOnNoSubscribers() {
-	msg, err := s.conn.Request("hey", []byte("hello"))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	msg, err := s.conn.Request(ctx, &nats.Msg{Subject: "hey", Data: []byte("hello")})
 
 	assert.Nil(s.T(), msg)
 	assert.True(s.T(), errors.Is(err, msgbroker.ErrNoResponders))
@@ -320,12 +419,17 @@ func (s *ConnTestSuite) TestRequestOnNoSubscribers() {
 func (s *ConnTestSuite) TestRequestOnError() {
 	s.TearDownTest()
 
-	msg, err := s.conn.Request("hey", []byte("hello"))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	msg, err := s.conn.Request(ctx, &nats.Msg{Subject: "hey", Data: []byte("hello")})
 
 	assert.Nil(s.T(), msg)
 	assert.Error(s.T(), err)
 }
 
+func noDrop(*nats.Msg) {}
+
 func TestMsgBroker(t *testing.T) {
 	suite.Run(t, &ConnTestSuite{})
 }
@@ -1,41 +1,124 @@
 package nats
 
 import (
+	"strconv"
+
+	"NATter/compression"
 	"NATter/driver"
 	"NATter/driver/msgbroker"
+	"NATter/entity"
+	"NATter/errtpl"
+	"NATter/msgtpl"
 
 	nats "github.com/nats-io/nats.go"
 )
 
 type receiver struct {
-	conn  Conn
-	topic string
+	conn       Conn
+	topic      string
+	stats      *entity.RouteStats
+	workers    int
+	route      string
+	logPayload bool
 }
 
 func (r *receiver) Listen(sender driver.Sender) error {
-	return r.conn.Subscribe(r.topic, func(msg *nats.Msg) error {
-		msgbroker.LogDebugReceived(r.topic, msg.Data)
+	return r.conn.Subscribe(r.topic, r.workers, func(natsMsg *nats.Msg) error {
+		msg, err := r.message(natsMsg)
+
+		if err != nil {
+			return err
+		}
 
-		return sender.Send(msg.Data)
-	})
+		return sender.Send(msg)
+	}, r.drop)
 }
 
 func (r *receiver) ListenRequest(sender driver.Sender) error {
-	return r.conn.Subscribe(r.topic, func(msg *nats.Msg) error {
-		msgbroker.LogDebugReceived(r.topic, msg.Data)
+	return r.conn.Subscribe(r.topic, r.workers, func(natsMsg *nats.Msg) error {
+		msg, err := r.message(natsMsg)
+
+		if err != nil {
+			return err
+		}
 
-		respb, err := sender.Request(msg.Data)
+		resp, err := sender.Request(msg)
 
 		if err != nil {
+			// The requester gets the error status at once instead of waiting for its timeout.
+			code, body := errtpl.Reply(err)
+
+			if rerr := respond(natsMsg, &entity.Message{Payload: body, Status: code}); rerr != nil {
+				msgbroker.LogErrorHandle(rerr, natsMsg.Subject)
+			}
+
 			return err
 		}
 
-		if err := msg.Respond(respb); err != nil {
-			return msgbroker.ErrRespond(err, msg.Subject)
+		if err := respond(natsMsg, resp); err != nil {
+			return err
 		}
 
-		msgbroker.LogDebugResponded(msg.Subject, msg.Data)
+		msgbroker.LogDebugResponded(r.route, natsMsg.Subject, msgbroker.Loggable(resp.Payload, r.logPayload))
 
 		return nil
-	})
+	}, r.dropRequest)
+}
+
+// drop counts and logs the message the route has no room for.
+func (r *receiver) drop(natsMsg *nats.Msg) {
+	if r.stats != nil {
+		r.stats.AddDropped()
+	}
+
+	msgbroker.LogWarnDropped(r.route, natsMsg.Subject)
+}
+
+// dropRequest drops the request, the requester gets the error status at once instead of waiting for its timeout.
+func (r *receiver) dropRequest(natsMsg *nats.Msg) {
+	r.drop(natsMsg)
+
+	code, body := errtpl.Reply(msgbroker.ErrQueueFull)
+
+	if err := respond(natsMsg, &entity.Message{Payload: body, Status: code}); err != nil {
+		msgbroker.LogErrorHandle(err, natsMsg.Subject)
+	}
+}
+
+func respond(natsMsg *nats.Msg, resp *entity.Message) error {
+	reply := &nats.Msg{
+		Data:   resp.Payload,
+		Header: nats.Header{},
+	}
+
+	if resp.Status != 0 {
+		reply.Header.Set(msgbroker.HeaderStatus, strconv.Itoa(resp.Status))
+	}
+
+	if err := natsMsg.RespondMsg(reply); err != nil {
+		return msgbroker.ErrRespond(err, natsMsg.Subject)
+	}
+
+	return nil
+}
+
+func (r *receiver) message(natsMsg *nats.Msg) (*entity.Message, error) {
+	payload, err := decompress(natsMsg)
+
+	if err != nil {
+		return nil, err
+	}
+
+	msgbroker.LogDebugReceived(r.route, natsMsg.Subject, msgbroker.Loggable(payload, r.logPayload))
+
+	return &entity.Message{
+		Payload:   payload,
+		Subject:   natsMsg.Subject,
+		Wildcards: msgtpl.Wildcards(r.topic, natsMsg.Subject),
+		Header:    entity.MessageHeader(natsMsg.Header),
+	}, nil
+}
+
+func decompress(msg *nats.Msg) ([]byte, error) {
+	return compression.Decompress(msg.Header.Get(msgbroker.HeaderContentEncoding), msg.Data)
 }
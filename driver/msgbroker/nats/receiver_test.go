@@ -1,10 +1,16 @@
 package nats
 
 import (
+	"context"
+	"fmt"
 	"sync"
 	"testing"
 	"time"
 
+	"NATter/compression"
+	"NATter/driver/msgbroker"
+	"NATter/entity"
+	"NATter/errtpl"
 	m "NATter/mock"
 
 	"github.com/nats-io/nats-server/v2/server"
@@ -20,13 +26,14 @@ func TestReceiverListen(t *testing.T) {
 	conn := &m.DriverNatsConn{}
 
 	conn.
-		On("Subscribe", "topic", mock.AnythingOfType("func(*nats.Msg) error")).
+		On("Subscribe", "topic", 1, mock.AnythingOfType("func(*nats.Msg) error"), mock.AnythingOfType("func(*nats.Msg)")).
 		Run(func(args mock.Arguments) {
 			msg := &nats.Msg{
-				Data: []byte("some-data"),
+				Subject: "topic",
+				Data:    []byte("some-data"),
 			}
 
-			err := args.Get(1).(func(*nats.Msg) error)(msg)
+			err := args.Get(2).(func(*nats.Msg) error)(msg)
 
 			assert.Nil(t, err)
 		}).
@@ -35,12 +42,13 @@ func TestReceiverListen(t *testing.T) {
 	sender := &m.DriverSender{}
 
 	sender.
-		On("Send", []byte("some-data")).
+		On("Send", &entity.Message{Payload: []byte("some-data"), Subject: "topic"}).
 		Return(nil)
 
 	receiver := &receiver{
-		conn:  conn,
-		topic: "topic",
+		conn:    conn,
+		topic:   "topic",
+		workers: 1,
 	}
 
 	err := receiver.Listen(sender)
@@ -48,12 +56,102 @@ func TestReceiverListen(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestReceiverListenOnCompressed(t *testing.T) {
+	conn := &m.DriverNatsConn{}
+
+	payload, err := compression.Compress("snappy", []byte("some-data"))
+
+	assert.Nil(t, err)
+
+	conn.
+		On("Subscribe", "topic", 1, mock.AnythingOfType("func(*nats.Msg) error"), mock.AnythingOfType("func(*nats.Msg)")).
+		Run(func(args mock.Arguments) {
+			msg := &nats.Msg{
+				Subject: "topic",
+				Header:  nats.Header{"Content-Encoding": []string{"snappy"}},
+				Data:    payload,
+			}
+
+			err := args.Get(2).(func(*nats.Msg) error)(msg)
+
+			assert.Nil(t, err)
+		}).
+		Return(nil)
+
+	sender := &m.DriverSender{}
+
+	sender.
+		On("Send", &entity.Message{
+			Payload: []byte("some-data"),
+			Subject: "topic",
+			Header:  map[string]string{"Content-Encoding": "snappy"},
+		}).
+		Return(nil)
+
+	receiver := &receiver{
+		conn:    conn,
+		topic:   "topic",
+		workers: 1,
+	}
+
+	err = receiver.Listen(sender)
+
+	assert.Nil(t, err)
+	sender.AssertExpectations(t)
+}
+
+func TestReceiverListenOnWildcard(t *testing.T) {
+	conn := &m.DriverNatsConn{}
+
+	conn.
+		On("Subscribe", "user.*.created.>", 1, mock.AnythingOfType("func(*nats.Msg) error"), mock.AnythingOfType("func(*nats.Msg)")).
+		Run(func(args mock.Arguments) {
+			msg := &nats.Msg{
+				Subject: "user.42.created.eu.west",
+				Data:    []byte("some-data"),
+			}
+
+			err := args.Get(2).(func(*nats.Msg) error)(msg)
+
+			assert.Nil(t, err)
+		}).
+		Return(nil)
+
+	sender := &m.DriverSender{}
+
+	sender.
+		On("Send", &entity.Message{
+			Payload:   []byte("some-data"),
+			Subject:   "user.42.created.eu.west",
+			Wildcards: []string{"42", "eu.west"},
+		}).
+		Return(nil)
+
+	receiver := &receiver{
+		conn:    conn,
+		topic:   "user.*.created.>",
+		workers: 1,
+	}
+
+	err := receiver.Listen(sender)
+
+	assert.Nil(t, err)
+	sender.AssertExpectations(t)
+}
+
 type ReceiverTestSuite struct {
 	suite.Suite
 	srv  *server.Server
 	conn *conn
 }
 
+func (s *ReceiverTestSuite) request(msg *nats.Msg) (*nats.Msg, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultRequestTimeout)
+	defer cancel()
+
+	return s.conn.Request(ctx, msg)
+}
+
 func (s *ReceiverTestSuite) SetupSuite() {
 	opts := servertest.DefaultTestOptions
 	opts.Port = 3000
@@ -62,8 +160,9 @@ func (s *ReceiverTestSuite) SetupSuite() {
 
 func (s *ReceiverTestSuite) SetupTest() {
 	s.conn = &conn{
-		mx:   &sync.RWMutex{},
-		subs: make(map[string]*nats.Subscription),
+		mx:      &sync.RWMutex{},
+		subs:    make(map[string]*nats.Subscription),
+		workers: make(map[string][]*worker),
 	}
 
 	var err error
@@ -86,19 +185,20 @@ func (s *ReceiverTestSuite) TestListenRequest() {
 	sender := &m.DriverSender{}
 
 	sender.
-		On("Request", []byte("request-data")).
-		Return([]byte("response-data"), nil)
+		On("Request", &entity.Message{Payload: []byte("request-data"), Subject: "topic"}).
+		Return(entity.NewMessage([]byte("response-data")), nil)
 
 	receiver := &receiver{
-		conn:  s.conn,
-		topic: "topic",
+		conn:    s.conn,
+		topic:   "topic",
+		workers: 1,
 	}
 
 	err := receiver.ListenRequest(sender)
 
 	assert.Nil(s.T(), err)
 
-	msg, err := s.conn.Request("topic", []byte("request-data"))
+	msg, err := s.request(&nats.Msg{Subject: "topic", Data: []byte("request-data")})
 
 	assert.Nil(s.T(), err)
 	assert.Equal(s.T(), []byte("response-data"), msg.Data)
@@ -108,35 +208,106 @@ func (s *ReceiverTestSuite) TestListenRequestOnError() {
 	sender := &m.DriverSender{}
 
 	sender.
-		On("Request", []byte("request-data")).
-		Return([]byte(nil), errors.New("error"))
+		On("Request", &entity.Message{Payload: []byte("request-data"), Subject: "topic"}).
+		Return((*entity.Message)(nil), errors.New("error"))
+
+	receiver := &receiver{
+		conn:    s.conn,
+		topic:   "topic",
+		workers: 1,
+	}
+
+	err := receiver.ListenRequest(sender)
+
+	assert.Nil(s.T(), err)
+
+	msg, err := s.request(&nats.Msg{Subject: "topic", Data: []byte("request-data")})
+
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "500", msg.Header.Get(msgbroker.HeaderStatus))
+	assert.Equal(s.T(), []byte("Internal Server Error"), msg.Data)
+}
+
+func (s *ReceiverTestSuite) TestListenRequestOnStatus() {
+	inputs := []struct {
+		resp   *entity.Message
+		err    error
+		status string
+		data   []byte
+	}{
+		{
+			resp:   &entity.Message{Payload: []byte("response-data"), Status: 201},
+			status: "201",
+			data:   []byte("response-data"),
+		},
+		{
+			err:    &errtpl.StatusError{Code: 400, Body: []byte("bad email")},
+			status: "400",
+			data:   []byte("bad email"),
+		},
+		{
+			err:    errors.Wrap(context.DeadlineExceeded, "endpoint"),
+			status: "504",
+			data:   []byte("Gateway Timeout"),
+		},
+	}
+
+	for i, input := range inputs {
+		topic := fmt.Sprintf("topic%d", i)
+		sender := &m.DriverSender{}
+
+		sender.On("Request", mock.Anything).Return(input.resp, input.err)
+
+		receiver := &receiver{
+			conn:    s.conn,
+			topic:   topic,
+			workers: 1,
+		}
+
+		err := receiver.ListenRequest(sender)
+
+		assert.Nil(s.T(), err)
+
+		msg, err := s.request(&nats.Msg{Subject: topic, Data: []byte("request-data")})
+
+		assert.Nil(s.T(), err)
+		assert.Equalf(s.T(), input.status, msg.Header.Get(msgbroker.HeaderStatus), "case %d", i+1)
+		assert.Equalf(s.T(), input.data, msg.Data, "case %d", i+1)
+	}
+}
+
+func (s *ReceiverTestSuite) TestDropRequest() {
+	stats := &entity.RouteStats{}
 
 	receiver := &receiver{
 		conn:  s.conn,
 		topic: "topic",
+		stats: stats,
 	}
 
-	err := receiver.ListenRequest(sender)
+	_, err := s.conn.Conn.Subscribe("topic", receiver.dropRequest)
 
 	assert.Nil(s.T(), err)
 
-	msg, err := s.conn.Request("topic", []byte("request-data"))
+	msg, err := s.request(&nats.Msg{Subject: "topic", Data: []byte("request-data")})
 
-	assert.Error(s.T(), err)
-	assert.Nil(s.T(), msg)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "503", msg.Header.Get(msgbroker.HeaderStatus))
+	assert.Equal(s.T(), uint64(1), stats.Dropped())
 }
 
 func (s *ReceiverTestSuite) TestListenRequestOnRespondError() {
 	sender := &m.DriverSender{}
 
 	sender.
-		On("Request", []byte("request-data")).
-		Return([]byte("response-data"), nil).
+		On("Request", &entity.Message{Payload: []byte("request-data"), Subject: "topic"}).
+		Return(entity.NewMessage([]byte("response-data")), nil).
 		After(time.Millisecond * 5)
 
 	receiver := &receiver{
-		conn:  s.conn,
-		topic: "topic",
+		conn:    s.conn,
+		topic:   "topic",
+		workers: 1,
 	}
 
 	err := receiver.ListenRequest(sender)
@@ -144,7 +315,7 @@ func (s *ReceiverTestSuite) TestListenRequestOnRespondError() {
 	assert.Nil(s.T(), err)
 
 	go func() {
-		msg, err := s.conn.Request("topic", []byte("request-data"))
+		msg, err := s.request(&nats.Msg{Subject: "topic", Data: []byte("request-data")})
 
 		assert.Error(s.T(), err)
 		assert.Nil(s.T(), msg)
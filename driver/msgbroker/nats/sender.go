@@ -1,20 +1,112 @@
 package nats
 
+import (
+	"context"
+	"time"
+
+	"NATter/compression"
+	"NATter/driver/msgbroker"
+	"NATter/entity"
+	"NATter/msgtpl"
+
+	nats "github.com/nats-io/nats.go"
+)
+
 type sender struct {
-	conn  Conn
-	topic string
+	conn        Conn
+	topic       *msgtpl.Template
+	compression string
+	timeout     time.Duration
+	route       string
+	logPayload  bool
+}
+
+func (s *sender) Send(msg *entity.Message) error {
+	natsMsg, err := s.message(msg)
+
+	if err != nil {
+		return err
+	}
+
+	if err := s.conn.Publish(natsMsg); err != nil {
+		return err
+	}
+
+	msgbroker.LogDebugPublished(s.route, natsMsg.Subject, msgbroker.Loggable(msg.Payload, s.logPayload))
+
+	return nil
+}
+
+func (s *sender) Request(msg *entity.Message) (*entity.Message, error) {
+	natsMsg, err := s.message(msg)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := msg.Context()
+
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+
+	resp, err := s.conn.Request(ctx, natsMsg)
+
+	if err != nil {
+		return nil, err
+	}
+
+	respb, err := decompress(resp)
+
+	if err != nil {
+		return nil, err
+	}
+
+	msgbroker.LogDebugRequested(
+		s.route,
+		natsMsg.Subject,
+		msgbroker.Loggable(msg.Payload, s.logPayload),
+		msgbroker.Loggable(respb, s.logPayload),
+		time.Since(start),
+	)
+
+	return reply(resp.Header, respb)
 }
 
-func (s *sender) Send(payload []byte) error {
-	return s.conn.Publish(s.topic, payload)
+// reply returns the reply with the status set by the responder.
+func reply(header nats.Header, payload []byte) (*entity.Message, error) {
+	var status interface{}
+
+	if value := header.Get(msgbroker.HeaderStatus); value != "" {
+		status = value
+	}
+
+	return msgbroker.Reply(status, payload)
 }
 
-func (s *sender) Request(payload []byte) ([]byte, error) {
-	msg, err := s.conn.Request(s.topic, payload)
+func (s *sender) message(msg *entity.Message) (*nats.Msg, error) {
+	topic, err := s.topic.Execute(msg)
 
 	if err != nil {
 		return nil, err
 	}
 
-	return msg.Data, nil
+	natsMsg := nats.NewMsg(topic)
+
+	natsMsg.Data, err = compression.Compress(s.compression, msg.Payload)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if s.compression != "" {
+		natsMsg.Header.Set(msgbroker.HeaderContentEncoding, s.compression)
+	}
+
+	return natsMsg, nil
 }
@@ -3,64 +3,238 @@ package nats
 import (
 	"testing"
 
+	"NATter/compression"
+	"NATter/driver/msgbroker"
+	"NATter/entity"
+	"NATter/errtpl"
 	m "NATter/mock"
+	"NATter/msgtpl"
 
 	nats "github.com/nats-io/nats.go"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func TestSenderSend(t *testing.T) {
 	conn := &m.DriverNatsConn{}
 
 	conn.
-		On("Publish", "topic", []byte("some-data")).
+		On("Publish", &nats.Msg{
+			Subject: "topic",
+			Header:  nats.Header{},
+			Data:    []byte("some-data"),
+		}).
 		Return(nil)
 
 	sender := &sender{
 		conn:  conn,
-		topic: "topic",
+		topic: msgtpl.New("topic"),
 	}
 
-	err := sender.Send([]byte("some-data"))
+	err := sender.Send(entity.NewMessage([]byte("some-data")))
 
 	assert.Nil(t, err)
 }
 
+func TestSenderSendOnCompression(t *testing.T) {
+	conn := &m.DriverNatsConn{}
+
+	conn.
+		On("Publish", mock.AnythingOfType("*nats.Msg")).
+		Run(func(args mock.Arguments) {
+			msg := args.Get(0).(*nats.Msg)
+
+			assert.Equal(t, "zstd", msg.Header.Get("Content-Encoding"))
+
+			payload, err := compression.Decompress("zstd", msg.Data)
+
+			assert.Nil(t, err)
+			assert.Equal(t, []byte("some-data"), payload)
+		}).
+		Return(nil)
+
+	sender := &sender{
+		conn:        conn,
+		topic:       msgtpl.New("topic"),
+		compression: "zstd",
+	}
+
+	err := sender.Send(entity.NewMessage([]byte("some-data")))
+
+	assert.Nil(t, err)
+	conn.AssertExpectations(t)
+}
+
+func TestSenderSendOnUnknownCompression(t *testing.T) {
+	sender := &sender{
+		conn:        &m.DriverNatsConn{},
+		topic:       msgtpl.New("topic"),
+		compression: "unknown",
+	}
+
+	err := sender.Send(entity.NewMessage([]byte("some-data")))
+
+	assert.Error(t, err)
+}
+
 func TestSenderRequest(t *testing.T) {
 	conn := &m.DriverNatsConn{}
 
 	conn.
-		On("Request", "topic", []byte("request-data")).
+		On("Request", &nats.Msg{
+			Subject: "topic",
+			Header:  nats.Header{},
+			Data:    []byte("request-data"),
+		}).
 		Return(&nats.Msg{
 			Data: []byte("response-data"),
 		}, nil)
 
 	sender := &sender{
 		conn:  conn,
-		topic: "topic",
+		topic: msgtpl.New("topic"),
+	}
+
+	resp, err := sender.Request(entity.NewMessage([]byte("request-data")))
+
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("response-data"), resp.Payload)
+}
+
+func TestSenderRequestOnCompressedResponse(t *testing.T) {
+	conn := &m.DriverNatsConn{}
+
+	payload, err := compression.Compress("gzip", []byte("response-data"))
+	assert.Nil(t, err)
+
+	conn.
+		On("Request", mock.AnythingOfType("*nats.Msg")).
+		Return(&nats.Msg{
+			Header: nats.Header{"Content-Encoding": []string{"gzip"}},
+			Data:   payload,
+		}, nil)
+
+	sender := &sender{
+		conn:  conn,
+		topic: msgtpl.New("topic"),
 	}
 
-	respb, err := sender.Request([]byte("request-data"))
+	resp, err := sender.Request(entity.NewMessage([]byte("request-data")))
 
 	assert.Nil(t, err)
-	assert.Equal(t, []byte("response-data"), respb)
+	assert.Equal(t, []byte("response-data"), resp.Payload)
+}
+
+func TestSenderRequestOnStatus(t *testing.T) {
+	inputs := []struct {
+		status string
+		resp   *entity.Message
+		err    error
+	}{
+		{
+			status: "201",
+			resp:   &entity.Message{Payload: []byte("response-data"), Status: 201},
+		},
+		{
+			status: "404",
+			err:    &errtpl.StatusError{Code: 404, Body: []byte("response-data")},
+		},
+	}
+
+	for i, input := range inputs {
+		conn := &m.DriverNatsConn{}
+
+		conn.
+			On("Request", mock.AnythingOfType("*nats.Msg")).
+			Return(&nats.Msg{
+				Header: nats.Header{msgbroker.HeaderStatus: []string{input.status}},
+				Data:   []byte("response-data"),
+			}, nil)
+
+		sender := &sender{
+			conn:  conn,
+			topic: msgtpl.New("topic"),
+		}
+
+		resp, err := sender.Request(entity.NewMessage([]byte("request-data")))
+
+		assert.Equalf(t, input.resp, resp, "case %d", i+1)
+		assert.Equalf(t, input.err, err, "case %d", i+1)
+	}
+}
+
+func TestSenderRequestOnBadStatus(t *testing.T) {
+	conn := &m.DriverNatsConn{}
+
+	conn.
+		On("Request", mock.AnythingOfType("*nats.Msg")).
+		Return(&nats.Msg{
+			Header: nats.Header{msgbroker.HeaderStatus: []string{"ok"}},
+		}, nil)
+
+	sender := &sender{
+		conn:  conn,
+		topic: msgtpl.New("topic"),
+	}
+
+	resp, err := sender.Request(entity.NewMessage([]byte("request-data")))
+
+	assert.Error(t, err)
+	assert.Nil(t, resp)
 }
 
 func TestSenderRequestOnError(t *testing.T) {
 	conn := &m.DriverNatsConn{}
 
 	conn.
-		On("Request", "topic", []byte("request-data")).
+		On("Request", &nats.Msg{
+			Subject: "topic",
+			Header:  nats.Header{},
+			Data:    []byte("request-data"),
+		}).
 		Return((*nats.Msg)(nil), errors.New("error"))
 
 	sender := &sender{
 		conn:  conn,
-		topic: "topic",
+		topic: msgtpl.New("topic"),
+	}
+
+	resp, err := sender.Request(entity.NewMessage([]byte("request-data")))
+
+	assert.Error(t, err)
+	assert.Nil(t, resp)
+}
+
+func TestSenderSendOnTemplate(t *testing.T) {
+	conn := &m.DriverNatsConn{}
+
+	conn.
+		On("Publish", &nats.Msg{
+			Subject: "orders.eu",
+			Header:  nats.Header{},
+			Data:    []byte(`{"region":"eu"}`),
+		}).
+		Return(nil)
+
+	sender := &sender{
+		conn:  conn,
+		topic: msgtpl.New("orders.{{ .json.region }}"),
+	}
+
+	err := sender.Send(entity.NewMessage([]byte(`{"region":"eu"}`)))
+
+	assert.Nil(t, err)
+	conn.AssertExpectations(t)
+}
+
+func TestSenderSendOnTemplateError(t *testing.T) {
+	sender := &sender{
+		conn:  &m.DriverNatsConn{},
+		topic: msgtpl.New("orders.{{ .json.region }}"),
 	}
 
-	respb, err := sender.Request([]byte("request-data"))
+	err := sender.Send(entity.NewMessage([]byte("some-data")))
 
 	assert.Error(t, err)
-	assert.Nil(t, respb)
 }
@@ -0,0 +1,531 @@
+package redis
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"NATter/driver"
+	"NATter/driver/msgbroker"
+	"NATter/entity"
+	"NATter/errtpl"
+	"NATter/log"
+	"NATter/msgtpl"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+)
+
+const (
+	DriverName = entity.DriverRedis
+
+	FieldPayload = "payload" // field of a stream entry with the payload, other fields are headers
+
+	defaultGroup         = "natter"
+	defaultReadCount     = 10
+	defaultRetryInterval = time.Second * 30
+	defaultClaimIdle     = time.Minute * 5
+	readBlock            = time.Second * 5
+	reconnectDelay       = time.Second * 5
+
+	// Reads entries that have never been delivered to the group.
+	newEntries = ">"
+	// Reads pending entries of the consumer that have not been acknowledged.
+	pendingEntries = "0"
+)
+
+type ConnConfig struct {
+	Addr     string
+	Username string
+	Password string
+	DB       int
+
+	Group         string        // consumer group of streams, shared by NATter instances
+	Consumer      string        // name of the instance in the group, the host name if not set
+	ReadCount     int           // stream entries read at once
+	RetryInterval time.Duration // between reads of pending entries that have failed
+	ClaimIdle     time.Duration // entries pending in other consumers for as long are claimed
+}
+
+type Conn interface {
+	Subscribe(channel string, handler func(*redis.Message) error) error
+	Consume(stream string, handler func(*redis.XMessage) error) error
+	Publish(ctx context.Context, channel string, payload []byte) error
+	Add(ctx context.Context, stream string, maxLen int64, values map[string]interface{}) error
+}
+
+// client is a part of redis.Client the conn uses.
+type client interface {
+	Ping(ctx context.Context) *redis.StatusCmd
+	Publish(ctx context.Context, channel string, message interface{}) *redis.IntCmd
+	Subscribe(ctx context.Context, channels ...string) *redis.PubSub
+	XAdd(ctx context.Context, a *redis.XAddArgs) *redis.StringCmd
+	XGroupCreateMkStream(ctx context.Context, stream, group, start string) *redis.StatusCmd
+	XReadGroup(ctx context.Context, a *redis.XReadGroupArgs) *redis.XStreamSliceCmd
+	XAck(ctx context.Context, stream, group string, ids ...string) *redis.IntCmd
+	XPendingExt(ctx context.Context, a *redis.XPendingExtArgs) *redis.XPendingExtCmd
+	XClaimJustID(ctx context.Context, a *redis.XClaimArgs) *redis.StringSliceCmd
+	Close() error
+}
+
+type conn struct {
+	group         string
+	consumer      string
+	readCount     int64
+	retryInterval time.Duration
+	claimIdle     time.Duration
+
+	client   client
+	ctx      context.Context
+	cancel   context.CancelFunc
+	mx       *sync.RWMutex
+	pubsub   *redis.PubSub
+	channels map[string][]func(*redis.Message) error  // by channel or pattern
+	streams  map[string][]func(*redis.XMessage) error // by stream
+	wg       *sync.WaitGroup
+}
+
+func NewConn(cfg *ConnConfig) (driver.Conn, error) {
+	conn := newConn(redis.NewClient(&redis.Options{
+		Addr:     cfg.Addr,
+		Username: cfg.Username,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	}), cfg)
+
+	if err := conn.client.Ping(conn.ctx).Err(); err != nil {
+		conn.cancel()
+		conn.client.Close()
+
+		return nil, errtpl.ErrConnect(err, "redis")
+	}
+
+	return conn, nil
+}
+
+func newConn(client client, cfg *ConnConfig) *conn {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	conn := &conn{
+		group:         cfg.Group,
+		consumer:      cfg.Consumer,
+		readCount:     int64(cfg.ReadCount),
+		retryInterval: cfg.RetryInterval,
+		claimIdle:     cfg.ClaimIdle,
+
+		client:   client,
+		ctx:      ctx,
+		cancel:   cancel,
+		mx:       &sync.RWMutex{},
+		channels: make(map[string][]func(*redis.Message) error),
+		streams:  make(map[string][]func(*redis.XMessage) error),
+		wg:       &sync.WaitGroup{},
+	}
+
+	if conn.group == "" {
+		conn.group = defaultGroup
+	}
+
+	if conn.consumer == "" {
+		if host, err := os.Hostname(); err == nil {
+			conn.consumer = host
+		} else {
+			conn.consumer = DriverName
+		}
+	}
+
+	if conn.readCount <= 0 {
+		conn.readCount = defaultReadCount
+	}
+
+	if conn.retryInterval <= 0 {
+		conn.retryInterval = defaultRetryInterval
+	}
+
+	if conn.claimIdle <= 0 {
+		conn.claimIdle = defaultClaimIdle
+	}
+
+	return conn
+}
+
+func (c *conn) Serve(ctx context.Context) error {
+	<-ctx.Done()
+
+	return nil
+}
+
+// Close waits for the entries being handled, so they are acknowledged before the client is closed.
+func (c *conn) Close() error {
+	c.cancel()
+
+	c.mx.Lock()
+	pubsub := c.pubsub
+	c.mx.Unlock()
+
+	if pubsub != nil {
+		if err := pubsub.Close(); err != nil {
+			log.Error(errtpl.ErrClose(err, "redis pubsub"))
+		}
+	}
+
+	c.wg.Wait()
+
+	if err := c.client.Close(); err != nil {
+		return errtpl.ErrClose(err, "redis")
+	}
+
+	return nil
+}
+
+func (c *conn) Receiver(route *entity.Route) driver.Receiver {
+	return &receiver{
+		conn:       c,
+		channel:    route.RedisChannel,
+		stream:     route.RedisStream,
+		route:      route.ID,
+		logPayload: log.PayloadEnabled(route.LogPayload),
+	}
+}
+
+func (c *conn) Sender(route *entity.Route) driver.Sender {
+	snd := &sender{
+		conn:        c,
+		maxLen:      int64(route.RedisMaxLen),
+		compression: route.Compression,
+		route:       route.ID,
+		logPayload:  log.PayloadEnabled(route.LogPayload),
+	}
+
+	if route.RedisStream != "" {
+		snd.stream = msgtpl.New(route.RedisStream)
+	} else {
+		snd.channel = msgtpl.New(route.RedisChannel)
+	}
+
+	return snd
+}
+
+// Subscribe adds the handler to the subscription of the channel, a channel with glob-style
+// wildcards is subscribed as a pattern. Every route receiving from the channel gets each message.
+func (c *conn) Subscribe(channel string, handler func(*redis.Message) error) error {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if _, ok := c.channels[channel]; !ok {
+		if err := c.subscribe(channel); err != nil {
+			return msgbroker.ErrSubscribe(err, channel)
+		}
+	}
+
+	c.channels[channel] = append(c.channels[channel], handler)
+
+	msgbroker.LogDebugSubscribed(channel)
+
+	return nil
+}
+
+// subscribe subscribes to the channel with the only pubsub connection, the client restores
+// subscriptions of the connection when it reconnects.
+func (c *conn) subscribe(channel string) error {
+	if c.pubsub == nil {
+		c.pubsub = c.client.Subscribe(c.ctx)
+
+		c.wg.Add(1)
+
+		go c.receive(c.pubsub.Channel())
+	}
+
+	if isPattern(channel) {
+		return c.pubsub.PSubscribe(c.ctx, channel)
+	}
+
+	return c.pubsub.Subscribe(c.ctx, channel)
+}
+
+func (c *conn) receive(messages <-chan *redis.Message) {
+	defer c.wg.Done()
+
+	for msg := range messages {
+		msg := msg
+
+		c.wg.Add(1)
+
+		go func() {
+			defer c.wg.Done()
+
+			c.handleMessage(msg)
+		}()
+	}
+}
+
+// handleMessage passes the message to every handler of its channel or pattern, a failed one
+// does not affect the others.
+func (c *conn) handleMessage(msg *redis.Message) {
+	key := msg.Channel
+
+	if msg.Pattern != "" {
+		key = msg.Pattern
+	}
+
+	c.mx.RLock()
+	handlers := c.channels[key]
+	c.mx.RUnlock()
+
+	for _, handler := range handlers {
+		if err := handler(msg); err != nil {
+			msgbroker.LogErrorHandle(err, msg.Channel)
+		}
+	}
+}
+
+// Consume adds the handler to the reader of the stream, so every route receiving from the stream
+// gets each entry.
+func (c *conn) Consume(stream string, handler func(*redis.XMessage) error) error {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if _, ok := c.streams[stream]; !ok {
+		if err := c.createGroup(stream); err != nil {
+			return msgbroker.ErrSubscribe(err, stream)
+		}
+
+		c.wg.Add(1)
+
+		go c.read(stream)
+	}
+
+	c.streams[stream] = append(c.streams[stream], handler)
+
+	msgbroker.LogDebugSubscribed(stream)
+
+	return nil
+}
+
+// createGroup creates the consumer group reading entries added from now on, the stream is created
+// if it does not exist. The existing group is kept.
+func (c *conn) createGroup(stream string) error {
+	err := c.client.XGroupCreateMkStream(c.ctx, stream, c.group, "$").Err()
+
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+
+	return nil
+}
+
+// read reads entries of the stream in the consumer group until the conn is closed. Pending entries
+// of the consumer are read first and then every retry interval, so failed entries are handled again.
+// Idle entries of other consumers are claimed before, so entries of a consumer that is gone are not
+// left pending.
+func (c *conn) read(stream string) {
+	defer c.wg.Done()
+
+	start := pendingEntries
+	retry := time.Now().Add(c.retryInterval)
+
+	for c.ctx.Err() == nil {
+		if start == newEntries && time.Now().After(retry) {
+			start = pendingEntries
+		}
+
+		if start == pendingEntries {
+			c.claim(stream)
+		}
+
+		res, err := c.client.XReadGroup(c.ctx, &redis.XReadGroupArgs{
+			Group:    c.group,
+			Consumer: c.consumer,
+			Streams:  []string{stream, start},
+			Count:    c.readCount,
+			Block:    readBlock,
+		}).Result()
+
+		if err == redis.Nil {
+			continue
+		}
+
+		if err != nil {
+			if c.ctx.Err() != nil {
+				return
+			}
+
+			log.Error(msgbroker.ErrSubscribe(err, stream))
+
+			select {
+			case <-c.ctx.Done():
+				return
+			case <-time.After(reconnectDelay):
+			}
+
+			// The group is lost if the stream has been deleted.
+			if strings.HasPrefix(err.Error(), "NOGROUP") {
+				if err := c.createGroup(stream); err != nil {
+					log.Error(msgbroker.ErrSubscribe(err, stream))
+				}
+			}
+
+			continue
+		}
+
+		var entries []redis.XMessage
+
+		if len(res) != 0 {
+			entries = res[0].Messages
+		}
+
+		if start != newEntries {
+			if len(entries) == 0 {
+				start = newEntries
+				retry = time.Now().Add(c.retryInterval)
+
+				continue
+			}
+
+			start = entries[len(entries)-1].ID
+		}
+
+		for i := range entries {
+			c.handleEntry(stream, &entries[i])
+		}
+	}
+}
+
+// claim takes over the entries that have been pending in other consumers of the group for the claim
+// idle time, they are read as pending entries of the consumer then. XCLAIM checks the idle time
+// again, so an entry that another consumer has just read is not taken over.
+func (c *conn) claim(stream string) {
+	start := "-"
+
+	for c.ctx.Err() == nil {
+		pending, err := c.client.XPendingExt(c.ctx, &redis.XPendingExtArgs{
+			Stream: stream,
+			Group:  c.group,
+			Start:  start,
+			End:    "+",
+			Count:  c.readCount,
+		}).Result()
+
+		if err != nil {
+			if c.ctx.Err() == nil {
+				msgbroker.LogErrorHandle(errors.Wrap(err, "unable read pending entries"), stream)
+			}
+
+			return
+		}
+
+		var ids []string
+
+		for _, entry := range pending {
+			if entry.Consumer != c.consumer && entry.Idle >= c.claimIdle {
+				ids = append(ids, entry.ID)
+			}
+		}
+
+		if len(ids) != 0 {
+			err := c.client.XClaimJustID(c.ctx, &redis.XClaimArgs{
+				Stream:   stream,
+				Group:    c.group,
+				Consumer: c.consumer,
+				MinIdle:  c.claimIdle,
+				Messages: ids,
+			}).Err()
+
+			if err != nil {
+				if c.ctx.Err() == nil {
+					msgbroker.LogErrorHandle(errors.Wrap(err, "unable claim entries"), stream)
+				}
+
+				return
+			}
+		}
+
+		if int64(len(pending)) < c.readCount {
+			return
+		}
+
+		if start = nextID(pending[len(pending)-1].ID); start == "" {
+			return
+		}
+	}
+}
+
+// nextID returns the least entry ID following the given one, so XPENDING pages through entries
+// without exclusive ranges that older Redis versions lack.
+func nextID(id string) string {
+	i := strings.LastIndexByte(id, '-')
+
+	if i < 0 {
+		return ""
+	}
+
+	seq, err := strconv.ParseUint(id[i+1:], 10, 64)
+
+	if err != nil {
+		return ""
+	}
+
+	return id[:i+1] + strconv.FormatUint(seq+1, 10)
+}
+
+// handleEntry passes the entry to every handler of the stream and acknowledges it if they succeed.
+// A failed entry stays pending to be read again.
+func (c *conn) handleEntry(stream string, entry *redis.XMessage) {
+	c.mx.RLock()
+	handlers := c.streams[stream]
+	c.mx.RUnlock()
+
+	failed := false
+
+	// A pending entry deleted by trimming has no values, it is only acknowledged.
+	if entry.Values != nil {
+		for _, handler := range handlers {
+			if err := handler(entry); err != nil {
+				msgbroker.LogErrorHandle(err, stream)
+
+				failed = true
+			}
+		}
+	}
+
+	if failed {
+		return
+	}
+
+	// The entry is acknowledged even if the conn is being closed.
+	if err := c.client.XAck(context.Background(), stream, c.group, entry.ID).Err(); err != nil {
+		msgbroker.LogErrorHandle(errors.Wrap(err, "unable acknowledge entry"), stream)
+	}
+}
+
+func (c *conn) Publish(ctx context.Context, channel string, payload []byte) error {
+	if err := c.client.Publish(ctx, channel, payload).Err(); err != nil {
+		return msgbroker.ErrPublish(err, channel)
+	}
+
+	return nil
+}
+
+// Add appends the entry to the stream, the stream is trimmed to about maxLen entries if it is set.
+func (c *conn) Add(ctx context.Context, stream string, maxLen int64, values map[string]interface{}) error {
+	err := c.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: stream,
+		MaxLen: maxLen,
+		Approx: true,
+		Values: values,
+	}).Err()
+
+	if err != nil {
+		return msgbroker.ErrPublish(err, stream)
+	}
+
+	return nil
+}
+
+// isPattern reports whether the channel has glob-style wildcards of PSUBSCRIBE.
+func isPattern(channel string) bool {
+	return strings.ContainsAny(channel, "*?[")
+}
@@ -0,0 +1,260 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+// testClient returns prepared results of reads and records acknowledged entries instead of talking to Redis.
+type testClient struct {
+	client
+
+	groupErr error
+	mx       *sync.Mutex
+	reads    [][]redis.XMessage
+	starts   []string
+	acked    []string
+	added    []*redis.XAddArgs
+	pending  []redis.XPendingExt
+	claimed  []string
+}
+
+func newTestClient(reads ...[]redis.XMessage) *testClient {
+	return &testClient{
+		mx:    &sync.Mutex{},
+		reads: reads,
+	}
+}
+
+func (c *testClient) XGroupCreateMkStream(context.Context, string, string, string) *redis.StatusCmd {
+	return redis.NewStatusResult("OK", c.groupErr)
+}
+
+// XReadGroup returns the prepared results in turn, then it blocks until the context is done.
+func (c *testClient) XReadGroup(ctx context.Context, a *redis.XReadGroupArgs) *redis.XStreamSliceCmd {
+	c.mx.Lock()
+
+	c.starts = append(c.starts, a.Streams[1])
+
+	if len(c.reads) == 0 {
+		c.mx.Unlock()
+
+		<-ctx.Done()
+
+		return redis.NewXStreamSliceCmdResult(nil, ctx.Err())
+	}
+
+	entries := c.reads[0]
+	c.reads = c.reads[1:]
+
+	c.mx.Unlock()
+
+	return redis.NewXStreamSliceCmdResult([]redis.XStream{{Stream: a.Streams[0], Messages: entries}}, nil)
+}
+
+func (c *testClient) XAck(_ context.Context, _, _ string, ids ...string) *redis.IntCmd {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	c.acked = append(c.acked, ids...)
+
+	return redis.NewIntResult(int64(len(ids)), nil)
+}
+
+// XPendingExt returns a page of the prepared pending entries starting from the given ID.
+func (c *testClient) XPendingExt(ctx context.Context, a *redis.XPendingExtArgs) *redis.XPendingExtCmd {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	var page []redis.XPendingExt
+
+	for _, entry := range c.pending {
+		if (a.Start == "-" || entry.ID >= a.Start) && int64(len(page)) < a.Count {
+			page = append(page, entry)
+		}
+	}
+
+	cmd := redis.NewXPendingExtCmd(ctx)
+	cmd.SetVal(page)
+
+	return cmd
+}
+
+func (c *testClient) XClaimJustID(_ context.Context, a *redis.XClaimArgs) *redis.StringSliceCmd {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	c.claimed = append(c.claimed, a.Messages...)
+
+	return redis.NewStringSliceResult(a.Messages, nil)
+}
+
+func (c *testClient) XAdd(_ context.Context, a *redis.XAddArgs) *redis.StringCmd {
+	c.added = append(c.added, a)
+
+	return redis.NewStringResult("1-0", nil)
+}
+
+func (c *testClient) Close() error {
+	return nil
+}
+
+func (c *testClient) state() ([]string, []string) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	return append([]string(nil), c.starts...), append([]string(nil), c.acked...)
+}
+
+func TestConnConsume(t *testing.T) {
+	client := newTestClient(
+		[]redis.XMessage{{ID: "1-0", Values: map[string]interface{}{FieldPayload: "pending"}}},
+		nil,
+		[]redis.XMessage{
+			{ID: "2-0", Values: map[string]interface{}{FieldPayload: "ok"}},
+			{ID: "3-0", Values: map[string]interface{}{FieldPayload: "fail"}},
+			{ID: "4-0"},
+		},
+	)
+
+	c := newConn(client, &ConnConfig{RetryInterval: time.Hour})
+
+	err := c.Consume("orders", func(entry *redis.XMessage) error {
+		if entry.Values[FieldPayload] == "fail" {
+			return errors.New("error")
+		}
+
+		return nil
+	})
+
+	assert.Nil(t, err)
+
+	assert.Eventually(t, func() bool {
+		starts, _ := client.state()
+
+		return len(starts) == 4
+	}, time.Second, time.Millisecond)
+
+	assert.Nil(t, c.Close())
+
+	starts, acked := client.state()
+
+	// Pending entries are read first until there are no more of them.
+	assert.Equal(t, []string{"0", "1-0", ">", ">"}, starts)
+
+	// The failed entry stays pending, the trimmed one is only acknowledged.
+	assert.Equal(t, []string{"1-0", "2-0", "4-0"}, acked)
+}
+
+func TestConnClaim(t *testing.T) {
+	client := newTestClient()
+	client.pending = []redis.XPendingExt{
+		{ID: "1-0", Consumer: "gone", Idle: time.Hour},
+		{ID: "1-1", Consumer: "natter", Idle: time.Hour},
+		{ID: "2-0", Consumer: "busy", Idle: time.Second},
+		{ID: "3-0", Consumer: "gone", Idle: time.Hour},
+	}
+
+	c := newConn(client, &ConnConfig{Consumer: "natter", ReadCount: 2, ClaimIdle: time.Minute})
+
+	c.claim("orders")
+
+	// Own entries and entries recently read by other consumers are not claimed.
+	assert.Equal(t, []string{"1-0", "3-0"}, client.claimed)
+}
+
+func TestNextID(t *testing.T) {
+	assert.Equal(t, "1-1", nextID("1-0"))
+	assert.Equal(t, "1526985054069-10", nextID("1526985054069-9"))
+	assert.Equal(t, "", nextID("invalid"))
+}
+
+func TestConnConsumeOnGroupError(t *testing.T) {
+	client := newTestClient()
+	client.groupErr = errors.New("BUSYGROUP Consumer Group name already exists")
+
+	c := newConn(client, &ConnConfig{})
+
+	assert.Nil(t, c.Consume("orders", func(*redis.XMessage) error { return nil }))
+	assert.Nil(t, c.Close())
+
+	client = newTestClient()
+	client.groupErr = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+
+	c = newConn(client, &ConnConfig{})
+
+	assert.Error(t, c.Consume("orders", func(*redis.XMessage) error { return nil }))
+	assert.Empty(t, c.streams)
+}
+
+func TestConnHandleMessage(t *testing.T) {
+	c := newConn(newTestClient(), &ConnConfig{})
+
+	var received []string
+
+	c.channels["orders.*"] = []func(*redis.Message) error{
+		func(msg *redis.Message) error {
+			received = append(received, "pattern:"+msg.Payload)
+
+			return errors.New("error")
+		},
+	}
+
+	c.channels["orders.created"] = []func(*redis.Message) error{
+		func(msg *redis.Message) error {
+			received = append(received, "channel:"+msg.Payload)
+
+			return nil
+		},
+	}
+
+	c.handleMessage(&redis.Message{Channel: "orders.created", Pattern: "orders.*", Payload: "1"})
+	c.handleMessage(&redis.Message{Channel: "orders.created", Payload: "2"})
+
+	assert.Equal(t, []string{"pattern:1", "channel:2"}, received)
+}
+
+func TestConnAdd(t *testing.T) {
+	client := newTestClient()
+	c := newConn(client, &ConnConfig{})
+
+	err := c.Add(context.Background(), "orders", 1000, map[string]interface{}{FieldPayload: []byte("some-data")})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []*redis.XAddArgs{{
+		Stream: "orders",
+		MaxLen: 1000,
+		Approx: true,
+		Values: map[string]interface{}{FieldPayload: []byte("some-data")},
+	}}, client.added)
+}
+
+func TestNewConnDefaults(t *testing.T) {
+	c := newConn(newTestClient(), &ConnConfig{})
+
+	assert.Equal(t, defaultGroup, c.group)
+	assert.NotEmpty(t, c.consumer)
+	assert.Equal(t, int64(defaultReadCount), c.readCount)
+	assert.Equal(t, defaultRetryInterval, c.retryInterval)
+	assert.Equal(t, defaultClaimIdle, c.claimIdle)
+}
+
+func TestIsPattern(t *testing.T) {
+	assert.False(t, isPattern("orders.created"))
+	assert.True(t, isPattern("orders.*"))
+	assert.True(t, isPattern("orders.?"))
+	assert.True(t, isPattern("orders.[ab]"))
+}
+
+func TestNewConnOnError(t *testing.T) {
+	conn, err := NewConn(&ConnConfig{Addr: "127.0.0.1:1"})
+
+	assert.Error(t, err)
+	assert.Nil(t, conn)
+}
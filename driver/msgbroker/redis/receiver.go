@@ -0,0 +1,86 @@
+package redis
+
+import (
+	"fmt"
+
+	"NATter/compression"
+	"NATter/driver"
+	"NATter/driver/msgbroker"
+	"NATter/entity"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+)
+
+type receiver struct {
+	conn       Conn
+	channel    string
+	stream     string
+	route      string
+	logPayload bool
+}
+
+// Listen receives messages of the channel or entries of the stream if the route has it.
+func (r *receiver) Listen(sender driver.Sender) error {
+	if r.stream != "" {
+		return r.conn.Consume(r.stream, func(entry *redis.XMessage) error {
+			msg, err := r.entryMessage(entry)
+
+			if err != nil {
+				return err
+			}
+
+			return sender.Send(msg)
+		})
+	}
+
+	return r.conn.Subscribe(r.channel, func(redisMsg *redis.Message) error {
+		msgbroker.LogDebugReceived(r.route, redisMsg.Channel, msgbroker.Loggable([]byte(redisMsg.Payload), r.logPayload))
+
+		return sender.Send(&entity.Message{
+			Payload: []byte(redisMsg.Payload),
+			Subject: redisMsg.Channel,
+		})
+	})
+}
+
+func (r *receiver) ListenRequest(driver.Sender) error {
+	return errors.New("response is not supported by redis")
+}
+
+// entryMessage returns the message of the stream entry, fields besides the payload are headers.
+func (r *receiver) entryMessage(entry *redis.XMessage) (*entity.Message, error) {
+	var header map[string]string
+
+	for key, value := range entry.Values {
+		if key == FieldPayload {
+			continue
+		}
+
+		if header == nil {
+			header = make(map[string]string, len(entry.Values))
+		}
+
+		header[key] = fmt.Sprint(value)
+	}
+
+	var data []byte
+
+	if value, ok := entry.Values[FieldPayload]; ok {
+		data = []byte(fmt.Sprint(value))
+	}
+
+	payload, err := compression.Decompress(header[msgbroker.HeaderContentEncoding], data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	msgbroker.LogDebugReceived(r.route, r.stream, msgbroker.Loggable(payload, r.logPayload))
+
+	return &entity.Message{
+		Payload: payload,
+		Subject: r.stream,
+		Header:  header,
+	}, nil
+}
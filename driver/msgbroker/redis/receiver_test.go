@@ -0,0 +1,154 @@
+package redis
+
+import (
+	"errors"
+	"testing"
+
+	"NATter/compression"
+	"NATter/entity"
+	m "NATter/mock"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// consumeWith makes the conn pass the entry to the handler of the stream and checks the handler result.
+func consumeWith(t *testing.T, conn *m.DriverRedisConn, entry *redis.XMessage, expectErr bool) {
+	conn.
+		On("Consume", "orders", mock.AnythingOfType("func(*redis.XMessage) error")).
+		Run(func(args mock.Arguments) {
+			err := args.Get(1).(func(*redis.XMessage) error)(entry)
+
+			if expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+		}).
+		Return(nil)
+}
+
+func TestReceiverListen(t *testing.T) {
+	conn := &m.DriverRedisConn{}
+
+	conn.
+		On("Subscribe", "orders.*", mock.AnythingOfType("func(*redis.Message) error")).
+		Run(func(args mock.Arguments) {
+			err := args.Get(1).(func(*redis.Message) error)(&redis.Message{
+				Channel: "orders.created",
+				Pattern: "orders.*",
+				Payload: "some-data",
+			})
+
+			assert.Nil(t, err)
+		}).
+		Return(nil)
+
+	sender := &m.DriverSender{}
+
+	sender.
+		On("Send", &entity.Message{
+			Payload: []byte("some-data"),
+			Subject: "orders.created",
+		}).
+		Return(nil)
+
+	receiver := &receiver{
+		conn:    conn,
+		channel: "orders.*",
+	}
+
+	err := receiver.Listen(sender)
+
+	assert.Nil(t, err)
+	sender.AssertExpectations(t)
+}
+
+func TestReceiverListenOnStream(t *testing.T) {
+	payload, err := compression.Compress("gzip", []byte("some-data"))
+	assert.Nil(t, err)
+
+	conn := &m.DriverRedisConn{}
+
+	consumeWith(t, conn, &redis.XMessage{
+		ID: "1-0",
+		Values: map[string]interface{}{
+			FieldPayload:       string(payload),
+			"Content-Encoding": "gzip",
+			"X-Priority":       "5",
+		},
+	}, false)
+
+	sender := &m.DriverSender{}
+
+	sender.
+		On("Send", &entity.Message{
+			Payload: []byte("some-data"),
+			Subject: "orders",
+			Header:  map[string]string{"Content-Encoding": "gzip", "X-Priority": "5"},
+		}).
+		Return(nil)
+
+	receiver := &receiver{
+		conn:   conn,
+		stream: "orders",
+	}
+
+	err = receiver.Listen(sender)
+
+	assert.Nil(t, err)
+	sender.AssertExpectations(t)
+}
+
+func TestReceiverListenOnSendError(t *testing.T) {
+	conn := &m.DriverRedisConn{}
+
+	consumeWith(t, conn, &redis.XMessage{
+		ID:     "1-0",
+		Values: map[string]interface{}{FieldPayload: "some-data"},
+	}, true)
+
+	sender := &m.DriverSender{}
+
+	sender.
+		On("Send", mock.AnythingOfType("*entity.Message")).
+		Return(errors.New("error"))
+
+	receiver := &receiver{
+		conn:   conn,
+		stream: "orders",
+	}
+
+	err := receiver.Listen(sender)
+
+	assert.Nil(t, err)
+	sender.AssertExpectations(t)
+}
+
+func TestReceiverListenOnUnknownCompression(t *testing.T) {
+	conn := &m.DriverRedisConn{}
+
+	consumeWith(t, conn, &redis.XMessage{
+		ID: "1-0",
+		Values: map[string]interface{}{
+			FieldPayload:       "some-data",
+			"Content-Encoding": "unknown",
+		},
+	}, true)
+
+	receiver := &receiver{
+		conn:   conn,
+		stream: "orders",
+	}
+
+	err := receiver.Listen(&m.DriverSender{})
+
+	assert.Nil(t, err)
+}
+
+func TestReceiverListenRequest(t *testing.T) {
+	receiver := &receiver{}
+
+	assert.Error(t, receiver.ListenRequest(&m.DriverSender{}))
+}
@@ -0,0 +1,78 @@
+package redis
+
+import (
+	"NATter/compression"
+	"NATter/driver/msgbroker"
+	"NATter/entity"
+	"NATter/msgtpl"
+
+	"github.com/pkg/errors"
+)
+
+type sender struct {
+	conn        Conn
+	channel     *msgtpl.Template // set if the route publishes to a channel
+	stream      *msgtpl.Template // set if the route adds entries to a stream
+	maxLen      int64
+	compression string
+	route       string
+	logPayload  bool
+}
+
+// Send adds the message to the stream or publishes it to the channel. A stream entry has
+// the content encoding field, Pub/Sub subscribers have to know the compression of the route.
+func (s *sender) Send(msg *entity.Message) error {
+	payload, err := compression.Compress(s.compression, msg.Payload)
+
+	if err != nil {
+		return err
+	}
+
+	var key string
+
+	if s.stream != nil {
+		key, err = s.add(msg, payload)
+	} else {
+		key, err = s.publish(msg, payload)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	msgbroker.LogDebugPublished(s.route, key, msgbroker.Loggable(msg.Payload, s.logPayload))
+
+	return nil
+}
+
+func (s *sender) add(msg *entity.Message, payload []byte) (string, error) {
+	stream, err := s.stream.Execute(msg)
+
+	if err != nil {
+		return "", err
+	}
+
+	values := map[string]interface{}{
+		FieldPayload: payload,
+	}
+
+	if s.compression != "" {
+		values[msgbroker.HeaderContentEncoding] = s.compression
+	}
+
+	return stream, s.conn.Add(msg.Context(), stream, s.maxLen, values)
+}
+
+func (s *sender) publish(msg *entity.Message, payload []byte) (string, error) {
+	channel, err := s.channel.Execute(msg)
+
+	if err != nil {
+		return "", err
+	}
+
+	return channel, s.conn.Publish(msg.Context(), channel, payload)
+}
+
+func (s *sender) Request(*entity.Message) (*entity.Message, error) {
+	return nil, errors.New("request is not supported by redis")
+}
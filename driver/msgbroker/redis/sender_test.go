@@ -0,0 +1,92 @@
+package redis
+
+import (
+	"errors"
+	"testing"
+
+	"NATter/compression"
+	"NATter/entity"
+	m "NATter/mock"
+	"NATter/msgtpl"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSenderSend(t *testing.T) {
+	conn := &m.DriverRedisConn{}
+
+	conn.
+		On("Publish", "orders.42", []byte("some-data")).
+		Return(nil)
+
+	sender := &sender{
+		conn:    conn,
+		channel: msgtpl.New("orders.{{ .uri.id }}"),
+	}
+
+	err := sender.Send(&entity.Message{
+		Payload: []byte("some-data"),
+		Params:  map[string]string{"id": "42"},
+	})
+
+	assert.Nil(t, err)
+	conn.AssertExpectations(t)
+}
+
+func TestSenderSendOnStream(t *testing.T) {
+	conn := &m.DriverRedisConn{}
+
+	conn.
+		On("Add", "orders", int64(1000), mock.AnythingOfType("map[string]interface {}")).
+		Run(func(args mock.Arguments) {
+			values := args.Get(2).(map[string]interface{})
+
+			assert.Equal(t, "gzip", values["Content-Encoding"])
+
+			payload, err := compression.Decompress("gzip", values[FieldPayload].([]byte))
+
+			assert.Nil(t, err)
+			assert.Equal(t, []byte("some-data"), payload)
+		}).
+		Return(nil)
+
+	sender := &sender{
+		conn:        conn,
+		stream:      msgtpl.New("orders"),
+		maxLen:      1000,
+		compression: "gzip",
+	}
+
+	err := sender.Send(entity.NewMessage([]byte("some-data")))
+
+	assert.Nil(t, err)
+	conn.AssertExpectations(t)
+}
+
+func TestSenderSendOnError(t *testing.T) {
+	conn := &m.DriverRedisConn{}
+
+	conn.
+		On("Add", "orders", int64(0), map[string]interface{}{FieldPayload: []byte("some-data")}).
+		Return(errors.New("error"))
+
+	sender := &sender{
+		conn:   conn,
+		stream: msgtpl.New("orders"),
+	}
+
+	err := sender.Send(entity.NewMessage([]byte("some-data")))
+
+	assert.Error(t, err)
+	conn.AssertExpectations(t)
+}
+
+func TestSenderRequest(t *testing.T) {
+	sender := &sender{}
+
+	resp, err := sender.Request(entity.NewMessage(nil))
+
+	assert.Error(t, err)
+	assert.Nil(t, resp)
+}
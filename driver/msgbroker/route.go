@@ -0,0 +1,13 @@
+package msgbroker
+
+import "NATter/entity"
+
+// Workers returns the number of messages of the route handled at once, the route
+// has as many workers as its rate limit allows concurrent messages.
+func Workers(route *entity.Route) int {
+	if route.RateLimit != nil && route.RateLimit.Concurrency > 1 {
+		return route.RateLimit.Concurrency
+	}
+
+	return 1
+}
@@ -0,0 +1,15 @@
+package msgbroker
+
+import (
+	"testing"
+
+	"NATter/entity"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkers(t *testing.T) {
+	assert.Equal(t, 1, Workers(&entity.Route{}))
+	assert.Equal(t, 1, Workers(&entity.Route{RateLimit: &entity.RouteRateLimit{Rate: 10}}))
+	assert.Equal(t, 4, Workers(&entity.Route{RateLimit: &entity.RouteRateLimit{Concurrency: 4}}))
+}
@@ -0,0 +1,96 @@
+package ws
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"time"
+
+	"NATter/errtpl"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	authTimeout = time.Second * 10
+
+	// Query parameter of the token for browser clients that can not set headers.
+	tokenParam = "token"
+
+	headerAuthorization = "Authorization"
+	headerCookie        = "Cookie"
+	headerURI           = "X-Natter-Uri"
+
+	bearerPrefix = "Bearer "
+)
+
+var ErrUnauthorized = errors.New("unauthorized")
+
+// authorizer checks the upgrade request of a client with the tokens and the auth endpoint,
+// any client is authorized if neither is set.
+type authorizer struct {
+	tokens   []string
+	endpoint string
+	client   *http.Client
+}
+
+func (a *authorizer) authorize(r *http.Request) error {
+	if len(a.tokens) != 0 && !a.validToken(token(r)) {
+		return errors.Wrap(ErrUnauthorized, "invalid token")
+	}
+
+	if a.endpoint == "" {
+		return nil
+	}
+
+	return a.request(r)
+}
+
+func (a *authorizer) validToken(token string) bool {
+	for _, t := range a.tokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// request asks the auth endpoint with the credentials of the client, a 2xx status authorizes it.
+func (a *authorizer) request(r *http.Request) error {
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, a.endpoint, nil)
+
+	if err != nil {
+		return err
+	}
+
+	for _, key := range []string{headerAuthorization, headerCookie} {
+		if value := r.Header.Get(key); value != "" {
+			req.Header.Set(key, value)
+		}
+	}
+
+	req.Header.Set(headerURI, r.URL.RequestURI())
+
+	resp, err := a.client.Do(req)
+
+	if err != nil {
+		return errors.Wrap(err, "auth endpoint")
+	}
+
+	defer resp.Body.Close()
+
+	if !errtpl.IsSuccess(resp.StatusCode) {
+		return errors.Wrapf(ErrUnauthorized, "auth endpoint responded with %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+func token(r *http.Request) string {
+	if header := r.Header.Get(headerAuthorization); strings.HasPrefix(header, bearerPrefix) {
+		return strings.TrimPrefix(header, bearerPrefix)
+	}
+
+	return r.URL.Query().Get(tokenParam)
+}
@@ -0,0 +1,145 @@
+package ws
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"NATter/entity"
+	"NATter/log"
+
+	"github.com/go-chi/chi"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	writeWait = time.Second * 10
+
+	// Query parameter of the topic pattern a client subscribes to.
+	subjectParam = "subject"
+)
+
+// client is a connection of a websocket client.
+type client struct {
+	ws   *websocket.Conn
+	addr string
+	send chan []byte
+
+	subject string            // pattern of subjects the client receives messages of, any if empty
+	params  map[string]string // parameters of the URI
+	header  map[string]string // headers of the upgrade request
+
+	once *sync.Once
+	done chan struct{}
+}
+
+func newClient(ws *websocket.Conn, r *http.Request, buffer int) *client {
+	return &client{
+		ws:      ws,
+		addr:    r.RemoteAddr,
+		send:    make(chan []byte, buffer),
+		subject: r.URL.Query().Get(subjectParam),
+		params:  urlParams(r),
+		header:  entity.MessageHeader(r.Header),
+		once:    &sync.Once{},
+		done:    make(chan struct{}),
+	}
+}
+
+// push queues the payload, the client that does not keep up with messages is disconnected.
+func (c *client) push(payload []byte) bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+
+	select {
+	case c.send <- payload:
+		return true
+	default:
+		log.Warnf("slow client %s is disconnected", c.addr)
+
+		c.close()
+
+		return false
+	}
+}
+
+// close stops the client, the connection is closed by the write pump.
+func (c *client) close() {
+	c.once.Do(func() {
+		close(c.done)
+	})
+}
+
+func (c *client) readPump(pingInterval time.Duration, receive func(*client, []byte)) {
+	defer c.close()
+
+	wait := pingInterval * 2
+
+	_ = c.ws.SetReadDeadline(time.Now().Add(wait))
+
+	c.ws.SetPongHandler(func(string) error {
+		return c.ws.SetReadDeadline(time.Now().Add(wait))
+	})
+
+	for {
+		_, payload, err := c.ws.ReadMessage()
+
+		if err != nil {
+			return
+		}
+
+		_ = c.ws.SetReadDeadline(time.Now().Add(wait))
+
+		receive(c, payload)
+	}
+}
+
+func (c *client) writePump(pingInterval time.Duration) {
+	ticker := time.NewTicker(pingInterval)
+
+	defer ticker.Stop()
+	defer c.ws.Close()
+	defer c.close()
+
+	for {
+		select {
+		case <-c.done:
+			_ = c.ws.WriteControl(
+				websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseGoingAway, ""),
+				time.Now().Add(writeWait),
+			)
+
+			return
+		case payload := <-c.send:
+			_ = c.ws.SetWriteDeadline(time.Now().Add(writeWait))
+
+			if err := c.ws.WriteMessage(websocket.TextMessage, payload); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := c.ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func urlParams(r *http.Request) map[string]string {
+	rctx := chi.RouteContext(r.Context())
+
+	if rctx == nil || len(rctx.URLParams.Keys) == 0 {
+		return nil
+	}
+
+	params := make(map[string]string, len(rctx.URLParams.Keys))
+
+	for i, key := range rctx.URLParams.Keys {
+		params[key] = rctx.URLParams.Values[i]
+	}
+
+	return params
+}
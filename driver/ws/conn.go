@@ -0,0 +1,247 @@
+package ws
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"NATter/driver"
+	"NATter/entity"
+	"NATter/errtpl"
+	"NATter/log"
+
+	"github.com/go-chi/chi"
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+)
+
+const (
+	DriverName = entity.DriverWS
+
+	defaultPingInterval = time.Second * 30
+	defaultBuffer       = 64
+
+	anyOrigin = "*"
+)
+
+type ConnConfig struct {
+	Host string
+	Port string
+
+	Tokens         []string // tokens a client is authorized with, any if empty
+	AuthEndpoint   string   // endpoint that authorizes a client by its upgrade request
+	AllowedOrigins []string // origins of browser clients, the host of the request if empty
+	PingInterval   time.Duration
+	Buffer         int // messages queued per client, a slower client is disconnected
+}
+
+type conn struct {
+	host string
+	port string
+
+	auth         *authorizer
+	upgrader     *websocket.Upgrader
+	pingInterval time.Duration
+	buffer       int
+
+	mx   *sync.Mutex
+	mux  *chi.Mux
+	hubs map[string]*hub // by URI
+	wg   *sync.WaitGroup
+}
+
+func NewConn(cfg *ConnConfig) driver.Conn {
+	conn := &conn{
+		host: cfg.Host,
+		port: cfg.Port,
+
+		auth: &authorizer{
+			tokens:   cfg.Tokens,
+			endpoint: cfg.AuthEndpoint,
+			client:   &http.Client{Timeout: authTimeout},
+		},
+		upgrader: &websocket.Upgrader{
+			CheckOrigin:       checkOrigin(cfg.AllowedOrigins),
+			EnableCompression: true,
+		},
+		pingInterval: cfg.PingInterval,
+		buffer:       cfg.Buffer,
+
+		mx:   &sync.Mutex{},
+		mux:  chi.NewRouter(),
+		hubs: make(map[string]*hub),
+		wg:   &sync.WaitGroup{},
+	}
+
+	if conn.pingInterval <= 0 {
+		conn.pingInterval = defaultPingInterval
+	}
+
+	if conn.buffer <= 0 {
+		conn.buffer = defaultBuffer
+	}
+
+	return conn
+}
+
+func (c *conn) Serve(ctx context.Context) error {
+	c.mx.Lock()
+	count := len(c.hubs)
+	c.mx.Unlock()
+
+	// The server is not started without routes so the port may be left unset.
+	if count == 0 {
+		return nil
+	}
+
+	if c.port == "" {
+		return errtpl.ErrConnect(errors.New("port is not set"), "ws server")
+	}
+
+	srv := http.Server{
+		Addr:    net.JoinHostPort(c.host, c.port),
+		Handler: c.mux,
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		if err := srv.Shutdown(ctx); err != nil {
+			log.Error(errtpl.ErrClose(err, "ws server"))
+		}
+
+		// Hijacked connections are not closed by the server shutdown.
+		c.closeHubs()
+	}()
+
+	err := srv.ListenAndServe()
+
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return errtpl.ErrClose(err, "ws server")
+	}
+
+	log.Debug("closing WS Server")
+
+	c.wg.Wait()
+
+	return nil
+}
+
+func (c *conn) Close() error {
+	return nil
+}
+
+func (c *conn) Receiver(route *entity.Route) driver.Receiver {
+	return &receiver{
+		hub:        c.hub(route.URI),
+		route:      route.ID,
+		logPayload: log.PayloadEnabled(route.LogPayload),
+	}
+}
+
+func (c *conn) Sender(route *entity.Route) driver.Sender {
+	return &sender{
+		hub:        c.hub(route.URI),
+		route:      route.ID,
+		logPayload: log.PayloadEnabled(route.LogPayload),
+	}
+}
+
+// hub returns the hub of clients connected to the URI, routes receiving and sending
+// through the URI share it.
+func (c *conn) hub(uri string) *hub {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if h, ok := c.hubs[uri]; ok {
+		return h
+	}
+
+	h := newHub(uri)
+
+	c.hubs[uri] = h
+	c.mux.Get(uri, c.handle(h))
+
+	return h
+}
+
+func (c *conn) closeHubs() {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	for _, h := range c.hubs {
+		h.close()
+	}
+}
+
+func (c *conn) handle(h *hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := c.auth.authorize(r); err != nil {
+			log.WithFields(log.Fields{
+				log.FieldURI: h.uri,
+			}).WithError(err).Debug("unauthorized")
+
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+
+			return
+		}
+
+		ws, err := c.upgrader.Upgrade(w, r, nil)
+
+		if err != nil {
+			// The upgrader has already responded with the error.
+			return
+		}
+
+		cl := newClient(ws, r, c.buffer)
+
+		h.add(cl)
+
+		c.wg.Add(2)
+
+		go func() {
+			defer c.wg.Done()
+
+			cl.writePump(c.pingInterval)
+		}()
+
+		go func() {
+			defer c.wg.Done()
+
+			cl.readPump(c.pingInterval, h.receive)
+			h.remove(cl)
+		}()
+	}
+}
+
+// checkOrigin allows browser clients from the origins or from any origin with '*',
+// the same origin is allowed if they are not set.
+func checkOrigin(origins []string) func(r *http.Request) bool {
+	if len(origins) == 0 {
+		return nil
+	}
+
+	allowed := make(map[string]struct{}, len(origins))
+
+	for _, origin := range origins {
+		allowed[origin] = struct{}{}
+	}
+
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+
+		if origin == "" {
+			return true
+		}
+
+		_, ok := allowed[origin]
+
+		if !ok {
+			_, ok = allowed[anyOrigin]
+		}
+
+		return ok
+	}
+}
@@ -0,0 +1,203 @@
+package ws
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"NATter/entity"
+	m "NATter/mock"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func dial(t *testing.T, srvr *httptest.Server, path string, header http.Header) (*websocket.Conn, *http.Response, error) {
+	t.Helper()
+
+	ws, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srvr.URL, "http")+path, header)
+
+	if resp != nil {
+		resp.Body.Close()
+	}
+
+	return ws, resp, err
+}
+
+// waitClients waits until the hub has the number of clients.
+func waitClients(t *testing.T, h *hub, count int) {
+	t.Helper()
+
+	assert.Eventually(t, func() bool {
+		h.mx.RLock()
+		defer h.mx.RUnlock()
+
+		return len(h.clients) == count
+	}, time.Second, time.Millisecond)
+}
+
+func TestConnSender(t *testing.T) {
+	c := NewConn(&ConnConfig{}).(*conn)
+	snd := c.Sender(&entity.Route{URI: "/events"})
+
+	srvr := httptest.NewServer(c.mux)
+	defer srvr.Close()
+
+	all, _, err := dial(t, srvr, "/events", nil)
+	assert.Nil(t, err)
+
+	defer all.Close()
+
+	created, _, err := dial(t, srvr, "/events?subject=user.*.created", nil)
+	assert.Nil(t, err)
+
+	defer created.Close()
+
+	waitClients(t, c.hubs["/events"], 2)
+
+	assert.Nil(t, snd.Send(&entity.Message{Payload: []byte("deleted"), Subject: "user.42.deleted"}))
+	assert.Nil(t, snd.Send(&entity.Message{Payload: []byte("created"), Subject: "user.42.created"}))
+
+	for _, expected := range []string{"deleted", "created"} {
+		_, payload, err := all.ReadMessage()
+
+		assert.Nil(t, err)
+		assert.Equal(t, expected, string(payload))
+	}
+
+	_, payload, err := created.ReadMessage()
+
+	assert.Nil(t, err)
+	assert.Equal(t, "created", string(payload))
+}
+
+func TestConnReceiver(t *testing.T) {
+	c := NewConn(&ConnConfig{}).(*conn)
+
+	received := make(chan *entity.Message, 1)
+
+	sender := &m.DriverSender{}
+
+	sender.
+		On("Send", mock.AnythingOfType("*entity.Message")).
+		Run(func(args mock.Arguments) {
+			received <- args.Get(0).(*entity.Message)
+		}).
+		Return(nil)
+
+	err := c.Receiver(&entity.Route{URI: "/user/{id}"}).Listen(sender)
+
+	assert.Nil(t, err)
+
+	srvr := httptest.NewServer(c.mux)
+	defer srvr.Close()
+
+	ws, _, err := dial(t, srvr, "/user/42", http.Header{"X-Client": []string{"dashboard"}})
+	assert.Nil(t, err)
+
+	defer ws.Close()
+
+	assert.Nil(t, ws.WriteMessage(websocket.TextMessage, []byte("request-data")))
+
+	select {
+	case msg := <-received:
+		assert.Equal(t, []byte("request-data"), msg.Payload)
+		assert.Equal(t, map[string]string{"id": "42"}, msg.Params)
+		assert.Equal(t, "dashboard", msg.Header["X-Client"])
+	case <-time.After(time.Second):
+		assert.Fail(t, "message is not received")
+	}
+}
+
+func TestConnOnUnauthorized(t *testing.T) {
+	auth := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Cookie") != "session=valid" {
+			w.WriteHeader(http.StatusForbidden)
+
+			return
+		}
+
+		assert.Equal(t, "/events?token=secret", r.Header.Get("X-Natter-Uri"))
+	}))
+	defer auth.Close()
+
+	c := NewConn(&ConnConfig{
+		Tokens:       []string{"secret"},
+		AuthEndpoint: auth.URL,
+	}).(*conn)
+
+	c.hub("/events")
+
+	srvr := httptest.NewServer(c.mux)
+	defer srvr.Close()
+
+	_, resp, err := dial(t, srvr, "/events?token=wrong", http.Header{"Cookie": []string{"session=valid"}})
+
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	_, resp, err = dial(t, srvr, "/events?token=secret", http.Header{"Cookie": []string{"session=expired"}})
+
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	ws, _, err := dial(t, srvr, "/events?token=secret", http.Header{"Cookie": []string{"session=valid"}})
+
+	assert.Nil(t, err)
+
+	ws.Close()
+}
+
+func TestConnOnOrigin(t *testing.T) {
+	c := NewConn(&ConnConfig{
+		AllowedOrigins: []string{"https://dashboard.example.com"},
+	}).(*conn)
+
+	c.hub("/events")
+
+	srvr := httptest.NewServer(c.mux)
+	defer srvr.Close()
+
+	_, resp, err := dial(t, srvr, "/events", http.Header{"Origin": []string{"https://evil.example.com"}})
+
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	ws, _, err := dial(t, srvr, "/events", http.Header{"Origin": []string{"https://dashboard.example.com"}})
+
+	assert.Nil(t, err)
+
+	ws.Close()
+}
+
+func TestConnServeOnNoRoutes(t *testing.T) {
+	err := NewConn(&ConnConfig{}).Serve(context.Background())
+
+	assert.Nil(t, err)
+}
+
+func TestConnServeOnNoPort(t *testing.T) {
+	c := NewConn(&ConnConfig{})
+	c.Sender(&entity.Route{URI: "/events"})
+
+	err := c.Serve(context.Background())
+
+	assert.Error(t, err)
+}
+
+func TestConnOnTwoway(t *testing.T) {
+	c := NewConn(&ConnConfig{})
+
+	err := c.Receiver(&entity.Route{URI: "/events"}).ListenRequest(&m.DriverSender{})
+
+	assert.Error(t, err)
+
+	resp, err := c.Sender(&entity.Route{URI: "/events"}).Request(entity.NewMessage(nil))
+
+	assert.Error(t, err)
+	assert.Nil(t, resp)
+}
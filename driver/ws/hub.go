@@ -0,0 +1,115 @@
+package ws
+
+import (
+	"sync"
+
+	"NATter/entity"
+	"NATter/log"
+	"NATter/msgtpl"
+)
+
+// hub is a set of clients connected to a URI.
+type hub struct {
+	uri string
+
+	mx       *sync.RWMutex
+	clients  map[*client]struct{}
+	handlers []func(*entity.Message) error
+}
+
+func newHub(uri string) *hub {
+	return &hub{
+		uri:     uri,
+		mx:      &sync.RWMutex{},
+		clients: make(map[*client]struct{}),
+	}
+}
+
+func (h *hub) add(cl *client) {
+	h.mx.Lock()
+	h.clients[cl] = struct{}{}
+	count := len(h.clients)
+	h.mx.Unlock()
+
+	log.WithFields(log.Fields{
+		log.FieldURI: h.uri,
+	}).Debugf("client connected, %d clients", count)
+}
+
+func (h *hub) remove(cl *client) {
+	h.mx.Lock()
+	delete(h.clients, cl)
+	count := len(h.clients)
+	h.mx.Unlock()
+
+	cl.close()
+
+	log.WithFields(log.Fields{
+		log.FieldURI: h.uri,
+	}).Debugf("client disconnected, %d clients", count)
+}
+
+func (h *hub) close() {
+	h.mx.RLock()
+	defer h.mx.RUnlock()
+
+	for cl := range h.clients {
+		cl.close()
+	}
+}
+
+// listen adds the handler of messages received from clients.
+func (h *hub) listen(handler func(*entity.Message) error) {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+
+	h.handlers = append(h.handlers, handler)
+}
+
+func (h *hub) receive(cl *client, payload []byte) {
+	h.mx.RLock()
+	handlers := h.handlers
+	h.mx.RUnlock()
+
+	ent := log.WithFields(log.Fields{
+		log.FieldURI: h.uri,
+	})
+
+	if len(handlers) == 0 {
+		ent.Debug("message of client is ignored, no route receives from the uri")
+
+		return
+	}
+
+	msg := &entity.Message{
+		Payload: payload,
+		Params:  cl.params,
+		Header:  cl.header,
+	}
+
+	for _, handler := range handlers {
+		if err := handler(msg); err != nil {
+			ent.WithError(err).Error("unable handle message")
+		}
+	}
+}
+
+// broadcast queues the payload to clients subscribed to the subject, it returns their number.
+func (h *hub) broadcast(subject string, payload []byte) int {
+	h.mx.RLock()
+	defer h.mx.RUnlock()
+
+	count := 0
+
+	for cl := range h.clients {
+		if cl.subject != "" && !msgtpl.Match(cl.subject, subject) {
+			continue
+		}
+
+		if cl.push(payload) {
+			count++
+		}
+	}
+
+	return count
+}
@@ -0,0 +1,82 @@
+package ws
+
+import (
+	"sync"
+	"testing"
+
+	"NATter/entity"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func testClient(subject string, buffer int) *client {
+	return &client{
+		send:    make(chan []byte, buffer),
+		subject: subject,
+		params:  map[string]string{"id": "42"},
+		once:    &sync.Once{},
+		done:    make(chan struct{}),
+	}
+}
+
+func TestHubBroadcast(t *testing.T) {
+	h := newHub("/events")
+
+	all := testClient("", 1)
+	created := testClient("user.*.created", 1)
+
+	h.add(all)
+	h.add(created)
+
+	assert.Equal(t, 1, h.broadcast("user.42.deleted", []byte("deleted")))
+	assert.Equal(t, []byte("deleted"), <-all.send)
+
+	assert.Equal(t, 2, h.broadcast("user.42.created", []byte("created")))
+	assert.Equal(t, []byte("created"), <-all.send)
+	assert.Equal(t, []byte("created"), <-created.send)
+}
+
+func TestHubBroadcastOnSlowClient(t *testing.T) {
+	h := newHub("/events")
+	cl := testClient("", 1)
+
+	h.add(cl)
+
+	assert.Equal(t, 1, h.broadcast("topic", []byte("queued")))
+	assert.Equal(t, 0, h.broadcast("topic", []byte("dropped")))
+
+	select {
+	case <-cl.done:
+	default:
+		assert.Fail(t, "slow client is not closed")
+	}
+
+	assert.Equal(t, 0, h.broadcast("topic", []byte("closed")))
+}
+
+func TestHubReceive(t *testing.T) {
+	h := newHub("/user/{id}")
+	cl := testClient("", 1)
+
+	// Messages are ignored without handlers.
+	h.receive(cl, []byte("ignored"))
+
+	var received []*entity.Message
+
+	h.listen(func(msg *entity.Message) error {
+		received = append(received, msg)
+
+		return nil
+	})
+	h.listen(func(msg *entity.Message) error {
+		return errors.New("error")
+	})
+
+	h.receive(cl, []byte("request-data"))
+
+	assert.Equal(t, []*entity.Message{{
+		Payload: []byte("request-data"),
+		Params:  map[string]string{"id": "42"},
+	}}, received)
+}
@@ -0,0 +1,40 @@
+package ws
+
+import (
+	"NATter/driver"
+	"NATter/entity"
+	"NATter/log"
+
+	"github.com/pkg/errors"
+)
+
+type receiver struct {
+	hub        *hub
+	route      string
+	logPayload bool
+}
+
+func (r *receiver) Listen(sender driver.Sender) error {
+	r.hub.listen(func(msg *entity.Message) error {
+		ent := log.WithFields(log.Fields{
+			log.FieldRoute: r.route,
+			log.FieldURI:   r.hub.uri,
+		})
+
+		if r.logPayload {
+			ent = ent.WithFields(log.Fields{
+				log.FieldPayload: log.FormatPayload(msg.Payload),
+			})
+		}
+
+		ent.Debug("received")
+
+		return sender.Send(msg)
+	})
+
+	return nil
+}
+
+func (r *receiver) ListenRequest(driver.Sender) error {
+	return errors.New("response is not supported by ws")
+}
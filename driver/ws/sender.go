@@ -0,0 +1,38 @@
+package ws
+
+import (
+	"NATter/entity"
+	"NATter/log"
+
+	"github.com/pkg/errors"
+)
+
+type sender struct {
+	hub        *hub
+	route      string
+	logPayload bool
+}
+
+func (s *sender) Send(msg *entity.Message) error {
+	count := s.hub.broadcast(msg.Subject, msg.Payload)
+
+	ent := log.WithFields(log.Fields{
+		log.FieldRoute: s.route,
+		log.FieldURI:   s.hub.uri,
+		log.FieldTopic: msg.Subject,
+	})
+
+	if s.logPayload {
+		ent = ent.WithFields(log.Fields{
+			log.FieldPayload: log.FormatPayload(msg.Payload),
+		})
+	}
+
+	ent.Debugf("pushed to %d clients", count)
+
+	return nil
+}
+
+func (s *sender) Request(*entity.Message) (*entity.Message, error) {
+	return nil, errors.New("request is not supported by ws")
+}
@@ -0,0 +1,157 @@
+package entity
+
+import (
+	"strings"
+)
+
+// RouteConflict is the reason two routes can not be used together.
+type RouteConflict string
+
+const (
+	RouteConflictURI    RouteConflict = "receive from the same URI"
+	RouteConflictStream RouteConflict = "use the same URI"
+	RouteConflictReply  RouteConflict = "reply to the same requests"
+	RouteConflictCopy   RouteConflict = "are the same"
+)
+
+// RouteConflicts finds routes that shadow each other: HTTP routes with the same URI, HTTP routes
+// receiving from a URI that is streamed to, twoway routes replying to the same topic and copies
+// of a route. Oneway routes receiving from the same topic or WebSocket URI are fanned out.
+// URIs are compared regardless of names and patterns of their path parameters.
+type RouteConflicts struct {
+	sources map[string]int
+	streams map[string]int
+	replies map[string]int
+	pairs   map[string]int
+}
+
+func NewRouteConflicts() *RouteConflicts {
+	return &RouteConflicts{
+		sources: map[string]int{},
+		streams: map[string]int{},
+		replies: map[string]int{},
+		pairs:   map[string]int{},
+	}
+}
+
+// Add registers the route under the number n. If the route conflicts with a registered one,
+// it returns the number of that route and the conflict.
+func (c *RouteConflicts) Add(n int, r *Route) (int, RouteConflict, bool) {
+	comp := r.Mode.Components()
+
+	key := *r
+	key.URI = normalizeURI(r.URI)
+
+	src, dst := key.Source(), key.Destination()
+
+	if comp.Receiver == DriverHTTP {
+		if m, ok := c.sources[src]; ok {
+			return m, RouteConflictURI, true
+		}
+
+		if m, ok := c.streams[key.URI]; ok {
+			return m, RouteConflictStream, true
+		}
+
+		c.sources[src] = n
+	}
+
+	// Streams share the HTTP server, routes streaming to the same URI share clients.
+	if comp.Sender == DriverSSE {
+		if m, ok := c.sources[DriverHTTP+":"+key.URI]; ok {
+			return m, RouteConflictStream, true
+		}
+
+		if _, ok := c.streams[key.URI]; !ok {
+			c.streams[key.URI] = n
+		}
+	}
+
+	if comp.Direction == RouteDirectionTwoway {
+		if m, ok := c.replies[src]; ok {
+			return m, RouteConflictReply, true
+		}
+
+		c.replies[src] = n
+	}
+
+	if m, ok := c.pairs[src+" -> "+dst]; ok {
+		return m, RouteConflictCopy, true
+	}
+
+	c.pairs[src+" -> "+dst] = n
+
+	return 0, "", false
+}
+
+// Source returns the URI or the topic the route receives from.
+func (r *Route) Source() string {
+	comp := r.Mode.Components()
+
+	switch comp.Receiver {
+	case DriverHTTP, DriverWS:
+		return comp.Receiver + ":" + r.URI
+	case DriverMQTT:
+		return comp.Receiver + ":" + r.MQTTTopic
+	case DriverAMQP:
+		return comp.Receiver + ":" + r.AMQPQueue
+	case DriverRedis:
+		return comp.Receiver + ":" + r.redisKey()
+	}
+
+	return comp.Receiver + ":" + r.Topic
+}
+
+// Destination returns the endpoint or the topic the route sends to.
+func (r *Route) Destination() string {
+	comp := r.Mode.Components()
+
+	switch comp.Sender {
+	case DriverHTTP:
+		return comp.Sender + ":" + r.Endpoint
+	case DriverWS, DriverSSE:
+		return comp.Sender + ":" + r.URI
+	case DriverMQTT:
+		return comp.Sender + ":" + r.MQTTTopic
+	case DriverAMQP:
+		return comp.Sender + ":" + r.AMQPExchange + "/" + r.AMQPRoutingKey
+	case DriverRedis:
+		return comp.Sender + ":" + r.redisKey()
+	}
+
+	return comp.Sender + ":" + r.Topic
+}
+
+// redisKey returns the stream or the Pub/Sub channel of the route, a route uses only one of them.
+func (r *Route) redisKey() string {
+	if r.RedisStream != "" {
+		return "stream:" + r.RedisStream
+	}
+
+	return "channel:" + r.RedisChannel
+}
+
+// normalizeURI replaces path parameters of the URI with {}, so /users/{id} and /users/{name:[a-z]+}
+// are the same. Patterns may have braces of their own like {id:[0-9]{3}}.
+func normalizeURI(uri string) string {
+	var b strings.Builder
+
+	depth := 0
+
+	for _, c := range uri {
+		switch {
+		case c == '{':
+			if depth == 0 {
+				b.WriteString("{}")
+			}
+
+			depth++
+		case c == '}' && depth > 0:
+			depth--
+		case depth == 0:
+			b.WriteRune(c)
+		}
+	}
+
+	return b.String()
+}
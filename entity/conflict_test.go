@@ -0,0 +1,93 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouteConflictsAdd(t *testing.T) {
+	for _, tc := range []struct {
+		routes   []*Route
+		conflict RouteConflict
+		with     int
+	}{
+		{
+			routes: []*Route{
+				{Mode: "http-broker-oneway", URI: "/users/{id}", Topic: "topic1"},
+				{Mode: "http-broker-oneway", URI: "/users/{name:[a-z]{3}}", Topic: "topic2"},
+			},
+			conflict: RouteConflictURI,
+		},
+		{
+			routes: []*Route{
+				{Mode: "broker-sse-oneway", Topic: "topic", URI: "/events/{id:[0-9]+}"},
+				{Mode: "http-broker-oneway", URI: "/events/{name}", Topic: "topic"},
+			},
+			conflict: RouteConflictStream,
+		},
+		{
+			routes: []*Route{
+				{Mode: "broker-http-twoway", Topic: "topic", Endpoint: "http://svc/path1"},
+				{Mode: "broker-http-oneway", Topic: "other", Endpoint: "http://svc/path1"},
+				{Mode: "broker-http-twoway", Topic: "topic", Endpoint: "http://svc/path2"},
+			},
+			conflict: RouteConflictReply,
+		},
+		{
+			routes: []*Route{
+				{Mode: "http-broker-oneway", URI: "/events/{id}", Topic: "topic"},
+				{Mode: "broker-http-oneway", Topic: "topic", Endpoint: "http://svc/events"},
+				{Mode: "broker-http-oneway", Topic: "topic", Endpoint: "http://svc/events"},
+			},
+			conflict: RouteConflictCopy,
+			with:     1,
+		},
+		{
+			routes: []*Route{
+				{Mode: "broker-ws-oneway", Topic: "topic", URI: "/events/{id}"},
+				{Mode: "broker-ws-oneway", Topic: "topic", URI: "/events/{user}"},
+			},
+			conflict: RouteConflictCopy,
+		},
+	} {
+		conflicts := NewRouteConflicts()
+		last := len(tc.routes) - 1
+
+		for i, r := range tc.routes[:last] {
+			_, _, ok := conflicts.Add(i, r)
+
+			assert.False(t, ok)
+		}
+
+		with, conflict, ok := conflicts.Add(last, tc.routes[last])
+
+		assert.True(t, ok)
+		assert.Equal(t, tc.conflict, conflict)
+		assert.Equal(t, tc.with, with)
+	}
+}
+
+func TestRouteConflictsAddOnFanOut(t *testing.T) {
+	conflicts := NewRouteConflicts()
+
+	for i, r := range []*Route{
+		{Mode: "http-broker-oneway", URI: "/users/{id}", Topic: "topic"},
+		{Mode: "http-broker-oneway", URI: "/users/{id}/posts", Topic: "topic"},
+		{Mode: "broker-http-oneway", Topic: "topic", Endpoint: "http://svc/path1"},
+		{Mode: "broker-http-oneway", Topic: "topic", Endpoint: "http://svc/path2"},
+		{Mode: "ws-broker-oneway", URI: "/events", Topic: "topic1"},
+		{Mode: "ws-broker-oneway", URI: "/events", Topic: "topic2"},
+	} {
+		_, _, ok := conflicts.Add(i, r)
+
+		assert.False(t, ok)
+	}
+}
+
+func TestNormalizeURI(t *testing.T) {
+	assert.Equal(t, "/users", normalizeURI("/users"))
+	assert.Equal(t, "/users/{}/posts/{}", normalizeURI("/users/{id}/posts/{post}"))
+	assert.Equal(t, "/users/{}", normalizeURI("/users/{id:[0-9]+}"))
+	assert.Equal(t, "/users/{}/posts", normalizeURI("/users/{id:[0-9]{3}}/posts"))
+}
@@ -0,0 +1,65 @@
+package entity
+
+import (
+	"context"
+)
+
+type Message struct {
+	Payload   []byte
+	Params    map[string]string // parameters of an inbound request URI
+	Subject   string            // concrete topic an inbound message is received from
+	Wildcards []string          // subject tokens matched by wildcards of a route topic
+	Header    map[string]string // headers of an inbound request or message
+	Status    int               // status code of a response, zero if unknown
+	Position  *Position         // position of a message received from a partitioned log, nil for other brokers
+
+	ctx context.Context
+}
+
+// Position is the offset of a message in a partition of a topic.
+type Position struct {
+	Topic     string
+	Partition int32
+	Offset    int64
+}
+
+func NewMessage(payload []byte) *Message {
+	return &Message{
+		Payload: payload,
+	}
+}
+
+// Context returns the context of the inbound request the message is received with,
+// it is canceled when the request is.
+func (m *Message) Context() context.Context {
+	if m.ctx != nil {
+		return m.ctx
+	}
+
+	return context.Background()
+}
+
+// WithContext returns a shallow copy of the message with the context.
+func (m *Message) WithContext(ctx context.Context) *Message {
+	res := *m
+	res.ctx = ctx
+
+	return &res
+}
+
+// MessageHeader returns the first value of every key of an HTTP or a broker header.
+func MessageHeader(header map[string][]string) map[string]string {
+	if len(header) == 0 {
+		return nil
+	}
+
+	res := make(map[string]string, len(header))
+
+	for key, values := range header {
+		if len(values) != 0 {
+			res[key] = values[0]
+		}
+	}
+
+	return res
+}
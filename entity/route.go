@@ -1,16 +1,40 @@
 package entity
 
 import (
+	"encoding/json"
+	"fmt"
+	"regexp"
 	"strings"
+	"sync/atomic"
 )
 
+// reservedURIPattern matches URIs of the internal API, /i/* or /i.
+var reservedURIPattern = regexp.MustCompile(`^/i(/.*)?$`)
+
 type Route struct {
-	Mode     RouteMode      `toml:"MODE" json:"mode"`
-	Async    bool           `toml:"ASYNC" json:"async,omitempty"`
-	Topic    string         `toml:"TOPIC" json:"topic,omitempty"`
-	Endpoint string         `toml:"ENDPOINT" json:"endpoint,omitempty"`
-	URI      string         `toml:"URI" json:"uri,omitempty"`
-	Batching *RouteBatching `toml:"BATCHING" json:"batching,omitempty"`
+	ID             string            `toml:"-" json:"id,omitempty"` // identifies the route in logs, set by the router
+	Mode           RouteMode         `toml:"MODE" json:"mode"`
+	Async          bool              `toml:"ASYNC" json:"async,omitempty"`
+	Topic          string            `toml:"TOPIC" json:"topic,omitempty"`
+	Endpoint       string            `toml:"ENDPOINT" json:"endpoint,omitempty"`
+	URI            string            `toml:"URI" json:"uri,omitempty"`
+	MQTTTopic      string            `toml:"MQTT_TOPIC" json:"mqtt_topic,omitempty"`
+	AMQPQueue      string            `toml:"AMQP_QUEUE" json:"amqp_queue,omitempty"`
+	AMQPExchange   string            `toml:"AMQP_EXCHANGE" json:"amqp_exchange,omitempty"`
+	AMQPRoutingKey string            `toml:"AMQP_ROUTING_KEY" json:"amqp_routing_key,omitempty"`
+	RedisChannel   string            `toml:"REDIS_CHANNEL" json:"redis_channel,omitempty"`
+	RedisStream    string            `toml:"REDIS_STREAM" json:"redis_stream,omitempty"`
+	RedisMaxLen    uint32            `toml:"REDIS_MAXLEN" json:"redis_maxlen,omitempty"`
+	Compression    string            `toml:"COMPRESSION" json:"compression,omitempty"`
+	Timeout        uint32            `toml:"TIMEOUT" json:"timeout,omitempty"`
+	Retain         bool              `toml:"RETAIN" json:"retain,omitempty"`
+	LogPayload     *bool             `toml:"LOG_PAYLOAD" json:"log_payload,omitempty"`
+	Batching       *RouteBatching    `toml:"BATCHING" json:"batching,omitempty"`
+	Transform      []*RouteTransform `toml:"TRANSFORM" json:"transform,omitempty"`
+	Filter         string            `toml:"FILTER" json:"filter,omitempty"`
+	RateLimit      *RouteRateLimit   `toml:"RATE_LIMIT" json:"rate_limit,omitempty"`
+	Breaker        *RouteBreaker     `toml:"BREAKER" json:"breaker,omitempty"`
+	Stats          *RouteStats       `toml:"-" json:"stats,omitempty"`
 }
 
 type RouteBatching struct {
@@ -18,6 +42,79 @@ type RouteBatching struct {
 	Capacity uint32 `toml:"CAPACITY" json:"capacity"`
 }
 
+type RouteRateLimit struct {
+	Rate        float64 `toml:"RATE" json:"rate,omitempty"`
+	Burst       int     `toml:"BURST" json:"burst,omitempty"`
+	Concurrency int     `toml:"CONCURRENCY" json:"concurrency,omitempty"`
+}
+
+type RouteBreaker struct {
+	Threshold uint32 `toml:"THRESHOLD" json:"threshold"`
+	Duration  uint32 `toml:"DURATION" json:"duration"`
+	Probes    uint32 `toml:"PROBES" json:"probes,omitempty"`
+}
+
+// RouteTransform is a step of the route payload transformation, only one operation is set per step.
+type RouteTransform struct {
+	Rename   map[string]string      `toml:"RENAME" json:"rename,omitempty"`
+	Remove   []string               `toml:"REMOVE" json:"remove,omitempty"`
+	Add      map[string]interface{} `toml:"ADD" json:"add,omitempty"`
+	Envelope string                 `toml:"ENVELOPE" json:"envelope,omitempty"`
+	JQ       string                 `toml:"JQ" json:"jq,omitempty"`
+	Proto    *RouteTransformProto   `toml:"PROTO" json:"proto,omitempty"`
+}
+
+type RouteTransformProto struct {
+	Descriptor string `toml:"DESCRIPTOR" json:"descriptor"`
+	Message    string `toml:"MESSAGE" json:"message"`
+	To         string `toml:"TO" json:"to"`
+}
+
+// RouteStats counts messages of the route and reports states of its parts at runtime.
+type RouteStats struct {
+	filtered uint64
+	dropped  uint64
+	breaker  fmt.Stringer
+}
+
+func (s *RouteStats) SetBreaker(breaker fmt.Stringer) {
+	s.breaker = breaker
+}
+
+func (s *RouteStats) AddFiltered() {
+	atomic.AddUint64(&s.filtered, 1)
+}
+
+func (s *RouteStats) Filtered() uint64 {
+	return atomic.LoadUint64(&s.filtered)
+}
+
+func (s *RouteStats) AddDropped() {
+	atomic.AddUint64(&s.dropped, 1)
+}
+
+// Dropped returns the number of messages the route had no room for.
+func (s *RouteStats) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+func (s *RouteStats) MarshalJSON() ([]byte, error) {
+	res := struct {
+		Filtered uint64 `json:"filtered"`
+		Dropped  uint64 `json:"dropped"`
+		Breaker  string `json:"breaker,omitempty"`
+	}{
+		Filtered: s.Filtered(),
+		Dropped:  s.Dropped(),
+	}
+
+	if s.breaker != nil {
+		res.Breaker = s.breaker.String()
+	}
+
+	return json.Marshal(res)
+}
+
 type RouteMode string
 
 const (
@@ -42,9 +139,28 @@ type RouteModeComponents struct {
 	Direction RouteDirection
 }
 
+// Drivers named in route modes, the broker driver is one of the message brokers below.
+const (
+	DriverHTTP   = "http"
+	DriverBroker = "broker"
+	DriverWS     = "ws"
+	DriverSSE    = "sse"
+	DriverMQTT   = "mqtt"
+	DriverAMQP   = "amqp"
+	DriverRedis  = "redis"
+
+	BrokerNATS  = "nats"
+	BrokerKafka = "kafka"
+)
+
 type RouteDirection string
 
 const (
 	RouteDirectionOneway RouteDirection = "oneway"
 	RouteDirectionTwoway RouteDirection = "twoway"
 )
+
+// IsReservedURI reports whether the URI belongs to the internal API, routes can not use it.
+func IsReservedURI(uri string) bool {
+	return reservedURIPattern.MatchString(uri)
+}
@@ -1,6 +1,7 @@
 package entity
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -47,3 +48,35 @@ func TestRouteModeComponents(t *testing.T) {
 		Direction: RouteDirectionTwoway,
 	}, comp)
 }
+
+type testState string
+
+func (s testState) String() string {
+	return string(s)
+}
+
+func TestRouteStatsMarshalJSON(t *testing.T) {
+	stats := &RouteStats{}
+
+	stats.AddFiltered()
+	stats.AddFiltered()
+
+	res, err := json.Marshal(&Route{Mode: "http-broker-oneway", Stats: stats})
+
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"mode":"http-broker-oneway","stats":{"filtered":2,"dropped":0}}`, string(res))
+
+	stats.SetBreaker(testState("open"))
+
+	res, err = json.Marshal(stats)
+
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"filtered":2,"dropped":0,"breaker":"open"}`, string(res))
+}
+
+func TestIsReservedURI(t *testing.T) {
+	assert.True(t, IsReservedURI("/i"))
+	assert.True(t, IsReservedURI("/i/routes"))
+	assert.False(t, IsReservedURI("/items"))
+	assert.False(t, IsReservedURI("/api/i"))
+}
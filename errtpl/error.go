@@ -5,7 +5,11 @@ import (
 )
 
 var (
-	ErrNotFound = errors.New("not found")
+	ErrNotFound           = errors.New("not found")
+	ErrBadRequest         = errors.New("bad request")
+	ErrTooManyRequests    = errors.New("too many requests")
+	ErrServiceUnavailable = errors.New("service unavailable")
+	ErrGatewayTimeout     = errors.New("gateway timeout")
 )
 
 func ErrConnect(err error, service string) error {
@@ -0,0 +1,60 @@
+package errtpl
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// StatusError is an unsuccessful response of an endpoint, it is forwarded to the requester as is.
+type StatusError struct {
+	Code int
+	Body []byte
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected response code %d from endpoint", e.Code)
+}
+
+// Status returns the HTTP status code that describes the error to a requester.
+func Status(err error) int {
+	var serr *StatusError
+
+	switch {
+	case errors.As(err, &serr):
+		return serr.Code
+	case errors.Is(err, ErrBadRequest):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrTooManyRequests):
+		return http.StatusTooManyRequests
+	case errors.Is(err, ErrServiceUnavailable):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, ErrGatewayTimeout), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Reply returns the status code and the body that describe the error to a requester,
+// the body of an endpoint response is forwarded as is.
+func Reply(err error) (int, []byte) {
+	var serr *StatusError
+
+	if errors.As(err, &serr) {
+		return serr.Code, serr.Body
+	}
+
+	code := Status(err)
+
+	return code, []byte(http.StatusText(code))
+}
+
+// IsSuccess reports whether the status code is 2xx.
+func IsSuccess(code int) bool {
+	return code >= http.StatusOK && code < http.StatusMultipleChoices
+}
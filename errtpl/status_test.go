@@ -0,0 +1,78 @@
+package errtpl
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusErrorError(t *testing.T) {
+	assert.EqualError(t, &StatusError{Code: 404}, "unexpected response code 404 from endpoint")
+}
+
+func TestStatus(t *testing.T) {
+	inputs := []struct {
+		err  error
+		code int
+	}{
+		{
+			err:  errors.Wrap(&StatusError{Code: http.StatusBadRequest}, "endpoint"),
+			code: http.StatusBadRequest,
+		},
+		{
+			err:  errors.Wrap(ErrBadRequest, "callback"),
+			code: http.StatusBadRequest,
+		},
+		{
+			err:  errors.Wrap(ErrNotFound, "route"),
+			code: http.StatusNotFound,
+		},
+		{
+			err:  ErrTooManyRequests,
+			code: http.StatusTooManyRequests,
+		},
+		{
+			err:  errors.Wrap(ErrServiceUnavailable, "no responders"),
+			code: http.StatusServiceUnavailable,
+		},
+		{
+			err:  ErrGatewayTimeout,
+			code: http.StatusGatewayTimeout,
+		},
+		{
+			err:  &url.Error{Op: "Post", URL: "http://localhost", Err: context.DeadlineExceeded},
+			code: http.StatusGatewayTimeout,
+		},
+		{
+			err:  errors.New("error"),
+			code: http.StatusInternalServerError,
+		},
+	}
+
+	for i, input := range inputs {
+		assert.Equalf(t, input.code, Status(input.err), "case %d", i+1)
+	}
+}
+
+func TestIsSuccess(t *testing.T) {
+	assert.True(t, IsSuccess(http.StatusOK))
+	assert.True(t, IsSuccess(http.StatusAccepted))
+	assert.False(t, IsSuccess(http.StatusMultipleChoices))
+	assert.False(t, IsSuccess(http.StatusBadRequest))
+}
+
+func TestReply(t *testing.T) {
+	code, body := Reply(errors.Wrap(&StatusError{Code: http.StatusBadRequest, Body: []byte("bad email")}, "endpoint"))
+
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, []byte("bad email"), body)
+
+	code, body = Reply(errors.Wrap(ErrServiceUnavailable, "no responders"))
+
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, []byte("Service Unavailable"), body)
+}
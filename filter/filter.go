@@ -0,0 +1,106 @@
+package filter
+
+import (
+	"NATter/driver"
+	"NATter/entity"
+	"NATter/log"
+	"NATter/msgtpl"
+
+	"github.com/itchyny/gojq"
+	"github.com/pkg/errors"
+)
+
+type Config struct {
+	Route      string
+	Expression string
+	Stats      *entity.RouteStats
+}
+
+// filter passes to the sender only messages the expression is truthy for,
+// other ones are acknowledged without sending.
+type filter struct {
+	sender driver.Sender
+	code   *gojq.Code
+	route  string
+	stats  *entity.RouteStats
+}
+
+func New(cfg *Config, sender driver.Sender) (driver.Sender, error) {
+	query, err := gojq.Parse(cfg.Expression)
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid filter expression %s", cfg.Expression)
+	}
+
+	code, err := gojq.Compile(query)
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid filter expression %s", cfg.Expression)
+	}
+
+	stats := cfg.Stats
+
+	if stats == nil {
+		stats = &entity.RouteStats{}
+	}
+
+	return &filter{
+		sender: sender,
+		code:   code,
+		route:  cfg.Route,
+		stats:  stats,
+	}, nil
+}
+
+func Validate(expr string) error {
+	_, err := New(&Config{Expression: expr}, nil)
+
+	return err
+}
+
+func (f *filter) match(msg *entity.Message) (bool, error) {
+	res, ok := f.code.Run(msgtpl.Data(msg)).Next()
+
+	if err, isErr := res.(error); ok && isErr {
+		return false, errors.Wrap(err, "unable evaluate filter")
+	}
+
+	// No result, null and false do not match as jq conditions do.
+	if !ok || res == nil || res == false {
+		f.stats.AddFiltered()
+
+		log.WithFields(log.Fields{
+			log.FieldRoute: f.route,
+			log.FieldTopic: msg.Subject,
+		}).Debug("message filtered")
+
+		return false, nil
+	}
+
+	return true, nil
+}
+
+func (f *filter) Send(msg *entity.Message) error {
+	ok, err := f.match(msg)
+
+	if err != nil || !ok {
+		return err
+	}
+
+	return f.sender.Send(msg)
+}
+
+// Request responds with an empty message to filtered requests.
+func (f *filter) Request(msg *entity.Message) (*entity.Message, error) {
+	ok, err := f.match(msg)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return entity.NewMessage(nil), nil
+	}
+
+	return f.sender.Request(msg)
+}
@@ -0,0 +1,121 @@
+package filter
+
+import (
+	"testing"
+
+	"NATter/entity"
+	m "NATter/mock"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewOnInvalidExpression(t *testing.T) {
+	f, err := New(&Config{Expression: ".json.id =="}, &m.DriverSender{})
+
+	assert.Error(t, err)
+	assert.Nil(t, f)
+}
+
+func TestFilterSend(t *testing.T) {
+	sender := &m.DriverSender{}
+	stats := &entity.RouteStats{}
+
+	matched := entity.NewMessage([]byte(`{"id":1,"changes":{"email":"john@example.com"}}`))
+
+	sender.
+		On("Send", matched).
+		Return(nil).Once()
+
+	f, err := New(&Config{
+		Expression: `.json.changes | has("email")`,
+		Stats:      stats,
+	}, sender)
+
+	assert.Nil(t, err)
+
+	assert.Nil(t, f.Send(matched))
+	assert.Nil(t, f.Send(entity.NewMessage([]byte(`{"id":1,"changes":{"name":"John"}}`))))
+
+	assert.Equal(t, uint64(1), stats.Filtered())
+	sender.AssertExpectations(t)
+}
+
+func TestFilterSendOnSubjectAndHeader(t *testing.T) {
+	sender := &m.DriverSender{}
+	stats := &entity.RouteStats{}
+
+	matched := &entity.Message{
+		Payload: []byte("some-data"),
+		Subject: "user.42.update",
+		Header:  map[string]string{"X-Event": "email"},
+	}
+
+	sender.
+		On("Send", matched).
+		Return(nil).Once()
+
+	f, err := New(&Config{
+		Expression: `(.subject | startswith("user.")) and .header["X-Event"] == "email" and .json == null`,
+		Stats:      stats,
+	}, sender)
+
+	assert.Nil(t, err)
+
+	assert.Nil(t, f.Send(matched))
+	assert.Nil(t, f.Send(&entity.Message{Payload: []byte("some-data"), Subject: "user.42.update"}))
+	assert.Nil(t, f.Send(&entity.Message{Payload: []byte("some-data"), Subject: "order.1.update"}))
+
+	assert.Equal(t, uint64(2), stats.Filtered())
+	sender.AssertExpectations(t)
+}
+
+func TestFilterSendOnEvaluationError(t *testing.T) {
+	f, err := New(&Config{Expression: `.json.id | error`}, &m.DriverSender{})
+
+	assert.Nil(t, err)
+
+	assert.Error(t, f.Send(entity.NewMessage([]byte(`{"id":1}`))))
+}
+
+func TestFilterRequest(t *testing.T) {
+	sender := &m.DriverSender{}
+
+	sender.
+		On("Request", &entity.Message{Payload: []byte("request-data"), Params: map[string]string{"id": "42"}}).
+		Return(entity.NewMessage([]byte("response-data")), nil).Once()
+
+	f, err := New(&Config{Expression: `.uri.id == "42"`}, sender)
+
+	assert.Nil(t, err)
+
+	resp, err := f.Request(&entity.Message{Payload: []byte("request-data"), Params: map[string]string{"id": "42"}})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "response-data", string(resp.Payload))
+
+	resp, err = f.Request(&entity.Message{Payload: []byte("request-data"), Params: map[string]string{"id": "1"}})
+
+	assert.Nil(t, err)
+	assert.Empty(t, resp.Payload)
+	sender.AssertExpectations(t)
+}
+
+func TestFilterSendOnLargeInteger(t *testing.T) {
+	sender := &m.DriverSender{}
+
+	matched := entity.NewMessage([]byte(`{"id":9007199254740993}`))
+
+	sender.
+		On("Send", matched).
+		Return(nil).Once()
+
+	f, err := New(&Config{Expression: `.json.id == 9007199254740993`}, sender)
+
+	assert.Nil(t, err)
+
+	// The ID is not rounded to the nearest float, so the next one does not match.
+	assert.Nil(t, f.Send(matched))
+	assert.Nil(t, f.Send(entity.NewMessage([]byte(`{"id":9007199254740992}`))))
+
+	sender.AssertExpectations(t)
+}
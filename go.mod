@@ -4,15 +4,27 @@ go 1.16
 
 require (
 	github.com/Shopify/sarama v1.29.1
+	github.com/eclipse/paho.mqtt.golang v1.4.2
 	github.com/go-chi/chi v1.5.4
+	github.com/go-redis/redis/v8 v8.11.5
 	github.com/golang/protobuf v1.5.2
+	github.com/golang/snappy v0.0.3
+	github.com/gorilla/websocket v1.4.2
+	github.com/itchyny/gojq v0.12.4
+	github.com/klauspost/compress v1.12.2
 	github.com/mitchellh/mapstructure v1.4.1
 	github.com/nats-io/nats-server/v2 v2.3.4
 	github.com/nats-io/nats.go v1.12.0
+	github.com/pelletier/go-toml v1.9.3
 	github.com/pkg/errors v0.9.1
+	github.com/rabbitmq/amqp091-go v1.5.0
 	github.com/sirupsen/logrus v1.8.1
+	github.com/spf13/cast v1.3.1
 	github.com/spf13/pflag v1.0.5
 	github.com/spf13/viper v1.8.1
 	github.com/stretchr/testify v1.7.0
+	golang.org/x/sync v0.0.0-20220907140024-f12130a52804 // indirect
+	golang.org/x/sys v0.10.0 // indirect
+	golang.org/x/time v0.0.0-20200416051211-89c76fbcd5d1
 	google.golang.org/protobuf v1.27.1
 )
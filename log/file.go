@@ -2,6 +2,7 @@ package log
 
 import (
 	"os"
+	"sync"
 
 	"NATter/log/hook"
 
@@ -9,12 +10,17 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-func setupFile(path string) error {
-	if len(path) == 0 {
+var (
+	fileHook *hook.File
+	fileMx   = &sync.Mutex{}
+)
+
+func setupFile(cfg *ConfigFile) error {
+	if len(cfg.Path) == 0 {
 		return errors.New("logger path couldn't be empty")
 	}
 
-	h, err := os.OpenFile(path, os.O_WRONLY, 0664)
+	h, err := os.OpenFile(cfg.Path, os.O_WRONLY, 0664)
 
 	if err != nil && !os.IsNotExist(err) {
 		return err
@@ -22,7 +28,45 @@ func setupFile(path string) error {
 
 	defer h.Close()
 
-	logrus.AddHook(hook.NewFile(path))
+	file := hook.NewFile(&hook.FileConfig{
+		Path:           cfg.Path,
+		MaxSize:        cfg.MaxSize,
+		RotateInterval: cfg.RotateInterval,
+		MaxFiles:       cfg.MaxFiles,
+		Compress:       cfg.Compress,
+	})
+
+	fileMx.Lock()
+	fileHook = file
+	fileMx.Unlock()
+
+	notifyReopen()
+
+	logrus.AddHook(file)
 
 	return nil
 }
+
+// reopenFile reopens the log file if the file output is enabled.
+func reopenFile() error {
+	fileMx.Lock()
+	defer fileMx.Unlock()
+
+	if fileHook == nil {
+		return nil
+	}
+
+	return fileHook.Reopen()
+}
+
+// Close closes the log file if the file output is enabled, rotated files are compressed before it returns.
+func Close() error {
+	fileMx.Lock()
+	defer fileMx.Unlock()
+
+	if fileHook == nil {
+		return nil
+	}
+
+	return fileHook.Close()
+}
@@ -0,0 +1,48 @@
+package log
+
+import (
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	FormatText   = "text"
+	FormatJSON   = "json"
+	FormatLogfmt = "logfmt"
+)
+
+// Field names shared by all structured log entries.
+const (
+	FieldRoute     = "route"
+	FieldTopic     = "topic"
+	FieldURI       = "uri"
+	FieldEndpoint  = "endpoint"
+	FieldDuration  = "duration"
+	FieldError     = "error"
+	FieldMethod    = "method"
+	FieldStatus    = "status"
+	FieldBody      = "body"
+	FieldPayload   = "payload"
+	FieldRequest   = "request"
+	FieldRequestID = "request_id"
+	FieldResponse  = "response"
+)
+
+func setupFormatter(format string) error {
+	switch format {
+	case "", FormatText:
+		logrus.SetFormatter(&logrus.TextFormatter{})
+	case FormatLogfmt:
+		logrus.SetFormatter(&logrus.TextFormatter{
+			DisableColors:    true,
+			FullTimestamp:    true,
+			QuoteEmptyFields: true,
+		})
+	case FormatJSON:
+		logrus.SetFormatter(&logrus.JSONFormatter{})
+	default:
+		return errors.Errorf("unknown log format: %s", format)
+	}
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package log
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetupFormatter(t *testing.T) {
+	inputs := []struct {
+		format    string
+		formatter logrus.Formatter
+	}{
+		{
+			format:    "",
+			formatter: &logrus.TextFormatter{},
+		},
+		{
+			format:    "text",
+			formatter: &logrus.TextFormatter{},
+		},
+		{
+			format: "logfmt",
+			formatter: &logrus.TextFormatter{
+				DisableColors:    true,
+				FullTimestamp:    true,
+				QuoteEmptyFields: true,
+			},
+		},
+		{
+			format:    "json",
+			formatter: &logrus.JSONFormatter{},
+		},
+	}
+
+	for i, input := range inputs {
+		err := setupFormatter(input.format)
+
+		assert.Nilf(t, err, "case %d", i+1)
+		assert.Equalf(t, input.formatter, logrus.StandardLogger().Formatter, "case %d", i+1)
+	}
+
+	logrus.SetFormatter(&logrus.TextFormatter{})
+}
+
+func TestSetupFormatterOnUnknownFormat(t *testing.T) {
+	err := setupFormatter("xml")
+
+	assert.Error(t, err)
+}
@@ -1,19 +1,48 @@
 package hook
 
 import (
+	"compress/gzip"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
+const (
+	rotatedTimeFormat = "20060102-150405.000"
+	compressedExt     = ".gz"
+)
+
+type FileConfig struct {
+	Path           string
+	MaxSize        int64         // bytes, 0 means no size rotation
+	RotateInterval time.Duration // 0 means no time rotation
+	MaxFiles       int           // rotated files to retain, 0 means all
+	Compress       bool
+}
+
 type File struct {
-	path string
+	cfg FileConfig
+
+	h        *os.File
+	size     int64
+	openedAt time.Time
+
+	wg *sync.WaitGroup
 	sync.Mutex
 }
 
-func NewFile(path string) *File {
-	return &File{path: path}
+func NewFile(cfg *FileConfig) *File {
+	return &File{
+		cfg: *cfg,
+		wg:  &sync.WaitGroup{},
+	}
 }
 
 func (f *File) Fire(entry *logrus.Entry) error {
@@ -26,15 +55,21 @@ func (f *File) Fire(entry *logrus.Entry) error {
 	f.Lock()
 	defer f.Unlock()
 
-	h, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0664)
+	if f.h != nil && f.shouldRotate(int64(len(line))) {
+		if err := f.rotate(); err != nil {
+			return err
+		}
+	}
 
-	if err != nil {
-		return err
+	if f.h == nil {
+		if err := f.open(); err != nil {
+			return err
+		}
 	}
 
-	defer h.Close()
+	n, err := f.h.Write(line)
 
-	_, err = h.Write(line)
+	f.size += int64(n)
 
 	return err
 }
@@ -42,3 +77,151 @@ func (f *File) Fire(entry *logrus.Entry) error {
 func (f *File) Levels() []logrus.Level {
 	return logrus.AllLevels
 }
+
+// Reopen closes the current file so that the next entry is written to a newly opened one.
+// It is used when the file is moved by external tools like logrotate.
+func (f *File) Reopen() error {
+	f.Lock()
+	defer f.Unlock()
+
+	return f.close()
+}
+
+// Close closes the file and waits until rotated files are compressed.
+func (f *File) Close() error {
+	f.Lock()
+	err := f.close()
+	f.Unlock()
+
+	f.wg.Wait()
+
+	return err
+}
+
+func (f *File) open() error {
+	h, err := os.OpenFile(f.cfg.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0664)
+
+	if err != nil {
+		return err
+	}
+
+	info, err := h.Stat()
+
+	if err != nil {
+		h.Close()
+
+		return err
+	}
+
+	f.h = h
+	f.size = info.Size()
+	// The rotation interval of a file that is opened again after a restart counts from its last write.
+	f.openedAt = info.ModTime()
+
+	return nil
+}
+
+func (f *File) close() error {
+	if f.h == nil {
+		return nil
+	}
+
+	err := f.h.Close()
+
+	f.h = nil
+	f.size = 0
+
+	return err
+}
+
+func (f *File) shouldRotate(size int64) bool {
+	if f.cfg.MaxSize > 0 && f.size > 0 && f.size+size > f.cfg.MaxSize {
+		return true
+	}
+
+	return f.cfg.RotateInterval > 0 && time.Since(f.openedAt) >= f.cfg.RotateInterval
+}
+
+func (f *File) rotate() error {
+	if err := f.close(); err != nil {
+		return err
+	}
+
+	rotated := f.cfg.Path + "." + time.Now().Format(rotatedTimeFormat)
+
+	if err := os.Rename(f.cfg.Path, rotated); err != nil {
+		return err
+	}
+
+	f.wg.Add(1)
+
+	go func() {
+		defer f.wg.Done()
+
+		if f.cfg.Compress {
+			// The error can not be logged to the file being rotated.
+			if err := compress(rotated); err != nil {
+				fmt.Fprintf(os.Stderr, "unable compress rotated log file %s: %s\n", rotated, err)
+
+				return
+			}
+		}
+
+		f.removeExpired()
+	}()
+
+	return nil
+}
+
+func (f *File) removeExpired() {
+	if f.cfg.MaxFiles <= 0 {
+		return
+	}
+
+	rotated, err := filepath.Glob(f.cfg.Path + ".*")
+
+	if err != nil {
+		return
+	}
+
+	// Rotated file names end with a sortable time so the oldest ones go first.
+	sort.Slice(rotated, func(i, j int) bool {
+		return strings.TrimSuffix(rotated[i], compressedExt) < strings.TrimSuffix(rotated[j], compressedExt)
+	})
+
+	for len(rotated) > f.cfg.MaxFiles {
+		os.Remove(rotated[0])
+
+		rotated = rotated[1:]
+	}
+}
+
+func compress(path string) error {
+	src, err := os.Open(path)
+
+	if err != nil {
+		return err
+	}
+
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+compressedExt, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0664)
+
+	if err != nil {
+		return err
+	}
+
+	defer dst.Close()
+
+	w := gzip.NewWriter(dst)
+
+	if _, err := io.Copy(w, src); err != nil {
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return os.Remove(path)
+}
@@ -3,8 +3,10 @@ package hook
 import (
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
@@ -16,7 +18,9 @@ func TestFire(t *testing.T) {
 
 	defer os.RemoveAll(dir)
 
-	hook := NewFile(dir + string(os.PathSeparator) + "test.log")
+	hook := NewFile(&FileConfig{Path: dir + string(os.PathSeparator) + "test.log"})
+
+	defer hook.Close()
 
 	logger := logrus.New()
 	logger.SetLevel(logrus.InfoLevel)
@@ -30,7 +34,9 @@ func TestFire(t *testing.T) {
 }
 
 func TestFireOnError(t *testing.T) {
-	hook := NewFile(string(os.PathSeparator) + "thisdirrectorydoesntexist" + string(os.PathSeparator))
+	hook := NewFile(&FileConfig{
+		Path: string(os.PathSeparator) + "thisdirrectorydoesntexist" + string(os.PathSeparator),
+	})
 
 	logger := logrus.New()
 	logger.SetLevel(logrus.InfoLevel)
@@ -38,3 +44,139 @@ func TestFireOnError(t *testing.T) {
 
 	logger.Info("hello")
 }
+
+func TestFireOnMaxSize(t *testing.T) {
+	dir, err := ioutil.TempDir(".", "")
+	assert.Nil(t, err)
+
+	defer os.RemoveAll(dir)
+
+	path := dir + string(os.PathSeparator) + "test.log"
+
+	hook := NewFile(&FileConfig{
+		Path:     path,
+		MaxSize:  10,
+		MaxFiles: 2,
+	})
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.InfoLevel)
+	logger.AddHook(hook)
+
+	for i := 0; i < 4; i++ {
+		logger.Info("hello")
+		time.Sleep(time.Millisecond * 2)
+	}
+
+	assert.Nil(t, hook.Close())
+
+	rotated, err := filepath.Glob(path + ".*")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(rotated))
+
+	data, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, strings.Count(string(data), "hello"))
+}
+
+func TestFireOnRotateInterval(t *testing.T) {
+	dir, err := ioutil.TempDir(".", "")
+	assert.Nil(t, err)
+
+	defer os.RemoveAll(dir)
+
+	path := dir + string(os.PathSeparator) + "test.log"
+
+	hook := NewFile(&FileConfig{
+		Path:           path,
+		RotateInterval: time.Millisecond,
+		Compress:       true,
+	})
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.InfoLevel)
+	logger.AddHook(hook)
+
+	logger.Info("hello")
+	time.Sleep(time.Millisecond * 2)
+	logger.Info("world")
+
+	assert.Nil(t, hook.Close())
+
+	rotated, err := filepath.Glob(path + ".*")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(rotated))
+	assert.True(t, strings.HasSuffix(rotated[0], ".gz"))
+
+	data, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	assert.True(t, strings.Contains(string(data), "world"))
+	assert.False(t, strings.Contains(string(data), "hello"))
+}
+
+func TestFireOnRotateIntervalOfExistingFile(t *testing.T) {
+	dir, err := ioutil.TempDir(".", "")
+	assert.Nil(t, err)
+
+	defer os.RemoveAll(dir)
+
+	path := dir + string(os.PathSeparator) + "test.log"
+
+	assert.Nil(t, ioutil.WriteFile(path, []byte("hello\n"), 0664))
+
+	// The file was written before a restart.
+	writtenAt := time.Now().Add(-time.Hour * 2)
+	assert.Nil(t, os.Chtimes(path, writtenAt, writtenAt))
+
+	hook := NewFile(&FileConfig{
+		Path:           path,
+		RotateInterval: time.Hour,
+	})
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.InfoLevel)
+	logger.AddHook(hook)
+
+	logger.Info("world")
+	logger.Info("again")
+
+	assert.Nil(t, hook.Close())
+
+	rotated, err := filepath.Glob(path + ".*")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(rotated))
+
+	data, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	assert.True(t, strings.Contains(string(data), "again"))
+	assert.False(t, strings.Contains(string(data), "hello"))
+}
+
+func TestReopen(t *testing.T) {
+	dir, err := ioutil.TempDir(".", "")
+	assert.Nil(t, err)
+
+	defer os.RemoveAll(dir)
+
+	path := dir + string(os.PathSeparator) + "test.log"
+
+	hook := NewFile(&FileConfig{Path: path})
+
+	defer hook.Close()
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.InfoLevel)
+	logger.AddHook(hook)
+
+	logger.Info("hello")
+
+	assert.Nil(t, os.Rename(path, path+".1"))
+	assert.Nil(t, hook.Reopen())
+
+	logger.Info("world")
+
+	data, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	assert.True(t, strings.Contains(string(data), "world"))
+	assert.False(t, strings.Contains(string(data), "hello"))
+}
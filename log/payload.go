@@ -0,0 +1,152 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	redactedValue  = "[REDACTED]"
+	redactPathSep  = "."
+	redactWildcard = "*"
+	formPairSep    = "&"
+	formValueSep   = "="
+)
+
+type ConfigPayload struct {
+	Enable    bool
+	MaxLength int      // 0 means no truncation
+	Redact    []string // dot separated JSON paths, '*' matches any key or index
+}
+
+var payloadCfg = &ConfigPayload{}
+
+func setupPayload(cfg *ConfigPayload) {
+	payloadCfg = cfg
+}
+
+// PayloadEnabled reports whether payloads should be logged, the route option overrides the global one.
+func PayloadEnabled(route *bool) bool {
+	if route != nil {
+		return *route
+	}
+
+	return payloadCfg.Enable
+}
+
+// FormatPayload redacts sensitive fields of a JSON or form payload and truncates it to the max length.
+func FormatPayload(payload []byte) string {
+	res := string(payload)
+
+	if len(payloadCfg.Redact) != 0 {
+		res = redact(payload)
+	}
+
+	if payloadCfg.MaxLength > 0 && len(res) > payloadCfg.MaxLength {
+		n := payloadCfg.MaxLength
+
+		// Multibyte characters are not cut in the middle.
+		for n > 0 && !utf8.RuneStart(res[n]) {
+			n--
+		}
+
+		res = fmt.Sprintf("%s...(%d bytes truncated)", res[:n], len(res)-n)
+	}
+
+	return res
+}
+
+func redact(payload []byte) string {
+	var v interface{}
+
+	if err := json.Unmarshal(payload, &v); err != nil {
+		return redactForm(string(payload))
+	}
+
+	for _, path := range payloadCfg.Redact {
+		v = redactPath(v, strings.Split(path, redactPathSep))
+	}
+
+	b, err := json.Marshal(v)
+
+	if err != nil {
+		return string(payload)
+	}
+
+	return string(b)
+}
+
+func redactPath(v interface{}, path []string) interface{} {
+	if len(path) == 0 {
+		return redactedValue
+	}
+
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, field := range val {
+			if path[0] == redactWildcard || path[0] == k {
+				val[k] = redactPath(field, path[1:])
+			}
+		}
+	case []interface{}:
+		// Arrays are transparent unless the wildcard stands for their index.
+		next := path
+
+		if path[0] == redactWildcard {
+			next = path[1:]
+		}
+
+		for i, item := range val {
+			val[i] = redactPath(item, next)
+		}
+	}
+
+	return v
+}
+
+// redactForm redacts values of a form encoded payload, a path matches the whole field name.
+// Pairs that are not fields are kept as is, so any other text is returned unchanged.
+func redactForm(payload string) string {
+	pairs := strings.Split(payload, formPairSep)
+
+	for i, pair := range pairs {
+		field := strings.SplitN(pair, formValueSep, 2)
+
+		if len(field) != 2 {
+			continue
+		}
+
+		name, err := url.QueryUnescape(field[0])
+
+		if err != nil {
+			continue
+		}
+
+		for _, path := range payloadCfg.Redact {
+			if matchPath(strings.Split(name, redactPathSep), strings.Split(path, redactPathSep)) {
+				pairs[i] = field[0] + formValueSep + redactedValue
+
+				break
+			}
+		}
+	}
+
+	return strings.Join(pairs, formPairSep)
+}
+
+func matchPath(name, path []string) bool {
+	if len(name) != len(path) {
+		return false
+	}
+
+	for i := range path {
+		if path[i] != redactWildcard && path[i] != name[i] {
+			return false
+		}
+	}
+
+	return true
+}
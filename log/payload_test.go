@@ -0,0 +1,99 @@
+package log
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPayloadEnabled(t *testing.T) {
+	enable, disable := true, false
+
+	setupPayload(&ConfigPayload{Enable: true})
+
+	assert.True(t, PayloadEnabled(nil))
+	assert.True(t, PayloadEnabled(&enable))
+	assert.False(t, PayloadEnabled(&disable))
+
+	setupPayload(&ConfigPayload{})
+
+	assert.False(t, PayloadEnabled(nil))
+	assert.True(t, PayloadEnabled(&enable))
+}
+
+func TestFormatPayload(t *testing.T) {
+	inputs := []struct {
+		cfg     *ConfigPayload
+		payload string
+		res     string
+	}{
+		{
+			cfg:     &ConfigPayload{},
+			payload: `{"password":"secret"}`,
+			res:     `{"password":"secret"}`,
+		},
+		{
+			cfg:     &ConfigPayload{MaxLength: 5},
+			payload: `some-data`,
+			res:     `some-...(4 bytes truncated)`,
+		},
+		{
+			cfg:     &ConfigPayload{Redact: []string{"password"}},
+			payload: `{"name":"John","password":"secret"}`,
+			res:     `{"name":"John","password":"[REDACTED]"}`,
+		},
+		{
+			cfg:     &ConfigPayload{Redact: []string{"user.token", "*.password"}},
+			payload: `{"user":{"token":"t","password":"p","name":"John"},"token":"t"}`,
+			res:     `{"token":"t","user":{"name":"John","password":"[REDACTED]","token":"[REDACTED]"}}`,
+		},
+		{
+			cfg:     &ConfigPayload{Redact: []string{"users.password"}},
+			payload: `{"users":[{"password":"p1"},{"password":"p2"}]}`,
+			res:     `{"users":[{"password":"[REDACTED]"},{"password":"[REDACTED]"}]}`,
+		},
+		{
+			cfg:     &ConfigPayload{Redact: []string{"*.*.password"}},
+			payload: `{"users":[{"password":"p1"}]}`,
+			res:     `{"users":[{"password":"[REDACTED]"}]}`,
+		},
+		{
+			cfg:     &ConfigPayload{Redact: []string{"password"}},
+			payload: `not-json password`,
+			res:     `not-json password`,
+		},
+		{
+			cfg:     &ConfigPayload{Redact: []string{"password", "user.token"}},
+			payload: `name=John&password=secret&user.token=t&token=t`,
+			res:     `name=John&password=[REDACTED]&user.token=[REDACTED]&token=t`,
+		},
+		{
+			cfg:     &ConfigPayload{Redact: []string{"*"}},
+			payload: `pass%77ord=secret&flag`,
+			res:     `pass%77ord=[REDACTED]&flag`,
+		},
+		{
+			cfg:     &ConfigPayload{MaxLength: 4},
+			payload: `añb`,
+			res:     `añb`,
+		},
+		{
+			cfg:     &ConfigPayload{MaxLength: 2},
+			payload: `añb`,
+			res:     `a...(3 bytes truncated)`,
+		},
+		{
+			cfg:     &ConfigPayload{Redact: []string{"password"}, MaxLength: 12},
+			payload: `{"password":"secret"}`,
+			res:     `{"password":...(13 bytes truncated)`,
+		},
+	}
+
+	for i, input := range inputs {
+		setupPayload(input.cfg)
+
+		assert.Equalf(t, input.res, FormatPayload([]byte(input.payload)), "case %d", i+1)
+	}
+
+	setupPayload(&ConfigPayload{})
+}
@@ -0,0 +1,28 @@
+// +build !windows
+
+package log
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+var reopenOnce = &sync.Once{}
+
+// notifyReopen reopens the log file on SIGUSR1, the signal is handled once however often the config is set.
+func notifyReopen() {
+	reopenOnce.Do(func() {
+		sigchan := make(chan os.Signal, 1)
+		signal.Notify(sigchan, syscall.SIGUSR1)
+
+		go func() {
+			for range sigchan {
+				if err := reopenFile(); err != nil {
+					Error(err)
+				}
+			}
+		}()
+	})
+}
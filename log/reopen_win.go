@@ -0,0 +1,6 @@
+// +build windows
+
+package log
+
+func notifyReopen() {
+}
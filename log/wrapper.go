@@ -3,6 +3,7 @@ package log
 import (
 	"fmt"
 	"io/ioutil"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
@@ -14,8 +15,12 @@ type Entry struct {
 type Fields map[string]interface{}
 
 type ConfigFile struct {
-	Enable bool
-	Path   string
+	Enable         bool
+	Path           string
+	MaxSize        int64 // bytes
+	RotateInterval time.Duration
+	MaxFiles       int
+	Compress       bool
 }
 
 type ConfigStream struct {
@@ -29,10 +34,12 @@ type ConfigSyslog struct {
 }
 
 type Config struct {
-	Level  string
-	Syslog ConfigSyslog
-	Stream ConfigStream
-	File   ConfigFile
+	Level   string
+	Format  string
+	Syslog  ConfigSyslog
+	Stream  ConfigStream
+	File    ConfigFile
+	Payload ConfigPayload
 }
 
 func SetConfig(cfg *Config) error {
@@ -45,6 +52,12 @@ func SetConfig(cfg *Config) error {
 	logrus.SetLevel(lvl)
 	logrus.SetOutput(ioutil.Discard)
 
+	if err = setupFormatter(cfg.Format); err != nil {
+		return err
+	}
+
+	setupPayload(&cfg.Payload)
+
 	if cfg.Syslog.Enable {
 		if err = setupSyslog(cfg.Syslog.Host, cfg.Syslog.Name); err != nil {
 			return err
@@ -52,7 +65,7 @@ func SetConfig(cfg *Config) error {
 	}
 
 	if cfg.File.Enable {
-		if err = setupFile(cfg.File.Path); err != nil {
+		if err = setupFile(&cfg.File); err != nil {
 			return err
 		}
 	}
@@ -78,6 +91,12 @@ func (e *Entry) WithFields(fields Fields) *Entry {
 	return e
 }
 
+func (e *Entry) WithError(err error) *Entry {
+	e.Entry = e.Entry.WithError(err)
+
+	return e
+}
+
 func Debug(args ...interface{}) {
 	logrus.Debug(args...)
 }
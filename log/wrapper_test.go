@@ -7,6 +7,7 @@ import (
 
 	"NATter/log/hook"
 
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/sirupsen/logrus/hooks/test"
 	"github.com/sirupsen/logrus/hooks/writer"
@@ -60,6 +61,40 @@ func TestSetConfigOnEnableFile(t *testing.T) {
 	logrus.SetOutput(os.Stderr)
 }
 
+func TestClose(t *testing.T) {
+	dir, err := ioutil.TempDir(".", "")
+	assert.Nil(t, err)
+
+	defer os.RemoveAll(dir)
+
+	path := dir + string(os.PathSeparator) + "test.log"
+
+	for i := 0; i < 2; i++ {
+		err = SetConfig(&Config{
+			Level: "info",
+			File: ConfigFile{
+				Enable: true,
+				Path:   path,
+			},
+		})
+
+		assert.Nil(t, err)
+	}
+
+	Info("hello")
+
+	assert.Nil(t, Close())
+
+	data, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), "hello")
+
+	std := logrus.StandardLogger()
+	std.Hooks = make(map[logrus.Level][]logrus.Hook)
+
+	logrus.SetOutput(os.Stderr)
+}
+
 func TestSetConfigOnEnableFileOnNoPath(t *testing.T) {
 	err := SetConfig(&Config{
 		Level: "info",
@@ -93,6 +128,30 @@ func TestSetConfigOnEnableFileOnNotWritable(t *testing.T) {
 	logrus.SetOutput(os.Stderr)
 }
 
+func TestSetConfigOnJSONFormat(t *testing.T) {
+	err := SetConfig(&Config{
+		Level:  "info",
+		Format: "json",
+	})
+
+	assert.Nil(t, err)
+	assert.IsType(t, &logrus.JSONFormatter{}, logrus.StandardLogger().Formatter)
+
+	logrus.SetFormatter(&logrus.TextFormatter{})
+	logrus.SetOutput(os.Stderr)
+}
+
+func TestSetConfigOnIncorrectFormat(t *testing.T) {
+	err := SetConfig(&Config{
+		Level:  "info",
+		Format: "unknown_format",
+	})
+
+	assert.Error(t, err)
+
+	logrus.SetOutput(os.Stderr)
+}
+
 func TestSetConfigOnIncorrectLevel(t *testing.T) {
 	err := SetConfig(&Config{
 		Level: "unknown_level",
@@ -119,6 +178,19 @@ func TestEntryWithFields(t *testing.T) {
 	}, ent.Data)
 }
 
+func TestEntryWithError(t *testing.T) {
+	err := errors.New("error")
+
+	ent := WithFields(Fields{
+		"test": true,
+	}).WithError(err)
+
+	assert.Equal(t, logrus.Fields{
+		"test":  true,
+		"error": err,
+	}, ent.Data)
+}
+
 func TestEntryTrace(t *testing.T) {
 	hook := test.NewGlobal()
 
@@ -3,6 +3,8 @@ package mock
 import (
 	"context"
 
+	"NATter/entity"
+
 	"github.com/stretchr/testify/mock"
 )
 
@@ -14,16 +16,16 @@ func (b *Batcher) Run(ctx context.Context) {
 	b.Called(ctx)
 }
 
-func (b *Batcher) Send(msg []byte) error {
+func (b *Batcher) Send(msg *entity.Message) error {
 	args := b.Called(msg)
 
 	return args.Error(0)
 }
 
-func (b *Batcher) Request(msg []byte) ([]byte, error) {
+func (b *Batcher) Request(msg *entity.Message) (*entity.Message, error) {
 	args := b.Called(msg)
 
-	return args.Get(0).([]byte), args.Error(1)
+	return args.Get(0).(*entity.Message), args.Error(1)
 }
 
 type BatcherEncoder struct {
@@ -35,3 +37,9 @@ func (e *BatcherEncoder) Marshal(msgs [][]byte) ([]byte, error) {
 
 	return args.Get(0).([]byte), args.Error(1)
 }
+
+func (e *BatcherEncoder) Split(batch []byte, msgs [][]byte) ([][]byte, error) {
+	args := e.Called(batch, msgs)
+
+	return args.Get(0).([][]byte), args.Error(1)
+}
@@ -6,7 +6,11 @@ import (
 	"NATter/driver"
 	"NATter/entity"
 
+	"github.com/Shopify/sarama"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/go-redis/redis/v8"
 	nats "github.com/nats-io/nats.go"
+	amqp "github.com/rabbitmq/amqp091-go"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -58,36 +62,36 @@ type DriverSender struct {
 	mock.Mock
 }
 
-func (s *DriverSender) Send(payload []byte) error {
-	args := s.Called(payload)
+func (s *DriverSender) Send(msg *entity.Message) error {
+	args := s.Called(msg)
 
 	return args.Error(0)
 }
 
-func (s *DriverSender) Request(payload []byte) ([]byte, error) {
-	args := s.Called(payload)
+func (s *DriverSender) Request(msg *entity.Message) (*entity.Message, error) {
+	args := s.Called(msg)
 
-	return args.Get(0).([]byte), args.Error(1)
+	return args.Get(0).(*entity.Message), args.Error(1)
 }
 
 type DriverNatsConn struct {
 	mock.Mock
 }
 
-func (c *DriverNatsConn) Subscribe(topic string, handler func(*nats.Msg) error) error {
-	args := c.Called(topic, handler)
+func (c *DriverNatsConn) Subscribe(topic string, workers int, handler func(*nats.Msg) error, drop func(*nats.Msg)) error {
+	args := c.Called(topic, workers, handler, drop)
 
 	return args.Error(0)
 }
 
-func (c *DriverNatsConn) Publish(topic string, payload []byte) error {
-	args := c.Called(topic, payload)
+func (c *DriverNatsConn) Publish(msg *nats.Msg) error {
+	args := c.Called(msg)
 
 	return args.Error(0)
 }
 
-func (c *DriverNatsConn) Request(topic string, payload []byte) (*nats.Msg, error) {
-	args := c.Called(topic, payload)
+func (c *DriverNatsConn) Request(_ context.Context, msg *nats.Msg) (*nats.Msg, error) {
+	args := c.Called(msg)
 
 	return args.Get(0).(*nats.Msg), args.Error(1)
 }
@@ -96,14 +100,92 @@ type DriverKafkaConn struct {
 	mock.Mock
 }
 
-func (c *DriverKafkaConn) Subscribe(topic string, handler func([]byte) error) error {
+func (c *DriverKafkaConn) Subscribe(topic string, workers int, handler func(*sarama.ConsumerMessage) error) error {
+	args := c.Called(topic, workers, handler)
+
+	return args.Error(0)
+}
+
+func (c *DriverKafkaConn) Publish(msg *sarama.ProducerMessage) error {
+	args := c.Called(msg)
+
+	return args.Error(0)
+}
+
+func (c *DriverKafkaConn) Positions(topic string) ([]*entity.Position, error) {
+	args := c.Called(topic)
+
+	return args.Get(0).([]*entity.Position), args.Error(1)
+}
+
+func (c *DriverKafkaConn) Replay(ctx context.Context, topic string, after []*entity.Position, handler func(*sarama.ConsumerMessage) error) error {
+	args := c.Called(ctx, topic, after, handler)
+
+	return args.Error(0)
+}
+
+type DriverMQTTConn struct {
+	mock.Mock
+}
+
+func (c *DriverMQTTConn) Subscribe(topic string, handler func(mqtt.Message) error) error {
 	args := c.Called(topic, handler)
 
 	return args.Error(0)
 }
 
-func (c *DriverKafkaConn) Publish(topic string, payload []byte) error {
-	args := c.Called(topic, payload)
+func (c *DriverMQTTConn) Publish(topic string, payload []byte, retained bool) error {
+	args := c.Called(topic, payload, retained)
+
+	return args.Error(0)
+}
+
+type DriverAMQPConn struct {
+	mock.Mock
+}
+
+func (c *DriverAMQPConn) Consume(queue string, handler func(*amqp.Delivery) error) error {
+	args := c.Called(queue, handler)
+
+	return args.Error(0)
+}
+
+func (c *DriverAMQPConn) Publish(_ context.Context, exchange, key string, msg amqp.Publishing) error {
+	args := c.Called(exchange, key, msg)
+
+	return args.Error(0)
+}
+
+func (c *DriverAMQPConn) Request(_ context.Context, exchange, key string, msg amqp.Publishing) (*amqp.Delivery, error) {
+	args := c.Called(exchange, key, msg)
+
+	return args.Get(0).(*amqp.Delivery), args.Error(1)
+}
+
+type DriverRedisConn struct {
+	mock.Mock
+}
+
+func (c *DriverRedisConn) Subscribe(channel string, handler func(*redis.Message) error) error {
+	args := c.Called(channel, handler)
+
+	return args.Error(0)
+}
+
+func (c *DriverRedisConn) Consume(stream string, handler func(*redis.XMessage) error) error {
+	args := c.Called(stream, handler)
+
+	return args.Error(0)
+}
+
+func (c *DriverRedisConn) Publish(_ context.Context, channel string, payload []byte) error {
+	args := c.Called(channel, payload)
+
+	return args.Error(0)
+}
+
+func (c *DriverRedisConn) Add(_ context.Context, stream string, maxLen int64, values map[string]interface{}) error {
+	args := c.Called(stream, maxLen, values)
 
 	return args.Error(0)
 }
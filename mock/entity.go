@@ -0,0 +1,19 @@
+package mock
+
+import (
+	"context"
+
+	"NATter/entity"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Message matches a message equal to the expected one regardless of its context.
+func Message(expected *entity.Message) interface{} {
+	return mock.MatchedBy(func(actual *entity.Message) bool {
+		ctx := context.Background()
+
+		return assert.ObjectsAreEqual(expected.WithContext(ctx), actual.WithContext(ctx))
+	})
+}
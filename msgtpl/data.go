@@ -0,0 +1,60 @@
+package msgtpl
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"NATter/entity"
+)
+
+const (
+	keyJSON      = "json"
+	keyURI       = "uri"
+	keySubject   = "subject"
+	keyWildcards = "wildcards"
+	keyHeader    = "header"
+)
+
+// Data returns the content of the message templates and filters refer to. The payload is decoded
+// as JSON with numbers kept as json.Number, so large integer IDs do not lose precision, and it is
+// null if the payload is not JSON. Values are of the types both text/template and jq evaluate.
+func Data(msg *entity.Message) map[string]interface{} {
+	res := map[string]interface{}{
+		keyURI:       toMap(msg.Params),
+		keySubject:   msg.Subject,
+		keyWildcards: toSlice(msg.Wildcards),
+		keyHeader:    toMap(msg.Header),
+		keyJSON:      nil,
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(msg.Payload))
+	dec.UseNumber()
+
+	var body interface{}
+
+	if err := dec.Decode(&body); err == nil {
+		res[keyJSON] = body
+	}
+
+	return res
+}
+
+func toMap(m map[string]string) map[string]interface{} {
+	res := make(map[string]interface{}, len(m))
+
+	for k, v := range m {
+		res[k] = v
+	}
+
+	return res
+}
+
+func toSlice(s []string) []interface{} {
+	res := make([]interface{}, len(s))
+
+	for i, v := range s {
+		res[i] = v
+	}
+
+	return res
+}
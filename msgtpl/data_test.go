@@ -0,0 +1,30 @@
+package msgtpl
+
+import (
+	"encoding/json"
+	"testing"
+
+	"NATter/entity"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestData(t *testing.T) {
+	data := Data(&entity.Message{
+		Payload:   []byte(`{"id":9007199254740993}`),
+		Params:    map[string]string{"id": "42"},
+		Subject:   "user.42.created",
+		Wildcards: []string{"42"},
+		Header:    map[string]string{"X-Event": "created"},
+	})
+
+	assert.Equal(t, map[string]interface{}{
+		"json":      map[string]interface{}{"id": json.Number("9007199254740993")},
+		"uri":       map[string]interface{}{"id": "42"},
+		"subject":   "user.42.created",
+		"wildcards": []interface{}{"42"},
+		"header":    map[string]interface{}{"X-Event": "created"},
+	}, data)
+
+	assert.Nil(t, Data(entity.NewMessage([]byte("some-data")))["json"])
+}
@@ -0,0 +1,232 @@
+package msgtpl
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"unicode"
+
+	"NATter/entity"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	actionDelim    = "{{"
+	actionEndDelim = "}}"
+
+	funcEscape   = "escape"
+	funcWildcard = "wildcard"
+
+	// Characters that would add subject tokens or wildcards if a value had them.
+	subjectTokenChars = ".*>/+#"
+)
+
+// {1} refers to the first subject token matched by a topic wildcard.
+var wildcardRefPattern = regexp.MustCompile(`\{(\d+)\}`)
+
+// wildcardAction prints the subject token a wildcard reference refers to, e.g. {{ wildcard $.wildcards 1 }}.
+var wildcardAction = actionDelim + " " + funcWildcard + " $$." + keyWildcards + " ${1} " + actionEndDelim
+
+// Template is a route topic or endpoint that may refer to message content,
+// e.g. 'orders.{{ .json.region }}', 'http://svc/users/{{ .uri.id }}' or '/hooks/{1}/created'.
+// Every value an action prints is escaped, so a message can not change the topic or endpoint beyond the value.
+type Template struct {
+	text string
+	tpl  *template.Template
+	err  error
+}
+
+// New returns the template of a topic, a printed value has to be a single subject token.
+// Wildcard references are printed as they are since the '>' wildcard gives several tokens.
+func New(text string) *Template {
+	return newTemplate(text, escapeToken, false)
+}
+
+// NewEndpoint returns the template of an endpoint, printed values and wildcard references
+// are escaped as URL path segments.
+func NewEndpoint(text string) *Template {
+	return newTemplate(text, escapePath, true)
+}
+
+func newTemplate(text string, escape func(interface{}) (string, error), escapeWildcards bool) *Template {
+	t := &Template{text: text}
+
+	if !IsTemplate(text) {
+		return t
+	}
+
+	t.tpl, t.err = template.New(text).
+		Option("missingkey=error").
+		Funcs(template.FuncMap{
+			funcEscape:   escape,
+			funcWildcard: wildcard,
+		}).
+		Parse(wildcardActions(text))
+
+	if t.err != nil {
+		t.err = errors.Wrapf(t.err, "invalid template %s", text)
+
+		return t
+	}
+
+	for _, tpl := range t.tpl.Templates() {
+		escapeActions(tpl.Tree, tpl.Tree.Root, escapeWildcards)
+	}
+
+	return t
+}
+
+// wildcardActions turns wildcard references outside of actions into actions, so the subject tokens
+// are printed by the template instead of being replaced in the text the message has already filled.
+func wildcardActions(text string) string {
+	res := &strings.Builder{}
+
+	for text != "" {
+		i := strings.Index(text, actionDelim)
+
+		if i < 0 {
+			i = len(text)
+		}
+
+		res.WriteString(wildcardRefPattern.ReplaceAllString(text[:i], wildcardAction))
+
+		text = text[i:]
+
+		j := strings.Index(text, actionEndDelim)
+
+		if j < 0 {
+			j = len(text)
+		} else {
+			j += len(actionEndDelim)
+		}
+
+		res.WriteString(text[:j])
+
+		text = text[j:]
+	}
+
+	return res.String()
+}
+
+// wildcard returns the subject token matched by the n-th wildcard of the route topic.
+func wildcard(wildcards []interface{}, n int) (interface{}, error) {
+	if n < 1 || n > len(wildcards) {
+		return "", errors.Errorf("no subject token for {%d} wildcard reference", n)
+	}
+
+	return wildcards[n-1], nil
+}
+
+// escapeActions pipes every value printed by actions of the node to the escape function.
+func escapeActions(tree *parse.Tree, node parse.Node, escapeWildcards bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+
+		for _, sub := range n.Nodes {
+			escapeActions(tree, sub, escapeWildcards)
+		}
+	case *parse.ActionNode:
+		// Variable declarations print nothing.
+		if len(n.Pipe.Decl) != 0 || (!escapeWildcards && isWildcard(n.Pipe)) {
+			return
+		}
+
+		n.Pipe.Cmds = append(n.Pipe.Cmds, &parse.CommandNode{
+			NodeType: parse.NodeCommand,
+			Pos:      n.Pos,
+			Args:     []parse.Node{parse.NewIdentifier(funcEscape).SetTree(tree).SetPos(n.Pos)},
+		})
+	case *parse.IfNode:
+		escapeActions(tree, n.List, escapeWildcards)
+		escapeActions(tree, n.ElseList, escapeWildcards)
+	case *parse.RangeNode:
+		escapeActions(tree, n.List, escapeWildcards)
+		escapeActions(tree, n.ElseList, escapeWildcards)
+	case *parse.WithNode:
+		escapeActions(tree, n.List, escapeWildcards)
+		escapeActions(tree, n.ElseList, escapeWildcards)
+	}
+}
+
+func isWildcard(pipe *parse.PipeNode) bool {
+	if len(pipe.Cmds) == 0 || len(pipe.Cmds[0].Args) == 0 {
+		return false
+	}
+
+	ident, ok := pipe.Cmds[0].Args[0].(*parse.IdentifierNode)
+
+	return ok && ident.Ident == funcWildcard
+}
+
+// escapeToken rejects a value that would add subject tokens or wildcards to the topic.
+func escapeToken(value interface{}) (string, error) {
+	res := fmt.Sprint(value)
+
+	if strings.ContainsAny(res, subjectTokenChars) || strings.IndexFunc(res, unicode.IsSpace) >= 0 {
+		return "", errors.Errorf("invalid topic value '%s', it must be a single token without '%s' and spaces",
+			res, subjectTokenChars)
+	}
+
+	return res, nil
+}
+
+// escapePath escapes a value as a URL path segment, so it can not change the path, the query or the host.
+func escapePath(value interface{}) (string, error) {
+	res := fmt.Sprint(value)
+
+	if res == "." || res == ".." {
+		return "", errors.Errorf("invalid endpoint value '%s'", res)
+	}
+
+	return url.PathEscape(res), nil
+}
+
+func IsTemplate(text string) bool {
+	return strings.Contains(text, actionDelim) || wildcardRefPattern.MatchString(text)
+}
+
+// WildcardRefs returns the greatest wildcard reference number of the text.
+func WildcardRefs(text string) (max int) {
+	for _, sub := range wildcardRefPattern.FindAllStringSubmatch(text, -1) {
+		if n, _ := strconv.Atoi(sub[1]); n > max {
+			max = n
+		}
+	}
+
+	return max
+}
+
+func Validate(text string) error {
+	return New(text).err
+}
+
+func (t *Template) String() string {
+	return t.text
+}
+
+func (t *Template) Execute(msg *entity.Message) (string, error) {
+	if t.err != nil {
+		return "", t.err
+	}
+
+	if t.tpl == nil {
+		return t.text, nil
+	}
+
+	buf := &bytes.Buffer{}
+
+	if err := t.tpl.Execute(buf, Data(msg)); err != nil {
+		return "", errors.Wrapf(err, "unable execute template %s", t.text)
+	}
+
+	return buf.String(), nil
+}
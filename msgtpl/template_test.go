@@ -0,0 +1,136 @@
+package msgtpl
+
+import (
+	"testing"
+
+	"NATter/entity"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplateExecute(t *testing.T) {
+	tpl := NewEndpoint("http://svc/{{ .json.region }}/users/{{ .uri.id }}/{{ .json.order.id }}")
+
+	res, err := tpl.Execute(&entity.Message{
+		Payload: []byte(`{"region":"eu","order":{"id":12345678901}}`),
+		Params:  map[string]string{"id": "42"},
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "http://svc/eu/users/42/12345678901", res)
+}
+
+func TestTemplateExecuteOnStatic(t *testing.T) {
+	res, err := New("topic").Execute(entity.NewMessage([]byte("not json")))
+
+	assert.Nil(t, err)
+	assert.Equal(t, "topic", res)
+}
+
+func TestTemplateExecuteOnMissingKey(t *testing.T) {
+	tpl := New("orders.{{ .json.region }}")
+
+	_, err := tpl.Execute(entity.NewMessage([]byte(`{"country":"de"}`)))
+	assert.NotNil(t, err)
+
+	_, err = tpl.Execute(entity.NewMessage([]byte("not json")))
+	assert.NotNil(t, err)
+
+	_, err = New("users.{{ .uri.id }}").Execute(entity.NewMessage(nil))
+	assert.NotNil(t, err)
+}
+
+func TestTemplateExecuteOnEndpointInjection(t *testing.T) {
+	tpl := NewEndpoint("http://svc/users/{{ .uri.id }}?source={{ .json.source }}")
+
+	res, err := tpl.Execute(&entity.Message{
+		Payload: []byte(`{"source":"a b&c=d"}`),
+		Params:  map[string]string{"id": "../admin?x="},
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "http://svc/users/..%2Fadmin%3Fx=?source=a%20b&c=d", res)
+
+	for _, id := range []string{".", ".."} {
+		_, err = tpl.Execute(&entity.Message{
+			Payload: []byte(`{"source":"web"}`),
+			Params:  map[string]string{"id": id},
+		})
+
+		assert.Error(t, err)
+	}
+}
+
+func TestTemplateExecuteOnSubjectInjection(t *testing.T) {
+	tpl := New("orders.{{ .json.region }}.created")
+
+	res, err := tpl.Execute(entity.NewMessage([]byte(`{"region":"eu-west_1"}`)))
+
+	assert.Nil(t, err)
+	assert.Equal(t, "orders.eu-west_1.created", res)
+
+	for _, region := range []string{"a.b", "*", ">", "eu west", "eu\\n", "a/b", "+", "#"} {
+		_, err = tpl.Execute(entity.NewMessage([]byte(`{"region":"` + region + `"}`)))
+
+		assert.Errorf(t, err, "region %s", region)
+	}
+
+	// Values of conditions are not escaped, only printed ones are.
+	res, err = New(`{{ if eq .json.kind "a.b" }}ab{{ else }}{{ .json.kind }}{{ end }}`).
+		Execute(entity.NewMessage([]byte(`{"kind":"a.b"}`)))
+
+	assert.Nil(t, err)
+	assert.Equal(t, "ab", res)
+}
+
+func TestValidate(t *testing.T) {
+	assert.Nil(t, Validate("orders.{{ .json.region }}"))
+	assert.Nil(t, Validate("orders"))
+	assert.NotNil(t, Validate("orders.{{ .json.region "))
+}
+
+func TestTemplateExecuteOnWildcards(t *testing.T) {
+	tpl := NewEndpoint("http://svc/hooks/{1}/{{ .subject }}/{2}")
+
+	res, err := tpl.Execute(&entity.Message{
+		Subject:   "user.42.created.eu.west",
+		Wildcards: []string{"42", "eu.west"},
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "http://svc/hooks/42/user.42.created.eu.west/eu.west", res)
+
+	_, err = NewEndpoint("http://svc/hooks/{3}").Execute(&entity.Message{
+		Wildcards: []string{"42"},
+	})
+
+	assert.Error(t, err)
+}
+
+func TestTemplateExecuteOnWildcardsInValues(t *testing.T) {
+	// A value that looks like a wildcard reference is not replaced.
+	res, err := NewEndpoint("http://svc/hooks/{1}/{{ .json.name }}").Execute(&entity.Message{
+		Payload:   []byte(`{"name":"{1}"}`),
+		Wildcards: []string{"42"},
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "http://svc/hooks/42/%7B1%7D", res)
+
+	// Subject tokens are escaped in endpoints.
+	res, err = NewEndpoint("http://svc/hooks/{1}").Execute(&entity.Message{
+		Wildcards: []string{"a/b?c"},
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "http://svc/hooks/a%2Fb%3Fc", res)
+
+	// The rest of the subject is passed to a topic as it is.
+	res, err = New("archive.{1}.{{ .json.kind }}").Execute(&entity.Message{
+		Payload:   []byte(`{"kind":"order"}`),
+		Wildcards: []string{"eu.west"},
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "archive.eu.west.order", res)
+}
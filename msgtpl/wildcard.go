@@ -0,0 +1,73 @@
+package msgtpl
+
+import (
+	"strings"
+)
+
+const (
+	subjectSep = "."
+
+	wildcardToken = "*"
+	wildcardTail  = ">"
+)
+
+// Wildcards returns subject tokens matched by wildcards of the topic pattern,
+// e.g. 'user.*.created' and 'user.42.created' give ['42'].
+// The '>' wildcard matches the rest of the subject as a single value.
+func Wildcards(pattern, subject string) []string {
+	if !IsWildcard(pattern) {
+		return nil
+	}
+
+	ptokens := strings.Split(pattern, subjectSep)
+	stokens := strings.Split(subject, subjectSep)
+
+	var res []string
+
+	for i, token := range ptokens {
+		if i >= len(stokens) {
+			break
+		}
+
+		switch token {
+		case wildcardToken:
+			res = append(res, stokens[i])
+		case wildcardTail:
+			res = append(res, strings.Join(stokens[i:], subjectSep))
+		}
+	}
+
+	return res
+}
+
+// Match reports whether the subject matches the topic pattern.
+func Match(pattern, subject string) bool {
+	ptokens := strings.Split(pattern, subjectSep)
+	stokens := strings.Split(subject, subjectSep)
+
+	for i, token := range ptokens {
+		if token == wildcardTail {
+			return i < len(stokens)
+		}
+
+		if i >= len(stokens) || (token != wildcardToken && token != stokens[i]) {
+			return false
+		}
+	}
+
+	return len(ptokens) == len(stokens)
+}
+
+func IsWildcard(pattern string) bool {
+	return CountWildcards(pattern) > 0
+}
+
+func CountWildcards(pattern string) (count int) {
+	for _, token := range strings.Split(pattern, subjectSep) {
+		if token == wildcardToken || token == wildcardTail {
+			count++
+		}
+	}
+
+	return count
+}
@@ -0,0 +1,29 @@
+package msgtpl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWildcards(t *testing.T) {
+	assert.Equal(t, []string{"42"}, Wildcards("user.*.created", "user.42.created"))
+	assert.Equal(t, []string{"42", "eu.west"}, Wildcards("user.*.created.>", "user.42.created.eu.west"))
+	assert.Nil(t, Wildcards("user.created", "user.created"))
+}
+
+func TestMatch(t *testing.T) {
+	assert.True(t, Match("user.created", "user.created"))
+	assert.True(t, Match("user.*.created", "user.42.created"))
+	assert.True(t, Match("user.>", "user.42.created"))
+	assert.False(t, Match("user.>", "user"))
+	assert.False(t, Match("user.*", "user.42.created"))
+	assert.False(t, Match("user.*.created", "user.42.deleted"))
+	assert.False(t, Match("user.*.created", "user.42"))
+}
+
+func TestCountWildcards(t *testing.T) {
+	assert.Equal(t, 2, CountWildcards("user.*.created.>"))
+	assert.Equal(t, 0, CountWildcards("user.created"))
+	assert.Equal(t, 3, WildcardRefs("/hooks/{1}/{3}/{id}"))
+}
@@ -0,0 +1,118 @@
+package ratelimit
+
+import (
+	"context"
+
+	"NATter/driver"
+	"NATter/entity"
+	"NATter/errtpl"
+
+	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
+)
+
+type Config struct {
+	Rate        float64 // messages per second, 0 means no rate limit
+	Burst       int
+	Concurrency int // concurrent messages, 0 means no cap
+	Wait        bool
+}
+
+// limiter passes messages to the sender within the rate and the concurrency limits.
+// Messages over the limits either wait or are rejected with errtpl.ErrTooManyRequests.
+type limiter struct {
+	sender driver.Sender
+	rate   *rate.Limiter
+	slots  chan struct{}
+	wait   bool
+}
+
+func New(cfg *Config, sender driver.Sender) (driver.Sender, error) {
+	if cfg.Rate < 0 || cfg.Burst < 0 || cfg.Concurrency < 0 {
+		return nil, errors.New("rate limit parameters must not be negative")
+	}
+
+	if cfg.Rate == 0 && cfg.Concurrency == 0 {
+		return nil, errors.New("rate limit requires rate or concurrency")
+	}
+
+	l := &limiter{
+		sender: sender,
+		wait:   cfg.Wait,
+	}
+
+	if cfg.Rate > 0 {
+		burst := cfg.Burst
+
+		if burst == 0 {
+			burst = 1
+		}
+
+		l.rate = rate.NewLimiter(rate.Limit(cfg.Rate), burst)
+	}
+
+	if cfg.Concurrency > 0 {
+		l.slots = make(chan struct{}, cfg.Concurrency)
+	}
+
+	return l, nil
+}
+
+// acquire waits for the limits until the context of the message is done.
+func (l *limiter) acquire(ctx context.Context) error {
+	if l.rate != nil {
+		if l.wait {
+			if err := l.rate.Wait(ctx); err != nil {
+				return errors.Wrap(err, "rate limit")
+			}
+		} else if !l.rate.Allow() {
+			return errors.Wrap(errtpl.ErrTooManyRequests, "rate limit exceeded")
+		}
+	}
+
+	if l.slots == nil {
+		return nil
+	}
+
+	if l.wait {
+		select {
+		case l.slots <- struct{}{}:
+			return nil
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "concurrency limit")
+		}
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	default:
+		return errors.Wrap(errtpl.ErrTooManyRequests, "concurrency limit exceeded")
+	}
+}
+
+func (l *limiter) release() {
+	if l.slots != nil {
+		<-l.slots
+	}
+}
+
+func (l *limiter) Send(msg *entity.Message) error {
+	if err := l.acquire(msg.Context()); err != nil {
+		return err
+	}
+
+	defer l.release()
+
+	return l.sender.Send(msg)
+}
+
+func (l *limiter) Request(msg *entity.Message) (*entity.Message, error) {
+	if err := l.acquire(msg.Context()); err != nil {
+		return nil, err
+	}
+
+	defer l.release()
+
+	return l.sender.Request(msg)
+}
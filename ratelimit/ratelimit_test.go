@@ -0,0 +1,165 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"NATter/entity"
+	"NATter/errtpl"
+	m "NATter/mock"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewOnInvalidParameters(t *testing.T) {
+	for _, cfg := range []*Config{
+		{},
+		{Rate: -1},
+		{Rate: 1, Burst: -1},
+		{Concurrency: -1},
+	} {
+		l, err := New(cfg, &m.DriverSender{})
+
+		assert.Error(t, err)
+		assert.Nil(t, l)
+	}
+}
+
+func TestLimiterSendOnRateExceeded(t *testing.T) {
+	sender := &m.DriverSender{}
+
+	sender.
+		On("Send", entity.NewMessage([]byte("some-data"))).
+		Return(nil).Twice()
+
+	l, err := New(&Config{Rate: 1, Burst: 2}, sender)
+
+	assert.Nil(t, err)
+
+	assert.Nil(t, l.Send(entity.NewMessage([]byte("some-data"))))
+	assert.Nil(t, l.Send(entity.NewMessage([]byte("some-data"))))
+
+	err = l.Send(entity.NewMessage([]byte("some-data")))
+
+	assert.True(t, errors.Is(err, errtpl.ErrTooManyRequests))
+	sender.AssertExpectations(t)
+}
+
+func TestLimiterSendOnWait(t *testing.T) {
+	sender := &m.DriverSender{}
+
+	sender.
+		On("Send", entity.NewMessage([]byte("some-data"))).
+		Return(nil).Times(3)
+
+	l, err := New(&Config{Rate: 100, Burst: 1, Wait: true}, sender)
+
+	assert.Nil(t, err)
+
+	start := time.Now()
+
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, l.Send(entity.NewMessage([]byte("some-data"))))
+	}
+
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(time.Millisecond*15))
+	sender.AssertExpectations(t)
+}
+
+// blockingSender holds requests until it is released.
+type blockingSender struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingSender) Send(*entity.Message) error {
+	return nil
+}
+
+func (s *blockingSender) Request(*entity.Message) (*entity.Message, error) {
+	s.started <- struct{}{}
+	<-s.release
+
+	return entity.NewMessage([]byte("response-data")), nil
+}
+
+func TestLimiterRequestOnConcurrencyExceeded(t *testing.T) {
+	sender := &blockingSender{
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+
+	l, err := New(&Config{Concurrency: 1}, sender)
+
+	assert.Nil(t, err)
+
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		resp, err := l.Request(entity.NewMessage([]byte("request-data")))
+
+		assert.Nil(t, err)
+		assert.Equal(t, "response-data", string(resp.Payload))
+	}()
+
+	<-sender.started
+
+	resp, err := l.Request(entity.NewMessage([]byte("request-data")))
+
+	assert.True(t, errors.Is(err, errtpl.ErrTooManyRequests))
+	assert.Nil(t, resp)
+
+	close(sender.release)
+	wg.Wait()
+
+	go func() { <-sender.started }()
+
+	_, err = l.Request(entity.NewMessage([]byte("request-data")))
+
+	assert.Nil(t, err)
+}
+
+func TestLimiterRequestOnWaitCanceled(t *testing.T) {
+	sender := &blockingSender{
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+
+	l, err := New(&Config{Concurrency: 1, Wait: true}, sender)
+
+	assert.Nil(t, err)
+
+	go func() {
+		_, _ = l.Request(entity.NewMessage([]byte("request-data")))
+	}()
+
+	<-sender.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+
+	// The message waiting for a slot gives up when its context is done.
+	resp, err := l.Request(entity.NewMessage([]byte("request-data")).WithContext(ctx))
+
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Nil(t, resp)
+
+	rl, err := New(&Config{Rate: 0.001, Wait: true}, &m.DriverSender{})
+
+	assert.Nil(t, err)
+
+	cancel()
+
+	// The burst is used up by the first message, the next one would wait for ages.
+	rl.(*limiter).rate.Allow()
+
+	assert.Error(t, rl.Send(entity.NewMessage([]byte("some-data")).WithContext(ctx)))
+
+	close(sender.release)
+}
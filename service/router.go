@@ -2,19 +2,27 @@ package service
 
 import (
 	"context"
+	"fmt"
 	"sync"
 
 	"NATter/batcher"
 	"NATter/batcher/encoder/protobuf"
+	"NATter/breaker"
+	"NATter/compression"
 	"NATter/driver"
 	"NATter/entity"
+	"NATter/filter"
 	"NATter/log"
+	"NATter/msgtpl"
+	"NATter/ratelimit"
+	"NATter/transform"
 
 	"github.com/pkg/errors"
 )
 
 var (
-	ErrUnknownConn = errors.New("unknown connection")
+	ErrUnknownConn   = errors.New("unknown connection")
+	ErrRouteConflict = errors.New("route conflict")
 )
 
 type RouterConfig struct {
@@ -24,6 +32,7 @@ type RouterConfig struct {
 type Router struct {
 	conns    map[string]driver.Conn
 	batchers []batcher.Batcher
+	breakers *breaker.Group
 
 	wg *sync.WaitGroup
 }
@@ -32,9 +41,14 @@ func NewRouter(cfg *RouterConfig, conns map[string]driver.Conn) (*Router, error)
 	router := &Router{
 		conns:    conns,
 		batchers: []batcher.Batcher{},
+		breakers: breaker.NewGroup(),
 		wg:       &sync.WaitGroup{},
 	}
 
+	if err := checkConflicts(cfg.Routes); err != nil {
+		return nil, err
+	}
+
 	if err := router.registerRoutes(cfg.Routes); err != nil {
 		return nil, err
 	}
@@ -46,6 +60,8 @@ func (router *Router) registerRoutes(routes []*entity.Route) (err error) {
 	for _, r := range routes {
 		modeComp := r.Mode.Components()
 
+		r.ID = describeRoute(r)
+
 		receiverConn, ok := router.conns[modeComp.Receiver]
 
 		if !ok {
@@ -58,9 +74,50 @@ func (router *Router) registerRoutes(routes []*entity.Route) (err error) {
 			return errors.Wrap(ErrUnknownConn, modeComp.Sender)
 		}
 
+		if _, err := compression.New(r.Compression); err != nil {
+			return err
+		}
+
+		if err := msgtpl.Validate(r.Topic); err != nil {
+			return err
+		}
+
+		if err := msgtpl.Validate(r.Endpoint); err != nil {
+			return err
+		}
+
+		if r.Stats == nil {
+			r.Stats = &entity.RouteStats{}
+		}
+
 		sender := senderConn.Sender(r)
+		resumer, _ := sender.(driver.Resumer)
+
+		if r.Breaker != nil {
+			// Broker subscriptions are paused while the breaker is open, HTTP requests are rejected.
+			grd, err := router.breakers.Sender(&breaker.Config{
+				Threshold: r.Breaker.Threshold,
+				Duration:  r.Breaker.Duration,
+				Probes:    r.Breaker.Probes,
+			}, r.Endpoint, sender, modeComp.Receiver != entity.DriverHTTP)
+
+			if err != nil {
+				return err
+			}
+
+			r.Stats.SetBreaker(grd)
+
+			sender = grd
+		}
 
 		if r.Batching != nil {
+			// Batches are sent in the background, so they wait for the limits, which apply to every batch.
+			if r.RateLimit != nil {
+				if sender, err = rateLimit(r, sender, true); err != nil {
+					return err
+				}
+			}
+
 			bat, err := batcher.New(&batcher.Config{
 				Timeout:  r.Batching.Timeout,
 				Capacity: r.Batching.Capacity,
@@ -75,9 +132,41 @@ func (router *Router) registerRoutes(routes []*entity.Route) (err error) {
 			sender = bat
 		}
 
+		if len(r.Transform) != 0 {
+			if sender, err = transform.New(&transform.Config{
+				Mode:  r.Mode,
+				Steps: r.Transform,
+			}, sender); err != nil {
+				return err
+			}
+		}
+
+		if r.RateLimit != nil && r.Batching == nil {
+			// Broker messages and async requests accepted already wait for the limits, HTTP requests are rejected.
+			if sender, err = rateLimit(r, sender, modeComp.Receiver != entity.DriverHTTP || r.Async); err != nil {
+				return err
+			}
+		}
+
+		if r.Filter != "" {
+			if sender, err = filter.New(&filter.Config{
+				Route:      r.ID,
+				Expression: r.Filter,
+				Stats:      r.Stats,
+			}, sender); err != nil {
+				return err
+			}
+		}
+
 		switch modeComp.Direction {
 		case entity.RouteDirectionOneway:
-			err = receiverConn.Receiver(r).Listen(sender)
+			receiver := receiverConn.Receiver(r)
+
+			if resumer != nil {
+				resume(r, resumer, receiver)
+			}
+
+			err = receiver.Listen(sender)
 		case entity.RouteDirectionTwoway:
 			err = receiverConn.Receiver(r).ListenRequest(sender)
 		default:
@@ -87,12 +176,71 @@ func (router *Router) registerRoutes(routes []*entity.Route) (err error) {
 		if err != nil {
 			return err
 		}
+
+		log.WithFields(log.Fields{
+			log.FieldRoute:    r.ID,
+			log.FieldTopic:    r.Topic,
+			log.FieldURI:      r.URI,
+			log.FieldEndpoint: r.Endpoint,
+		}).Debug("registered route")
+	}
+
+	return nil
+}
+
+// resume makes stream clients of the route resumed from the broker if the receiver replays messages
+// through the senders of the route. Batches can not be replayed message by message.
+func resume(r *entity.Route, resumer driver.Resumer, receiver driver.Receiver) {
+	if rep, ok := receiver.(driver.Replayer); ok && r.Batching == nil {
+		resumer.Resume(rep)
+
+		return
+	}
+
+	log.WithFields(log.Fields{
+		log.FieldRoute: r.ID,
+	}).Warn("route can not replay messages, stream clients are resumed only from messages kept in memory")
+}
+
+func rateLimit(r *entity.Route, sender driver.Sender, wait bool) (driver.Sender, error) {
+	return ratelimit.New(&ratelimit.Config{
+		Rate:        r.RateLimit.Rate,
+		Burst:       r.RateLimit.Burst,
+		Concurrency: r.RateLimit.Concurrency,
+		Wait:        wait,
+	}, sender)
+}
+
+// checkConflicts rejects routes that shadow each other, see entity.RouteConflicts.
+func checkConflicts(routes []*entity.Route) error {
+	conflicts := entity.NewRouteConflicts()
+
+	for i, r := range routes {
+		if j, reason, ok := conflicts.Add(i, r); ok {
+			return errors.Wrapf(ErrRouteConflict, "routes #%d (%s) and #%d (%s) %s",
+				j+1, describeRoute(routes[j]), i+1, describeRoute(r), reason)
+		}
 	}
 
 	return nil
 }
 
+func describeRoute(r *entity.Route) string {
+	return fmt.Sprintf("%s %s -> %s", r.Mode, r.Source(), r.Destination())
+}
+
 func (router *Router) Run(ctx context.Context) {
+	router.wg.Add(1)
+
+	// Messages held by open breakers are released on shutdown.
+	go func() {
+		defer router.wg.Done()
+
+		<-ctx.Done()
+
+		router.breakers.Close()
+	}()
+
 	for _, bat := range router.batchers {
 		router.wg.Add(1)
 
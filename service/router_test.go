@@ -2,11 +2,13 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"sync"
 	"testing"
 	"time"
 
 	"NATter/batcher"
+	"NATter/breaker"
 	"NATter/driver"
 	"NATter/entity"
 	m "NATter/mock"
@@ -27,12 +29,16 @@ func TestNewRouter(t *testing.T) {
 
 	connBroker.
 		On("Sender", &entity.Route{
-			Mode: entity.RouteMode("http-broker-oneway"),
+			ID:    "http-broker-oneway http: -> broker:",
+			Stats: &entity.RouteStats{},
+			Mode:  entity.RouteMode("http-broker-oneway"),
 		}).
 		Return(senderBroker)
 	connBroker.
 		On("Receiver", &entity.Route{
-			Mode: entity.RouteMode("broker-http-twoway"),
+			ID:    "broker-http-twoway broker: -> http:",
+			Stats: &entity.RouteStats{},
+			Mode:  entity.RouteMode("broker-http-twoway"),
 			Batching: &entity.RouteBatching{
 				Timeout:  30,
 				Capacity: 5,
@@ -42,12 +48,16 @@ func TestNewRouter(t *testing.T) {
 
 	connHTTP.
 		On("Receiver", &entity.Route{
-			Mode: entity.RouteMode("http-broker-oneway"),
+			ID:    "http-broker-oneway http: -> broker:",
+			Stats: &entity.RouteStats{},
+			Mode:  entity.RouteMode("http-broker-oneway"),
 		}).
 		Return(receiverHTTP)
 	connHTTP.
 		On("Sender", &entity.Route{
-			Mode: entity.RouteMode("broker-http-twoway"),
+			ID:    "broker-http-twoway broker: -> http:",
+			Stats: &entity.RouteStats{},
+			Mode:  entity.RouteMode("broker-http-twoway"),
 			Batching: &entity.RouteBatching{
 				Timeout:  30,
 				Capacity: 5,
@@ -123,7 +133,9 @@ func TestNewRouterOnNewBatcherError(t *testing.T) {
 
 	connHTTP.
 		On("Sender", &entity.Route{
-			Mode: entity.RouteMode("broker-http-twoway"),
+			ID:    "broker-http-twoway broker: -> http:",
+			Stats: &entity.RouteStats{},
+			Mode:  entity.RouteMode("broker-http-twoway"),
 			Batching: &entity.RouteBatching{
 				Timeout:  0,
 				Capacity: 0,
@@ -150,12 +162,277 @@ func TestNewRouterOnNewBatcherError(t *testing.T) {
 	assert.Nil(t, router)
 }
 
+func TestNewRouterOnNewTransformError(t *testing.T) {
+	route := &entity.Route{
+		Mode: entity.RouteMode("http-broker-oneway"),
+		Transform: []*entity.RouteTransform{
+			{JQ: "{id: .id"},
+		},
+	}
+
+	connBroker := &m.DriverConn{}
+
+	connBroker.
+		On("Sender", route).
+		Return(&m.DriverSender{})
+
+	router, err := NewRouter(&RouterConfig{
+		Routes: []*entity.Route{route},
+	}, map[string]driver.Conn{
+		"broker": connBroker,
+		"http":   &m.DriverConn{},
+	})
+
+	assert.Error(t, err)
+	assert.Nil(t, router)
+}
+
+func TestNewRouterOnSharedBreaker(t *testing.T) {
+	routes := []*entity.Route{
+		{
+			Mode:     entity.RouteMode("broker-http-oneway"),
+			Topic:    "topic1",
+			Endpoint: "http://svc/path",
+			Breaker:  &entity.RouteBreaker{Threshold: 5, Duration: 30},
+		},
+		{
+			Mode:     entity.RouteMode("broker-http-oneway"),
+			Topic:    "topic2",
+			Endpoint: "http://svc/path",
+			Breaker:  &entity.RouteBreaker{Threshold: 5, Duration: 30},
+		},
+	}
+
+	connBroker := &m.DriverConn{}
+	connHTTP := &m.DriverConn{}
+	receiver := &m.DriverReceiver{}
+
+	for _, r := range routes {
+		connHTTP.On("Sender", r).Return(&m.DriverSender{})
+		connBroker.On("Receiver", r).Return(receiver)
+	}
+
+	receiver.On("Listen", mock.AnythingOfType("*breaker.Guard")).Return(nil)
+
+	router, err := NewRouter(&RouterConfig{
+		Routes: routes,
+	}, map[string]driver.Conn{
+		"broker": connBroker,
+		"http":   connHTTP,
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, router.breakers.Len())
+
+	stats, err := json.Marshal(routes[1].Stats)
+
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"filtered":0,"dropped":0,"breaker":"closed"}`, string(stats))
+}
+
+// resumerSender is a stream sender that records the replayer it is resumed by.
+type resumerSender struct {
+	*m.DriverSender
+	replayer driver.Replayer
+}
+
+func (s *resumerSender) Resume(rep driver.Replayer) {
+	s.replayer = rep
+}
+
+// replayerReceiver is a receiver that replays nothing.
+type replayerReceiver struct {
+	*m.DriverReceiver
+}
+
+func (r *replayerReceiver) Positions() ([]*entity.Position, error) {
+	return nil, nil
+}
+
+func (r *replayerReceiver) Replay(context.Context, []*entity.Position) error {
+	return nil
+}
+
+func TestNewRouterOnResumer(t *testing.T) {
+	routes := []*entity.Route{
+		{Mode: entity.RouteMode("broker-sse-oneway"), Topic: "topic1", URI: "/events"},
+		{Mode: entity.RouteMode("broker-sse-oneway"), Topic: "topic2", URI: "/events", Batching: &entity.RouteBatching{Timeout: 1, Capacity: 5}},
+	}
+
+	connBroker := &m.DriverConn{}
+	connSSE := &m.DriverConn{}
+	receiver := &replayerReceiver{DriverReceiver: &m.DriverReceiver{}}
+	senders := []*resumerSender{{DriverSender: &m.DriverSender{}}, {DriverSender: &m.DriverSender{}}}
+
+	for i, r := range routes {
+		connSSE.On("Sender", r).Return(senders[i])
+		connBroker.On("Receiver", r).Return(receiver)
+	}
+
+	receiver.On("Listen", mock.Anything).Return(nil)
+
+	router, err := NewRouter(&RouterConfig{
+		Routes: routes,
+	}, map[string]driver.Conn{
+		"broker": connBroker,
+		"sse":    connSSE,
+	})
+
+	assert.Nil(t, err)
+	assert.NotNil(t, router)
+
+	// Batches are not replayed.
+	assert.Equal(t, receiver, senders[0].replayer)
+	assert.Nil(t, senders[1].replayer)
+}
+
+func TestNewRouterOnNewFilterError(t *testing.T) {
+	route := &entity.Route{
+		Mode:   entity.RouteMode("broker-http-oneway"),
+		Filter: ".json.id ==",
+	}
+
+	connHTTP := &m.DriverConn{}
+
+	connHTTP.
+		On("Sender", route).
+		Return(&m.DriverSender{})
+
+	router, err := NewRouter(&RouterConfig{
+		Routes: []*entity.Route{route},
+	}, map[string]driver.Conn{
+		"broker": &m.DriverConn{},
+		"http":   connHTTP,
+	})
+
+	assert.Error(t, err)
+	assert.Nil(t, router)
+}
+
+func TestNewRouterOnRouteConflict(t *testing.T) {
+	for _, tc := range []struct {
+		routes []*entity.Route
+		err    string
+	}{
+		{
+			routes: []*entity.Route{
+				{Mode: "http-broker-oneway", URI: "/path", Topic: "topic1"},
+				{Mode: "http-broker-twoway", URI: "/path", Topic: "topic2"},
+			},
+			err: "routes #1 (http-broker-oneway http:/path -> broker:topic1) and " +
+				"#2 (http-broker-twoway http:/path -> broker:topic2) receive from the same URI: route conflict",
+		},
+		{
+			routes: []*entity.Route{
+				{Mode: "http-broker-oneway", URI: "/users/{id}", Topic: "topic1"},
+				{Mode: "http-broker-oneway", URI: "/users/{name:[a-z]+}", Topic: "topic2"},
+			},
+			err: "routes #1 (http-broker-oneway http:/users/{id} -> broker:topic1) and " +
+				"#2 (http-broker-oneway http:/users/{name:[a-z]+} -> broker:topic2) receive from the same URI: route conflict",
+		},
+		{
+			routes: []*entity.Route{
+				{Mode: "broker-http-twoway", Topic: "topic", Endpoint: "http://svc/path1"},
+				{Mode: "broker-http-oneway", Topic: "other", Endpoint: "http://svc/path1"},
+				{Mode: "broker-http-twoway", Topic: "topic", Endpoint: "http://svc/path2"},
+			},
+			err: "routes #1 (broker-http-twoway broker:topic -> http:http://svc/path1) and " +
+				"#3 (broker-http-twoway broker:topic -> http:http://svc/path2) reply to the same requests: route conflict",
+		},
+		{
+			routes: []*entity.Route{
+				{Mode: "broker-http-oneway", Topic: "topic", Endpoint: "http://svc/path"},
+				{Mode: "broker-http-oneway", Topic: "topic", Endpoint: "http://svc/path"},
+			},
+			err: "routes #1 (broker-http-oneway broker:topic -> http:http://svc/path) and " +
+				"#2 (broker-http-oneway broker:topic -> http:http://svc/path) are the same: route conflict",
+		},
+		{
+			routes: []*entity.Route{
+				{Mode: "broker-ws-oneway", Topic: "topic", URI: "/events"},
+				{Mode: "ws-broker-oneway", URI: "/events", Topic: "topic"},
+				{Mode: "broker-ws-oneway", Topic: "topic", URI: "/events"},
+			},
+			err: "routes #1 (broker-ws-oneway broker:topic -> ws:/events) and " +
+				"#3 (broker-ws-oneway broker:topic -> ws:/events) are the same: route conflict",
+		},
+		{
+			routes: []*entity.Route{
+				{Mode: "broker-sse-oneway", Topic: "topic1", URI: "/events"},
+				{Mode: "broker-sse-oneway", Topic: "topic2", URI: "/events"},
+				{Mode: "http-broker-oneway", URI: "/events", Topic: "topic"},
+			},
+			err: "routes #1 (broker-sse-oneway broker:topic1 -> sse:/events) and " +
+				"#3 (http-broker-oneway http:/events -> broker:topic) use the same URI: route conflict",
+		},
+		{
+			routes: []*entity.Route{
+				{Mode: "mqtt-broker-oneway", MQTTTopic: "devices/+/state", Topic: "devices.state"},
+				{Mode: "mqtt-broker-oneway", MQTTTopic: "devices/+/state", Topic: "devices.state"},
+			},
+			err: "routes #1 (mqtt-broker-oneway mqtt:devices/+/state -> broker:devices.state) and " +
+				"#2 (mqtt-broker-oneway mqtt:devices/+/state -> broker:devices.state) are the same: route conflict",
+		},
+		{
+			routes: []*entity.Route{
+				{Mode: "http-amqp-oneway", URI: "/orders", AMQPExchange: "orders", AMQPRoutingKey: "order.created"},
+				{Mode: "amqp-broker-twoway", AMQPQueue: "orders", Topic: "orders"},
+				{Mode: "amqp-broker-twoway", AMQPQueue: "orders", Topic: "orders"},
+			},
+			err: "routes #2 (amqp-broker-twoway amqp:orders -> broker:orders) and " +
+				"#3 (amqp-broker-twoway amqp:orders -> broker:orders) reply to the same requests: route conflict",
+		},
+		{
+			routes: []*entity.Route{
+				{Mode: "redis-http-oneway", RedisChannel: "orders", Endpoint: "http://localhost/orders"},
+				{Mode: "redis-http-oneway", RedisStream: "orders", Endpoint: "http://localhost/orders"},
+				{Mode: "redis-http-oneway", RedisStream: "orders", Endpoint: "http://localhost/orders"},
+			},
+			err: "routes #2 (redis-http-oneway redis:stream:orders -> http:http://localhost/orders) and " +
+				"#3 (redis-http-oneway redis:stream:orders -> http:http://localhost/orders) are the same: route conflict",
+		},
+	} {
+		router, err := NewRouter(&RouterConfig{Routes: tc.routes}, map[string]driver.Conn{
+			"broker": &m.DriverConn{},
+			"http":   &m.DriverConn{},
+			"ws":     &m.DriverConn{},
+			"sse":    &m.DriverConn{},
+			"mqtt":   &m.DriverConn{},
+			"amqp":   &m.DriverConn{},
+			"redis":  &m.DriverConn{},
+		})
+
+		assert.EqualError(t, err, tc.err)
+		assert.True(t, errors.Is(err, ErrRouteConflict))
+		assert.Nil(t, router)
+	}
+}
+
+func TestNewRouterOnUnknownCompression(t *testing.T) {
+	router, err := NewRouter(&RouterConfig{
+		Routes: []*entity.Route{
+			{
+				Mode:        entity.RouteMode("http-broker-oneway"),
+				Compression: "unknown",
+			},
+		},
+	}, map[string]driver.Conn{
+		"broker": &m.DriverConn{},
+		"http":   &m.DriverConn{},
+	})
+
+	assert.Error(t, err)
+	assert.Nil(t, router)
+}
+
 func TestNewRouterOnUnknownDirection(t *testing.T) {
 	DriverConnBroker := &m.DriverConn{}
 
 	DriverConnBroker.
 		On("Sender", &entity.Route{
-			Mode: entity.RouteMode("http-broker-UNKNOWN"),
+			ID:    "http-broker-UNKNOWN http: -> broker:",
+			Stats: &entity.RouteStats{},
+			Mode:  entity.RouteMode("http-broker-UNKNOWN"),
 		}).
 		Return(&m.DriverSender{})
 
@@ -200,7 +477,8 @@ func TestRouterRun(t *testing.T) {
 			bat1,
 			bat2,
 		},
-		wg: &sync.WaitGroup{},
+		breakers: breaker.NewGroup(),
+		wg:       &sync.WaitGroup{},
 	}
 
 	router.Run(ctx)
@@ -223,7 +501,8 @@ func TestRouterRunOnServeError(t *testing.T) {
 		conns: map[string]driver.Conn{
 			"conn": conn,
 		},
-		wg: &sync.WaitGroup{},
+		breakers: breaker.NewGroup(),
+		wg:       &sync.WaitGroup{},
 	}
 
 	router.Run(ctx)
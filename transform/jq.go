@@ -0,0 +1,51 @@
+package transform
+
+import (
+	"encoding/json"
+
+	"NATter/entity"
+
+	"github.com/itchyny/gojq"
+	"github.com/pkg/errors"
+)
+
+type jqStep struct {
+	code *gojq.Code
+}
+
+func newJQStep(expr string) (*jqStep, error) {
+	query, err := gojq.Parse(expr)
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid jq expression %s", expr)
+	}
+
+	code, err := gojq.Compile(query)
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid jq expression %s", expr)
+	}
+
+	return &jqStep{code: code}, nil
+}
+
+// apply returns the first result of the expression.
+func (s *jqStep) apply(msg *entity.Message) ([]byte, error) {
+	var input interface{}
+
+	if err := json.Unmarshal(msg.Payload, &input); err != nil {
+		return nil, errors.Wrap(err, "payload is not JSON")
+	}
+
+	res, ok := s.code.Run(input).Next()
+
+	if !ok {
+		return nil, errors.New("jq expression has no result")
+	}
+
+	if err, ok := res.(error); ok {
+		return nil, err
+	}
+
+	return json.Marshal(res)
+}
@@ -0,0 +1,162 @@
+package transform
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"NATter/entity"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	pathSep = "."
+
+	envelopeMeta = "meta"
+)
+
+type renameStep struct {
+	paths map[string]string
+}
+
+func (s *renameStep) apply(msg *entity.Message) ([]byte, error) {
+	return updateObject(msg.Payload, func(obj map[string]interface{}) {
+		for _, from := range sortedKeys(s.paths) {
+			if value, ok := deletePath(obj, from); ok {
+				setPath(obj, s.paths[from], value)
+			}
+		}
+	})
+}
+
+type removeStep struct {
+	paths []string
+}
+
+func (s *removeStep) apply(msg *entity.Message) ([]byte, error) {
+	return updateObject(msg.Payload, func(obj map[string]interface{}) {
+		for _, path := range s.paths {
+			deletePath(obj, path)
+		}
+	})
+}
+
+type addStep struct {
+	values map[string]interface{}
+}
+
+func (s *addStep) apply(msg *entity.Message) ([]byte, error) {
+	return updateObject(msg.Payload, func(obj map[string]interface{}) {
+		for _, path := range sortedKeys(s.values) {
+			setPath(obj, path, s.values[path])
+		}
+	})
+}
+
+// envelopeStep wraps the payload into an object with the message metadata.
+type envelopeStep struct {
+	key  string
+	mode entity.RouteMode
+}
+
+func (s *envelopeStep) apply(msg *entity.Message) ([]byte, error) {
+	var payload interface{} = json.RawMessage(msg.Payload)
+
+	if !json.Valid(msg.Payload) {
+		payload = string(msg.Payload)
+	}
+
+	meta := map[string]interface{}{
+		"route": s.mode,
+		"time":  time.Now().UTC().Format(time.RFC3339Nano),
+	}
+
+	if msg.Subject != "" {
+		meta["subject"] = msg.Subject
+	}
+
+	if len(msg.Params) != 0 {
+		meta["uri"] = msg.Params
+	}
+
+	return json.Marshal(map[string]interface{}{
+		envelopeMeta: meta,
+		s.key:        payload,
+	})
+}
+
+func updateObject(payload []byte, update func(map[string]interface{})) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber()
+
+	obj := map[string]interface{}{}
+
+	if err := dec.Decode(&obj); err != nil {
+		return nil, errors.Wrap(err, "payload is not JSON object")
+	}
+
+	update(obj)
+
+	return json.Marshal(obj)
+}
+
+func deletePath(obj map[string]interface{}, path string) (interface{}, bool) {
+	keys := strings.Split(path, pathSep)
+
+	for _, key := range keys[:len(keys)-1] {
+		sub, ok := obj[key].(map[string]interface{})
+
+		if !ok {
+			return nil, false
+		}
+
+		obj = sub
+	}
+
+	last := keys[len(keys)-1]
+	value, ok := obj[last]
+
+	delete(obj, last)
+
+	return value, ok
+}
+
+// setPath sets the value creating missing intermediate objects.
+func setPath(obj map[string]interface{}, path string, value interface{}) {
+	keys := strings.Split(path, pathSep)
+
+	for _, key := range keys[:len(keys)-1] {
+		sub, ok := obj[key].(map[string]interface{})
+
+		if !ok {
+			sub = map[string]interface{}{}
+			obj[key] = sub
+		}
+
+		obj = sub
+	}
+
+	obj[keys[len(keys)-1]] = value
+}
+
+func sortedKeys(m interface{}) []string {
+	var keys []string
+
+	switch v := m.(type) {
+	case map[string]string:
+		for k := range v {
+			keys = append(keys, k)
+		}
+	case map[string]interface{}:
+		for k := range v {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
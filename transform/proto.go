@@ -0,0 +1,92 @@
+package transform
+
+import (
+	"io/ioutil"
+
+	"NATter/entity"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const (
+	ProtoToProto = "proto"
+	ProtoToJSON  = "json"
+)
+
+// protoStep converts payloads between JSON and protobuf by a message of a descriptor set.
+type protoStep struct {
+	desc protoreflect.MessageDescriptor
+	to   string
+}
+
+func newProtoStep(cfg *entity.RouteTransformProto) (*protoStep, error) {
+	if cfg.To != ProtoToProto && cfg.To != ProtoToJSON {
+		return nil, errors.Errorf("unknown proto conversion: %s", cfg.To)
+	}
+
+	desc, err := loadMessageDescriptor(cfg.Descriptor, cfg.Message)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &protoStep{desc: desc, to: cfg.To}, nil
+}
+
+func loadMessageDescriptor(path, name string) (protoreflect.MessageDescriptor, error) {
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	set := &descriptorpb.FileDescriptorSet{}
+
+	if err := proto.Unmarshal(data, set); err != nil {
+		return nil, errors.Wrapf(err, "invalid descriptor set %s", path)
+	}
+
+	files, err := protodesc.NewFiles(set)
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid descriptor set %s", path)
+	}
+
+	desc, err := files.FindDescriptorByName(protoreflect.FullName(name))
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable find message %s", name)
+	}
+
+	msgDesc, ok := desc.(protoreflect.MessageDescriptor)
+
+	if !ok {
+		return nil, errors.Errorf("%s is not a message", name)
+	}
+
+	return msgDesc, nil
+}
+
+func (s *protoStep) apply(msg *entity.Message) ([]byte, error) {
+	pb := dynamicpb.NewMessage(s.desc)
+
+	if s.to == ProtoToProto {
+		if err := protojson.Unmarshal(msg.Payload, pb); err != nil {
+			return nil, err
+		}
+
+		return proto.Marshal(pb)
+	}
+
+	if err := proto.Unmarshal(msg.Payload, pb); err != nil {
+		return nil, err
+	}
+
+	return protojson.Marshal(pb)
+}
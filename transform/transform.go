@@ -0,0 +1,122 @@
+package transform
+
+import (
+	"NATter/driver"
+	"NATter/entity"
+
+	"github.com/pkg/errors"
+)
+
+type Config struct {
+	Mode  entity.RouteMode
+	Steps []*entity.RouteTransform
+}
+
+type step interface {
+	apply(msg *entity.Message) ([]byte, error)
+}
+
+// transformer applies the steps to every message before passing it to the sender.
+type transformer struct {
+	sender driver.Sender
+	steps  []step
+}
+
+func New(cfg *Config, sender driver.Sender) (driver.Sender, error) {
+	steps := make([]step, len(cfg.Steps))
+
+	for i, st := range cfg.Steps {
+		var err error
+
+		if steps[i], err = newStep(st, cfg.Mode); err != nil {
+			return nil, errors.Wrapf(err, "transform step %d", i+1)
+		}
+	}
+
+	return &transformer{
+		sender: sender,
+		steps:  steps,
+	}, nil
+}
+
+func newStep(st *entity.RouteTransform, mode entity.RouteMode) (step, error) {
+	var steps []step
+
+	if len(st.Rename) != 0 {
+		steps = append(steps, &renameStep{paths: st.Rename})
+	}
+
+	if len(st.Remove) != 0 {
+		steps = append(steps, &removeStep{paths: st.Remove})
+	}
+
+	if len(st.Add) != 0 {
+		steps = append(steps, &addStep{values: st.Add})
+	}
+
+	if st.Envelope != "" {
+		steps = append(steps, &envelopeStep{key: st.Envelope, mode: mode})
+	}
+
+	if st.JQ != "" {
+		s, err := newJQStep(st.JQ)
+
+		if err != nil {
+			return nil, err
+		}
+
+		steps = append(steps, s)
+	}
+
+	if st.Proto != nil {
+		s, err := newProtoStep(st.Proto)
+
+		if err != nil {
+			return nil, err
+		}
+
+		steps = append(steps, s)
+	}
+
+	if len(steps) != 1 {
+		return nil, errors.Errorf("%d operations are set instead of one", len(steps))
+	}
+
+	return steps[0], nil
+}
+
+func (t *transformer) transform(msg *entity.Message) (*entity.Message, error) {
+	res := *msg
+
+	for _, st := range t.steps {
+		payload, err := st.apply(&res)
+
+		if err != nil {
+			return nil, errors.Wrap(err, "unable transform payload")
+		}
+
+		res.Payload = payload
+	}
+
+	return &res, nil
+}
+
+func (t *transformer) Send(msg *entity.Message) error {
+	msg, err := t.transform(msg)
+
+	if err != nil {
+		return err
+	}
+
+	return t.sender.Send(msg)
+}
+
+func (t *transformer) Request(msg *entity.Message) (*entity.Message, error) {
+	msg, err := t.transform(msg)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return t.sender.Request(msg)
+}
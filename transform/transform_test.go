@@ -0,0 +1,231 @@
+package transform
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"NATter/entity"
+	m "NATter/mock"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestNewOnInvalidStep(t *testing.T) {
+	for _, steps := range [][]*entity.RouteTransform{
+		{{}},
+		{{Envelope: "data", JQ: "."}},
+		{{JQ: "{id: .id"}},
+		{{Proto: &entity.RouteTransformProto{To: "xml"}}},
+		{{Proto: &entity.RouteTransformProto{To: ProtoToProto, Descriptor: "unknown.pb"}}},
+	} {
+		tr, err := New(&Config{Steps: steps}, &m.DriverSender{})
+
+		assert.Error(t, err)
+		assert.Nil(t, tr)
+	}
+}
+
+func TestTransformerSend(t *testing.T) {
+	sender := &m.DriverSender{}
+
+	sender.
+		On("Send", &entity.Message{
+			Payload: []byte(`{"name":"John","user":{"datetime":"2021-07-01","id":42},"version":2}`),
+			Subject: "users",
+		}).
+		Return(nil)
+
+	tr, err := New(&Config{
+		Steps: []*entity.RouteTransform{
+			{Rename: map[string]string{"user.date": "user.datetime", "user.name": "name"}},
+			{Remove: []string{"user.password", "unknown.path"}},
+			{Add: map[string]interface{}{"version": 2}},
+		},
+	}, sender)
+
+	assert.Nil(t, err)
+
+	err = tr.Send(&entity.Message{
+		Payload: []byte(`{"user":{"id":42,"name":"John","date":"2021-07-01","password":"secret"}}`),
+		Subject: "users",
+	})
+
+	assert.Nil(t, err)
+	sender.AssertExpectations(t)
+}
+
+func TestTransformerSendOnInvalidPayload(t *testing.T) {
+	tr, err := New(&Config{
+		Steps: []*entity.RouteTransform{{Remove: []string{"password"}}},
+	}, &m.DriverSender{})
+
+	assert.Nil(t, err)
+
+	err = tr.Send(entity.NewMessage([]byte("some-data")))
+
+	assert.Error(t, err)
+}
+
+func TestTransformerRequest(t *testing.T) {
+	sender := &m.DriverSender{}
+
+	sender.
+		On("Request", entity.NewMessage([]byte(`{"id":42,"tags":["a","b"]}`))).
+		Return(entity.NewMessage([]byte("response-data")), nil)
+
+	tr, err := New(&Config{
+		Steps: []*entity.RouteTransform{{JQ: "{id: .user.id, tags: [.tags[].name]}"}},
+	}, sender)
+
+	assert.Nil(t, err)
+
+	resp, err := tr.Request(entity.NewMessage([]byte(`{"user":{"id":42},"tags":[{"name":"a"},{"name":"b"}]}`)))
+
+	assert.Nil(t, err)
+	assert.Equal(t, "response-data", string(resp.Payload))
+}
+
+func TestTransformerRequestOnJQError(t *testing.T) {
+	tr, err := New(&Config{
+		Steps: []*entity.RouteTransform{{JQ: ".id | error"}},
+	}, &m.DriverSender{})
+
+	assert.Nil(t, err)
+
+	resp, err := tr.Request(entity.NewMessage([]byte(`{"id":42}`)))
+
+	assert.Error(t, err)
+	assert.Nil(t, resp)
+}
+
+func TestTransformerSendOnEnvelope(t *testing.T) {
+	sender := &m.DriverSender{}
+
+	sender.
+		On("Send", mock.MatchedBy(func(msg *entity.Message) bool {
+			var res struct {
+				Meta map[string]interface{} `json:"meta"`
+				Data map[string]interface{} `json:"data"`
+			}
+
+			if err := json.Unmarshal(msg.Payload, &res); err != nil {
+				return false
+			}
+
+			return res.Meta["route"] == "http-broker-oneway" &&
+				res.Meta["uri"].(map[string]interface{})["id"] == "42" &&
+				res.Meta["time"] != nil &&
+				res.Data["name"] == "John"
+		})).
+		Return(errors.New("error"))
+
+	tr, err := New(&Config{
+		Mode:  entity.RouteMode("http-broker-oneway"),
+		Steps: []*entity.RouteTransform{{Envelope: "data"}},
+	}, sender)
+
+	assert.Nil(t, err)
+
+	err = tr.Send(&entity.Message{
+		Payload: []byte(`{"name":"John"}`),
+		Params:  map[string]string{"id": "42"},
+	})
+
+	assert.Error(t, err)
+	sender.AssertExpectations(t)
+}
+
+func testDescriptorSet(t *testing.T) string {
+	t.Helper()
+
+	set := &descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{{
+			Name:    proto.String("user.proto"),
+			Package: proto.String("test"),
+			Syntax:  proto.String("proto3"),
+			MessageType: []*descriptorpb.DescriptorProto{{
+				Name: proto.String("User"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					{
+						Name:     proto.String("id"),
+						JsonName: proto.String("id"),
+						Number:   proto.Int32(1),
+						Type:     descriptorpb.FieldDescriptorProto_TYPE_INT64.Enum(),
+						Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+					},
+					{
+						Name:     proto.String("name"),
+						JsonName: proto.String("name"),
+						Number:   proto.Int32(2),
+						Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+						Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+					},
+				},
+			}},
+		}},
+	}
+
+	data, err := proto.Marshal(set)
+	assert.Nil(t, err)
+
+	dir, err := ioutil.TempDir("", "natter-transform")
+	assert.Nil(t, err)
+
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "user.pb")
+
+	assert.Nil(t, ioutil.WriteFile(path, data, 0600))
+
+	return path
+}
+
+func TestTransformerSendOnProto(t *testing.T) {
+	desc := testDescriptorSet(t)
+
+	sender := &m.DriverSender{}
+
+	sender.
+		On("Send", mock.MatchedBy(func(msg *entity.Message) bool {
+			var res map[string]interface{}
+
+			return json.Unmarshal(msg.Payload, &res) == nil &&
+				res["id"] == "42" && res["name"] == "John"
+		})).
+		Return(nil)
+
+	tr, err := New(&Config{
+		Steps: []*entity.RouteTransform{
+			{Proto: &entity.RouteTransformProto{Descriptor: desc, Message: "test.User", To: ProtoToProto}},
+			{Proto: &entity.RouteTransformProto{Descriptor: desc, Message: "test.User", To: ProtoToJSON}},
+		},
+	}, sender)
+
+	assert.Nil(t, err)
+
+	err = tr.Send(entity.NewMessage([]byte(`{"id":42,"name":"John"}`)))
+
+	assert.Nil(t, err)
+	sender.AssertExpectations(t)
+}
+
+func TestTransformerSendOnProtoUnknownField(t *testing.T) {
+	tr, err := New(&Config{
+		Steps: []*entity.RouteTransform{
+			{Proto: &entity.RouteTransformProto{Descriptor: testDescriptorSet(t), Message: "test.User", To: ProtoToProto}},
+		},
+	}, &m.DriverSender{})
+
+	assert.Nil(t, err)
+
+	err = tr.Send(entity.NewMessage([]byte(`{"email":"john@example.com"}`)))
+
+	assert.Error(t, err)
+}